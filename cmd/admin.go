@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"runtime/pprof"
+
+	"lovebox/pkg/config"
+	"lovebox/pkg/gin/middlewares"
+	"lovebox/pkg/httpserver"
+	"lovebox/pkg/resp"
+	"lovebox/pkg/validator"
+
+	ginpprof "github.com/gin-contrib/pprof"
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// GetGinAdminEngine 内部管理服务：健康检查、pprof、metrics、日志级别、构建信息、goroutine与配置，
+// 只允许http.internal.ipRanges内的地址访问，不应暴露到公网
+func GetGinAdminEngine(pkgs *Packages) *gin.Engine {
+	router := gin.New()
+	// 不信任X-Forwarded-For，按连接地址做ip校验
+	_ = router.SetTrustedProxies(nil)
+
+	router.Use(gin.Recovery())
+	router.Use(middlewares.NewIPAuthMiddleware(viper.GetStringSlice("http.internal.ipRanges")))
+
+	router.GET("healthz", pkgs.health.Liveness)
+	router.GET("readyz", pkgs.health.Readiness)
+
+	pkgs.prom.Use(router)
+	ginpprof.Register(router)
+
+	// GET返回当前级别，PUT {"level":"debug"}修改级别
+	router.GET("loglevel", gin.WrapH(atom))
+	router.PUT("loglevel", gin.WrapH(atom))
+
+	router.GET("buildinfo", func(c *gin.Context) {
+		info := gin.H{
+			"version":   version,
+			"date":      date,
+			"goVersion": runtime.Version(),
+		}
+		if bi, ok := debug.ReadBuildInfo(); ok {
+			for _, s := range bi.Settings {
+				switch s.Key {
+				case "vcs.revision", "vcs.time", "vcs.modified":
+					info[s.Key] = s.Value
+				}
+			}
+		}
+		c.JSON(http.StatusOK, &resp.Response{Result: info})
+	})
+
+	router.GET("goroutines", func(c *gin.Context) {
+		c.Header("Content-Type", "text/plain; charset=utf-8")
+		c.Status(http.StatusOK)
+		_ = pprof.Lookup("goroutine").WriteTo(c.Writer, 2)
+	})
+
+	router.GET("config", func(c *gin.Context) {
+		c.JSON(http.StatusOK, &resp.Response{Result: config.Redact(viper.AllSettings())})
+	})
+
+	return router
+}
+
+// registerAdminRoutes 管理接口（统计、webhook订阅，返回签名密钥），只挂在内部管理服务上
+func registerAdminRoutes(router *gin.Engine, ctrls *GinControllers) error {
+	uni, err := validator.GetUniversalTranslator()
+	if err != nil {
+		return err
+	}
+	eh := middlewares.NewWithStatusHandler(uni)
+
+	admin := router.Group("admin")
+	admin.Use(eh.HandleErrors)
+	admin.Use(middlewares.Logger(zap.S()))
+	admin.Use(middlewares.NewPaginationMiddleware(
+		middlewares.PaginationCursorKey(paginationCursorKey()),
+	))
+	admin.Use(middlewares.NewI18nMiddleware())
+
+	admin.GET("stats", ctrls.statsCtrl.Series)
+	admin.POST("webhooks", ctrls.webhookCtrl.Create)
+	admin.GET("webhooks", ctrls.webhookCtrl.List)
+	admin.GET("webhooks/:id", ctrls.webhookCtrl.Get)
+	admin.PUT("webhooks/:id", ctrls.webhookCtrl.Update)
+	admin.DELETE("webhooks/:id", ctrls.webhookCtrl.Delete)
+	admin.GET("webhooks/:id/deliveries", ctrls.webhookCtrl.Deliveries)
+	return nil
+}
+
+// newInternalServer 内部管理服务，http.internal.tls.clientCAFile不为空时要求客户端证书；
+// port不为空时覆盖http.internal的监听地址，ctrls不为空时注册管理接口
+func newInternalServer(pkgs *Packages, ctrls *GinControllers, port string) (*httpserver.Server, error) {
+	cfg, err := loadHTTPConfig("http.internal", map[string]interface{}{
+		"port": "8010",
+		// pprof profile默认采集30s
+		"writeTimeout": "90s",
+	})
+	if err != nil {
+		return nil, err
+	}
+	if port != "" {
+		cfg.Network, cfg.Address, cfg.Port = "tcp", "", port
+	}
+	router := GetGinAdminEngine(pkgs)
+	if ctrls != nil {
+		if err := registerAdminRoutes(router, ctrls); err != nil {
+			return nil, err
+		}
+	}
+	return httpserver.New("internal", cfg, router)
+}
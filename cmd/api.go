@@ -2,38 +2,25 @@ package cmd
 
 import (
 	"context"
-	"net/http"
+	"crypto/hmac"
+	"crypto/sha256"
 	"os"
-	"os/signal"
-	"sync"
-	"syscall"
 	"time"
 
 	"lovebox/models"
 	"lovebox/pkg/gin/middlewares"
+	"lovebox/pkg/httpcache"
+	"lovebox/pkg/httpserver"
 	"lovebox/pkg/validator"
 
-	"github.com/gin-contrib/gzip"
-	"github.com/gin-contrib/pprof"
 	"github.com/gin-gonic/gin"
-	cors "github.com/rs/cors/wrapper/gin"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"go.uber.org/zap"
-	"golang.org/x/sync/errgroup"
 	_ "google.golang.org/grpc/encoding/gzip" // Install the gzip compressor
 )
 
-type serverFlags struct {
-	port string
-}
-
-var sFlags serverFlags
-
 func init() {
-	serverCmd.Flags().StringVar(&sFlags.port, "port", "8008", "listen port")
-	_ = viper.BindPFlag("port", serverCmd.Flags().Lookup("port"))
-
 	rootCmd.AddCommand(serverCmd)
 }
 
@@ -52,89 +39,143 @@ var serverCmd = &cobra.Command{
 			&models.Account{},
 			&models.AccountExtraInfo{},
 			&models.OperateLogs{},
+			&models.OutboxMessage{},
+			&models.WebhookEndpoint{},
+			&models.WebhookDelivery{},
 		); err != nil {
 			log.Fatalf("Mysql AutoMigrate Error: %v", err)
 		}
 
-		var httpPublicServer *http.Server
-
-		var eg errgroup.Group
-		eg.Go(func() error {
-			engine, err := GetGinPublicEngine(ginCtrls, pkgs)
-			if err != nil {
-				return err
-			}
-
-			port := ":" + viper.GetString("http.public.port")
-			log.Infof("HTTP public server listen on %s", port)
-			httpPublicServer = &http.Server{
-				Addr:    port,
-				Handler: engine,
+		// 创建clickhouse分析表
+		if pkgs.analytics != nil {
+			if err := pkgs.analytics.Migrate(
+				&models.RequestLog{},
+				&models.LoginEvent{},
+				&models.OperateLogEvent{},
+				&models.LogRecord{},
+			); err != nil {
+				log.Fatalf("Clickhouse Migrate Error: %v", err)
 			}
+		}
 
-			return httpPublicServer.ListenAndServe()
-		})
+		engine, err := GetGinPublicEngine(ginCtrls, pkgs)
+		if err != nil {
+			log.Fatalf("Init gin engine err %v", err)
+		}
 
-		eg.Go(func() error {
-			signalChan := make(chan os.Signal, 1)
-			signal.Notify(signalChan, syscall.SIGINT, syscall.SIGTERM)
-			sig := <-signalChan
+		viper.SetDefault("shutdown.timeout", "10s")
+		viper.SetDefault("shutdown.drainTimeout", "15s")
+		lc := NewLifecycle(viper.GetDuration("shutdown.timeout"))
+		registerPackageHooks(lc, pkgs)
 
-			log.Warnf("System about to exit because of signal=%s", sig.String())
+		internalServer, err := newInternalServer(pkgs, ginCtrls, "")
+		if err != nil {
+			log.Fatalf("Init internal server err %v", err)
+		}
+		publicCfg, err := loadHTTPConfig("http.public", map[string]interface{}{
+			"port": "8080",
+		})
+		if err != nil {
+			log.Fatalf("Load http.public config err %v", err)
+		}
+		publicServer, err := httpserver.New("public", publicCfg, engine)
+		if err != nil {
+			log.Fatalf("Init public server err %v", err)
+		}
 
-			var wg sync.WaitGroup
-			servers := []*http.Server{
-				httpPublicServer,
+		drainTimeout := viper.GetDuration("shutdown.drainTimeout")
+		// 内部服务最后停止，停止过程中readyz仍可访问
+		lc.AppendServer(internalServer, drainTimeout)
+		// 处理完进行中的请求后才关闭数据库等依赖
+		publicDeps := []string{"internal", "eventbus", "operateLogger", "mysql", "redis", "mongodb", "analytics", "influxdb", "tracing"}
+		lc.AppendServer(publicServer, drainTimeout, publicDeps...)
+		// 可选的unix socket，供同机的反向代理使用，与public使用相同的路由；
+		// 客户端地址来自http.unix.clientIPHeader
+		listeners := []string{"public"}
+		if viper.GetBool("http.unix.enable") {
+			unixCfg, err := loadHTTPConfig("http.unix", map[string]interface{}{
+				"address": "/var/run/lovebox.sock",
+			})
+			if err != nil {
+				log.Fatalf("Load http.unix config err %v", err)
 			}
-
-			for _, svr := range servers {
-				if svr == nil {
-					continue
-				}
-
-				svr := svr
-				wg.Add(1)
-
-				go func() {
-					ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-					defer cancel()
-					if err := svr.Shutdown(ctx); err != nil {
-						log.Errorf("Api server shutdown err=%v", err)
-					}
-					wg.Done()
-				}()
+			unixCfg.Network = "unix"
+			unixServer, err := httpserver.New("unix", unixCfg, engine)
+			if err != nil {
+				log.Fatalf("Init unix server err %v", err)
 			}
+			lc.AppendServer(unixServer, drainTimeout, publicDeps...)
+			listeners = append(listeners, "unix")
+		}
 
-			wg.Wait()
-
-			return nil
+		// readiness先失败，等待负载均衡摘除实例后再关闭服务
+		shutdownDelay := viper.GetDuration("health.shutdownDelay")
+		lc.Append(Hook{
+			Name:      "health",
+			DependsOn: listeners,
+			OnStop: func(ctx context.Context) error {
+				pkgs.health.SetShuttingDown()
+				time.Sleep(shutdownDelay)
+				return nil
+			},
+			StopTimeout: shutdownDelay + time.Second,
 		})
 
-		if err := eg.Wait(); err != nil {
-			log.Fatalf("Server err %v", err)
+		if err := lc.Run(); err != nil {
+			log.Errorf("Server err %v", err)
+			os.Exit(1)
 		}
 	},
 }
 
+// paginationCursorKey 游标签名密钥，未配置pagination.cursorKey时由jwt.key派生，
+// 避免游标签名与jwt签名使用同一密钥
+func paginationCursorKey() []byte {
+	if key := viper.GetString("pagination.cursorKey"); key != "" {
+		return []byte(key)
+	}
+	mac := hmac.New(sha256.New, []byte(viper.GetString("jwt.key")))
+	mac.Write([]byte("pagination-cursor"))
+	return mac.Sum(nil)
+}
+
 func GetGinPublicEngine(ctrls *GinControllers, pkgs *Packages) (*gin.Engine, error) {
-	if os.Getenv("GO_ENV") == "production" {
+	production := os.Getenv("GO_ENV") == "production"
+	if production {
 		gin.SetMode(gin.ReleaseMode)
 	}
+	// 跨域与安全响应头按环境预设，http.preset可覆盖
+	if preset := viper.GetString("http.preset"); preset != "" {
+		production = preset == "production"
+	}
+	corsOpts, err := middlewares.CORSOptionsFromViper(viper.Sub("http.cors"), production)
+	if err != nil {
+		return nil, err
+	}
+	secureCfg, err := middlewares.SecureConfigFromViper(viper.Sub("http.secure"), production)
+	if err != nil {
+		return nil, err
+	}
 
 	router := gin.New()
 	_ = router.SetTrustedProxies(viper.GetStringSlice("http.trustedProxies"))
 
 	router.Use(gin.Recovery())
-	router.Use(cors.AllowAll())
-	router.Use(middlewares.HTTPGzipEncoding)
+	router.Use(middlewares.RequestID())
+	router.Use(middlewares.NewCORS(corsOpts))
+	router.Use(middlewares.Secure(secureCfg))
+	router.Use(middlewares.Decompress(
+		middlewares.DecompressMaxSize(viper.GetInt64("http.decompress.maxSize")),
+		middlewares.DecompressMaxRatio(viper.GetInt64("http.decompress.maxRatio")),
+	))
 
 	api := router.Group("/api/v1")
-	pkgs.prom.Use(router)
 	api.Use(pkgs.prom.Instrument("public"))
-	pprof.RouteRegister(api)
 
-	gzipExcludePaths := []string{}
-	api.Use(gzip.Gzip(gzip.DefaultCompression, gzip.WithExcludedPaths(gzipExcludePaths)))
+	api.Use(middlewares.Compress(
+		middlewares.CompressMinLength(viper.GetInt("http.compress.minLength")),
+		middlewares.CompressExcludedPaths(viper.GetStringSlice("http.compress.excludedPaths")...),
+	))
 
 	uni, err := validator.GetUniversalTranslator()
 	if err != nil {
@@ -144,16 +185,40 @@ func GetGinPublicEngine(ctrls *GinControllers, pkgs *Packages) (*gin.Engine, err
 	api.Use(eh.HandleErrors)
 
 	api.Use(middlewares.Logger(zap.S()))
-	api.Use(middlewares.NewPaginationMiddleware())
+	api.Use(middlewares.NewPaginationMiddleware(
+		middlewares.PaginationCursorKey(paginationCursorKey()),
+	))
 	api.Use(middlewares.NewI18nMiddleware())
 	api.Use(middlewares.Tracing(middlewares.TracingComponentName("gin")))
-	api.Use(middlewares.NewOperateLogger(zap.S(), pkgs.mysqlClient))
+	api.Use(pkgs.operateLogger.Middleware())
+	if pkgs.analytics != nil {
+		api.Use(middlewares.NewRequestRecorder(pkgs.analytics))
+	}
+
+	viper.SetDefault("httpcache.info.ttl", "5m")
+	viper.SetDefault("idempotency.ttl", "24h")
+	viper.SetDefault("idempotency.lockTimeout", "30s")
+	idempotency := middlewares.NewIdempotency(
+		pkgs.redisClient,
+		pkgs.redSyncClient,
+		middlewares.IdempotencyTTL(viper.GetDuration("idempotency.ttl")),
+		middlewares.IdempotencyLockTimeout(viper.GetDuration("idempotency.lockTimeout")),
+	)
 
 	api.GET("captcha", ctrls.accountCtrl.GetCaptcha)
 	api.POST("login", ctrls.accountCtrl.Login)
-	api.POST("register", ctrls.accountCtrl.Register)
+	api.POST("register", idempotency, ctrls.accountCtrl.Register)
 	api.Use(middlewares.NewJwtCheckMiddleware(pkgs.jwt, pkgs.mysqlClient, pkgs.cacheClient))
-	api.GET("info", ctrls.accountCtrl.Info)
+	// 登录后的接口按账号区分Idempotency-Key
+	api.Use(idempotency)
+	api.GET("info",
+		pkgs.httpCache.Handle(
+			httpcache.TTL(viper.GetDuration("httpcache.info.ttl")),
+			httpcache.KeyBy(httpcache.KeyAccount|httpcache.KeyLanguage),
+			httpcache.Tags("account:{account}"),
+		),
+		ctrls.accountCtrl.Info,
+	)
 
 	// base := api.Group("")
 
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"lovebox/pkg/rabbitmq"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+type dlqFlags struct {
+	queue   string
+	limit   int
+	timeout time.Duration
+}
+
+var dFlags dlqFlags
+
+func init() {
+	dlqCmd.PersistentFlags().StringVar(&dFlags.queue, "queue", "", "consumer queue name (without .dlq suffix)")
+	dlqCmd.PersistentFlags().IntVar(&dFlags.limit, "limit", 10, "max messages")
+	dlqCmd.PersistentFlags().DurationVar(&dFlags.timeout, "timeout", time.Minute, "command timeout")
+	_ = dlqCmd.MarkPersistentFlagRequired("queue")
+
+	dlqCmd.AddCommand(dlqListCmd, dlqReplayCmd)
+	rootCmd.AddCommand(dlqCmd)
+}
+
+var dlqCmd = &cobra.Command{
+	Use:   "dlq",
+	Short: "Inspect and replay rabbitmq dead-lettered messages",
+}
+
+var dlqListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List dead-lettered messages without removing them",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, cancel := context.WithTimeout(context.Background(), dFlags.timeout)
+		defer cancel()
+
+		letters, err := rabbitmq.InspectDeadLetters(ctx, viper.GetString("rabbitmq.address"), dFlags.queue, dFlags.limit)
+		if err != nil {
+			return err
+		}
+		for _, letter := range letters {
+			buf, err := json.Marshal(letter)
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(buf))
+		}
+		return nil
+	},
+}
+
+var dlqReplayCmd = &cobra.Command{
+	Use:   "replay",
+	Short: "Republish dead-lettered messages to their original queue",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, cancel := context.WithTimeout(context.Background(), dFlags.timeout)
+		defer cancel()
+
+		n, err := rabbitmq.ReplayDeadLetters(ctx, viper.GetString("rabbitmq.address"), dFlags.queue, dFlags.limit)
+		fmt.Printf("replayed %d messages\n", n)
+		return err
+	},
+}
@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+type healthcheckFlags struct {
+	url      string
+	liveness bool
+	timeout  time.Duration
+}
+
+var hcFlags healthcheckFlags
+
+func init() {
+	healthcheckCmd.Flags().StringVar(&hcFlags.url, "url", "", "health endpoint url, defaults to the internal server on localhost")
+	healthcheckCmd.Flags().BoolVar(&hcFlags.liveness, "liveness", false, "check liveness (/healthz) instead of readiness (/readyz)")
+	healthcheckCmd.Flags().DurationVar(&hcFlags.timeout, "timeout", 5*time.Second, "request timeout")
+
+	rootCmd.AddCommand(healthcheckCmd)
+}
+
+// healthcheckCmd 用于容器探针，检查失败时以非0状态码退出
+var healthcheckCmd = &cobra.Command{
+	Use:   "healthcheck",
+	Short: "Check health of a running instance",
+	Run: func(cmd *cobra.Command, args []string) {
+		url := hcFlags.url
+		if url == "" {
+			viper.SetDefault("http.internal.port", "8010")
+			path := "/readyz"
+			if hcFlags.liveness {
+				path = "/healthz"
+			}
+			url = "http://127.0.0.1:" + viper.GetString("http.internal.port") + path
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), hcFlags.timeout)
+		defer cancel()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		defer res.Body.Close()
+
+		body, _ := io.ReadAll(res.Body)
+		fmt.Println(string(body))
+		if res.StatusCode != http.StatusOK {
+			os.Exit(1)
+		}
+	},
+}
@@ -1,12 +1,31 @@
 package cmd
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"lovebox/models"
+	"lovebox/pkg/analytics"
 	"lovebox/pkg/database"
+	"lovebox/pkg/eventbus"
 	"lovebox/pkg/gin/middlewares"
+	"lovebox/pkg/health"
+	"lovebox/pkg/httpcache"
+	"lovebox/pkg/httpserver"
+	"lovebox/pkg/influxdb"
+	"lovebox/pkg/jobqueue"
 	"lovebox/pkg/jwt"
+	zaplog "lovebox/pkg/logger"
+	"lovebox/pkg/mongodb"
+	"lovebox/pkg/rabbitmq"
 	"lovebox/pkg/tracing"
 	"lovebox/services/account"
+	"lovebox/services/outbox"
+	"lovebox/services/stats"
 	"lovebox/services/system"
+	"lovebox/services/webhook"
 
 	redisCache "github.com/go-redis/cache/v8"
 	redislib "github.com/go-redis/redis/v8"
@@ -19,10 +38,21 @@ import (
 
 type Packages struct {
 	mysqlClient   *database.Client
+	analytics     *analytics.Client
+	mongoClient   *mongodb.Client
+	influxClient  *influxdb.Client
+	influxWriter  *influxdb.BatchWriter
 	redisClient   *redislib.Client
 	cacheClient   *redisCache.Cache
+	httpCache     *httpcache.Cache
 	redSyncClient *redsync.Redsync
+	jobQueue      *jobqueue.Queue
+	eventBus      *eventbus.Bus
+	eventForward  *rabbitmq.Client
+	logPublisher  *rabbitmq.Client
 	prom          *middlewares.Prometheus
+	operateLogger *middlewares.OperateLogger
+	health        *health.Registry
 	tracing       *tracing.TracingService
 	jwt           *jwt.Jwt
 }
@@ -33,6 +63,9 @@ func NewPackages() (pkgs *Packages) {
 	}
 	log := zap.S().With("module", "init")
 
+	viper.SetDefault("health.timeout", "2s")
+	pkgs.health = health.New(health.WithTimeout(viper.GetDuration("health.timeout")))
+
 	{
 		pkgs.tracing = tracing.NewTracingService(
 			viper.GetBool("tracing.ext.logging.enable"),
@@ -58,7 +91,155 @@ func NewPackages() (pkgs *Packages) {
 			log.Errorf("Init mysql error %v", err)
 			panic(err)
 		}
+		viper.SetDefault("mysql.txMaxRetries", 3)
+		viper.SetDefault("mysql.txRetryBackoff", "50ms")
+		mysqlClient.SetTxRetry(
+			viper.GetInt("mysql.txMaxRetries"),
+			viper.GetDuration("mysql.txRetryBackoff"),
+		)
+		viper.SetDefault("mysql.slowThreshold", "1s")
+		err = mysqlClient.Instrument("mysql", viper.GetDuration("mysql.slowThreshold"))
+		if err != nil {
+			log.Errorf("Instrument mysql error %v", err)
+			panic(err)
+		}
 		pkgs.mysqlClient = mysqlClient
+		pkgs.health.Register("mysql", mysqlClient.Ping)
+	}
+
+	if viper.GetBool("clickhouse.enable") {
+		viper.SetDefault("clickhouse.logLevel", logger.Warn)
+		viper.SetDefault("clickhouse.maxIdleConns", 5)
+		viper.SetDefault("clickhouse.maxOpenConns", 10)
+		viper.SetDefault("clickhouse.connMaxLifetime", "1h")
+		viper.SetDefault("clickhouse.slowThreshold", "5s")
+		viper.SetDefault("clickhouse.bufferSize", 10000)
+		viper.SetDefault("clickhouse.batchSize", 1000)
+		viper.SetDefault("clickhouse.flushInterval", "5s")
+		clickhouseClient, err := database.NewClickHouse(
+			viper.GetString("clickhouse.addr"),
+			viper.GetInt("clickhouse.maxIdleConns"),
+			viper.GetInt("clickhouse.maxOpenConns"),
+			viper.GetDuration("clickhouse.connMaxLifetime"),
+			logger.LogLevel(viper.GetUint("clickhouse.logLevel")),
+		)
+		if err != nil {
+			log.Errorf("Init clickhouse error %v", err)
+			panic(err)
+		}
+		err = clickhouseClient.Instrument("clickhouse", viper.GetDuration("clickhouse.slowThreshold"))
+		if err != nil {
+			log.Errorf("Instrument clickhouse error %v", err)
+			panic(err)
+		}
+		pkgs.analytics = analytics.New(
+			clickhouseClient,
+			viper.GetInt("clickhouse.bufferSize"),
+			viper.GetInt("clickhouse.batchSize"),
+			viper.GetDuration("clickhouse.flushInterval"),
+		)
+		// 分析数据为尽力写入，不影响readiness
+		pkgs.health.Register("clickhouse", clickhouseClient.Ping, health.Optional())
+	}
+
+	if viper.GetBool("mongodb.enable") {
+		viper.SetDefault("mongodb.timeout", "10s")
+		viper.SetDefault("mongodb.maxPoolSize", 100)
+		mongoClient, err := mongodb.NewMongo(
+			viper.GetString("mongodb.uri"),
+			viper.GetString("mongodb.database"),
+			viper.GetDuration("mongodb.timeout"),
+			viper.GetUint64("mongodb.maxPoolSize"),
+		)
+		if err != nil {
+			log.Errorf("Init mongodb error %v", err)
+			panic(err)
+		}
+		pkgs.mongoClient = mongoClient
+		pkgs.health.Register("mongodb", mongoClient.Ping)
+	}
+
+	if viper.GetBool("influxdb.enable") {
+		viper.SetDefault("influxdb.bufferSize", 10000)
+		viper.SetDefault("influxdb.batchSize", 500)
+		viper.SetDefault("influxdb.flushInterval", "5s")
+		viper.SetDefault("influxdb.maxRetries", 3)
+		viper.SetDefault("influxdb.retryInterval", "1s")
+		pkgs.influxClient = influxdb.New(
+			viper.GetString("influxdb.url"),
+			viper.GetString("influxdb.token"),
+			viper.GetString("influxdb.org"),
+			viper.GetString("influxdb.bucket"),
+		)
+		pkgs.influxWriter = pkgs.influxClient.NewBatchWriter(
+			viper.GetInt("influxdb.bufferSize"),
+			viper.GetInt("influxdb.batchSize"),
+			viper.GetDuration("influxdb.flushInterval"),
+			viper.GetInt("influxdb.maxRetries"),
+			viper.GetDuration("influxdb.retryInterval"),
+		)
+		pkgs.health.Register("influxdb", pkgs.influxClient.Ping, health.Optional())
+	}
+
+	{
+		viper.SetDefault("eventbus.workers", 4)
+		viper.SetDefault("eventbus.bufferSize", 1000)
+		pkgs.eventBus = eventbus.New(
+			eventbus.WithWorkers(viper.GetInt("eventbus.workers")),
+			eventbus.WithBufferSize(viper.GetInt("eventbus.bufferSize")),
+		)
+
+		// 转发到rabbitmq的事件，为空时不转发
+		forward := viper.GetStringSlice("eventbus.forward")
+		if len(forward) > 0 {
+			viper.SetDefault("eventbus.exchange", "lovebox.events")
+			exchange := viper.GetString("eventbus.exchange")
+			pkgs.eventForward = rabbitmq.New(
+				viper.GetString("rabbitmq.address"),
+				"topic",
+				"",
+				exchange,
+				"",
+				"application/json",
+				nil,
+				rabbitmq.WithName("eventbus"),
+			)
+			eventbus.ForwardToRabbitMQ(pkgs.eventBus, pkgs.eventForward, exchange, forward...)
+			pkgs.health.Register("rabbitmq.eventbus", pkgs.eventForward.Ping, health.Optional())
+		}
+	}
+
+	// 日志异步发送到rabbitmq或clickhouse
+	if viper.GetBool("log.sink.enable") {
+		switch sinkType := viper.GetString("log.sink.type"); sinkType {
+		case "rabbitmq":
+			viper.SetDefault("log.sink.exchange", "lovebox.logs")
+			viper.SetDefault("log.sink.routingKey", "logs")
+			exchange := viper.GetString("log.sink.exchange")
+			pkgs.logPublisher = rabbitmq.New(
+				viper.GetString("rabbitmq.address"),
+				"topic",
+				"",
+				exchange,
+				"",
+				"application/x-ndjson",
+				nil,
+				rabbitmq.WithName("logger"),
+			)
+			pkgs.health.Register("rabbitmq.logger", pkgs.logPublisher.Ping, health.Optional())
+			zaplog.SetSink(zaplog.NewRabbitMQSink(
+				pkgs.logPublisher,
+				exchange,
+				viper.GetString("log.sink.routingKey"),
+			))
+		case "clickhouse":
+			if pkgs.analytics == nil {
+				panic("log sink clickhouse requires clickhouse.enable")
+			}
+			zaplog.SetSink(newClickHouseLogSink(pkgs.analytics))
+		default:
+			panic(fmt.Sprintf("unknown log sink type %q", sinkType))
+		}
 	}
 
 	{
@@ -84,14 +265,118 @@ func NewPackages() (pkgs *Packages) {
 			Redis: rdb,
 		})
 		pkgs.redSyncClient = redsync.New(goredis.NewPool(pkgs.redisClient))
+		pkgs.httpCache = httpcache.New(pkgs.cacheClient, rdb)
+		pkgs.health.Register("redis", func(ctx context.Context) error {
+			return rdb.Ping(ctx).Err()
+		})
 	}
 
+	{
+		viper.SetDefault("jobqueue.prefix", "jobqueue")
+		viper.SetDefault("jobqueue.visibilityTimeout", "5m")
+		viper.SetDefault("jobqueue.pollInterval", "1s")
+		viper.SetDefault("jobqueue.maxRetries", 3)
+		pkgs.jobQueue = jobqueue.New(
+			pkgs.redisClient,
+			viper.GetString("jobqueue.prefix"),
+			jobqueue.WithVisibilityTimeout(viper.GetDuration("jobqueue.visibilityTimeout")),
+			jobqueue.WithPollInterval(viper.GetDuration("jobqueue.pollInterval")),
+			jobqueue.WithMaxRetries(viper.GetInt("jobqueue.maxRetries")),
+		)
+	}
+
+	pkgs.operateLogger = middlewares.NewOperateLogger(zap.S(), pkgs.mysqlClient, pkgs.analytics)
+
 	return
 }
 
+// registerPackageHooks 注册各依赖的停止顺序：使用方先于被使用方停止
+func registerPackageHooks(lc *Lifecycle, pkgs *Packages) {
+	lc.Append(Hook{
+		Name:   "tracing",
+		OnStop: pkgs.tracing.Shutdown,
+	})
+	if pkgs.logPublisher != nil {
+		lc.Append(Hook{
+			Name:   "rabbitmq.logger",
+			OnStop: pkgs.logPublisher.Shutdown,
+		})
+	}
+	if pkgs.analytics != nil {
+		lc.Append(Hook{
+			Name: "analytics",
+			OnStop: func(ctx context.Context) error {
+				if err := pkgs.analytics.Shutdown(ctx); err != nil {
+					return err
+				}
+				return pkgs.analytics.Db().Close()
+			},
+		})
+	}
+	// 日志sink依赖rabbitmq与analytics，先发送剩余日志
+	lc.Append(Hook{
+		Name:      "logger",
+		DependsOn: []string{"rabbitmq.logger", "analytics"},
+		OnStop:    zaplog.Shutdown,
+	})
+	lc.Append(Hook{
+		Name: "mysql",
+		OnStop: func(context.Context) error {
+			return pkgs.mysqlClient.Close()
+		},
+	})
+	lc.Append(Hook{
+		Name: "redis",
+		OnStop: func(context.Context) error {
+			return pkgs.redisClient.Close()
+		},
+	})
+	if pkgs.mongoClient != nil {
+		// 创建已注册仓库的索引，依赖mongodb的服务启动前完成
+		lc.Append(Hook{
+			Name: "mongodb",
+			OnStart: func(ctx context.Context) error {
+				ctx, cancel := context.WithTimeout(ctx, time.Minute)
+				defer cancel()
+				return pkgs.mongoClient.EnsureIndexes(ctx)
+			},
+			OnStop: pkgs.mongoClient.Disconnect,
+		})
+	}
+	if pkgs.influxWriter != nil {
+		lc.Append(Hook{
+			Name: "influxdb",
+			OnStop: func(ctx context.Context) error {
+				defer pkgs.influxClient.Close()
+				return pkgs.influxWriter.Shutdown(ctx)
+			},
+		})
+	}
+	if pkgs.eventForward != nil {
+		lc.Append(Hook{
+			Name:   "rabbitmq.eventbus",
+			OnStop: pkgs.eventForward.Shutdown,
+		})
+	}
+	// 先处理完异步事件，再关闭订阅者依赖的客户端
+	lc.Append(Hook{
+		Name:      "eventbus",
+		DependsOn: []string{"mysql", "redis", "analytics", "influxdb", "rabbitmq.eventbus"},
+		OnStop:    pkgs.eventBus.Shutdown,
+	})
+	lc.Append(Hook{
+		Name:      "operateLogger",
+		DependsOn: []string{"mysql", "analytics"},
+		OnStop:    pkgs.operateLogger.Shutdown,
+	})
+}
+
 type Services struct {
 	accountSvc *account.Service
 	systemSvc  *system.Service
+	statsSvc   *stats.Service
+	outboxSvc  *outbox.Service
+	webhookSvc *webhook.Service
 }
 
 func NewServices(pkgs *Packages) *Services {
@@ -99,22 +384,55 @@ func NewServices(pkgs *Packages) *Services {
 		pkgs.mysqlClient,
 	)
 
+	statsSvc := stats.NewService(
+		pkgs.influxClient,
+		pkgs.influxWriter,
+	)
+
+	outboxSvc := outbox.NewService(
+		pkgs.mysqlClient,
+	)
+
 	accountSvc := account.NewService(
 		pkgs.mysqlClient,
 		pkgs.redisClient,
 		pkgs.cacheClient,
+		pkgs.httpCache,
+		pkgs.analytics,
+		statsSvc,
+		outboxSvc,
+		pkgs.eventBus,
 		pkgs.jwt,
 	)
 
+	viper.SetDefault("webhook.timeout", "10s")
+	viper.SetDefault("webhook.maxRetries", 8)
+	viper.SetDefault("webhook.disableThreshold", 50)
+	webhookSvc := webhook.NewService(
+		pkgs.mysqlClient,
+		pkgs.jobQueue,
+		viper.GetDuration("webhook.timeout"),
+		viper.GetInt("webhook.maxRetries"),
+		viper.GetUint("webhook.disableThreshold"),
+	)
+
+	accountSvc.Subscribe(pkgs.eventBus)
+	statsSvc.Subscribe(pkgs.eventBus)
+
 	return &Services{
 		accountSvc: accountSvc,
 		systemSvc:  systemSvc,
+		statsSvc:   statsSvc,
+		outboxSvc:  outboxSvc,
+		webhookSvc: webhookSvc,
 	}
 }
 
 type GinControllers struct {
 	accountCtrl *account.GinController
 	systemCtrl  *system.GinController
+	statsCtrl   *stats.GinController
+	webhookCtrl *webhook.GinController
 }
 
 func NewGinControllers(pkgs *Packages, svcs *Services) *GinControllers {
@@ -126,5 +444,48 @@ func NewGinControllers(pkgs *Packages, svcs *Services) *GinControllers {
 		systemCtrl: system.NewGinController(
 			svcs.systemSvc,
 		),
+		statsCtrl: stats.NewGinController(
+			svcs.statsSvc,
+		),
+		webhookCtrl: webhook.NewGinController(
+			svcs.webhookSvc,
+		),
+	}
+}
+
+// newClickHouseLogSink 日志写入clickhouse的logs表，由analytics批量写入
+func newClickHouseLogSink(client *analytics.Client) zaplog.Sink {
+	return zaplog.SinkFunc(func(ctx context.Context, entries []*zaplog.Entry) error {
+		for _, entry := range entries {
+			record := &models.LogRecord{
+				Time:      entry.Time,
+				Level:     entry.Level,
+				Module:    entry.Module,
+				Message:   entry.Message,
+				RequestID: entry.RequestID,
+				TraceID:   entry.TraceID,
+			}
+			if len(entry.Fields) > 0 {
+				buf, err := json.Marshal(entry.Fields)
+				if err != nil {
+					return err
+				}
+				record.Fields = string(buf)
+			}
+			client.Record(record)
+		}
+		return nil
+	})
+}
+
+// loadHTTPConfig 读取http.public、http.internal等监听配置，defaults为该监听的默认值
+func loadHTTPConfig(key string, defaults map[string]interface{}) (*httpserver.Config, error) {
+	cfg := viper.Sub(key)
+	if cfg == nil {
+		cfg = viper.New()
+	}
+	for k, v := range defaults {
+		cfg.SetDefault(k, v)
 	}
+	return httpserver.ConfigFromViper(cfg)
 }
@@ -0,0 +1,240 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"lovebox/pkg/httpserver"
+
+	"go.uber.org/zap"
+)
+
+// Hook 组件的启动与停止。启动按依赖顺序执行，停止按相反顺序执行：
+// 组件停止后才会停止它依赖的组件；未注册的依赖（如未启用的mongodb）会被忽略
+type Hook struct {
+	Name      string
+	DependsOn []string
+	OnStart   func(ctx context.Context) error
+	OnStop    func(ctx context.Context) error
+	// StopTimeout 为0时使用Lifecycle的默认值
+	StopTimeout time.Duration
+}
+
+// Lifecycle 管理组件的启动、停止与退出信号
+type Lifecycle struct {
+	log         *zap.SugaredLogger
+	stopTimeout time.Duration
+
+	hooks   []*Hook
+	started []*Hook
+	errs    chan error
+}
+
+func NewLifecycle(stopTimeout time.Duration) *Lifecycle {
+	return &Lifecycle{
+		log:         zap.S().With("module", "lifecycle"),
+		stopTimeout: stopTimeout,
+		errs:        make(chan error, 1),
+	}
+}
+
+// Append 注册组件，同名组件只保留第一个
+func (l *Lifecycle) Append(h Hook) {
+	for _, exist := range l.hooks {
+		if exist.Name == h.Name {
+			l.log.Warnf("Duplicate lifecycle hook %s ignored", h.Name)
+			return
+		}
+	}
+	l.hooks = append(l.hooks, &h)
+}
+
+// AppendServer 注册http服务，启动时同步监听，停止时等待处理中的请求
+func (l *Lifecycle) AppendServer(srv *httpserver.Server, drainTimeout time.Duration, dependsOn ...string) {
+	l.Append(Hook{
+		Name:      srv.Name(),
+		DependsOn: dependsOn,
+		OnStart: func(ctx context.Context) error {
+			ln, err := srv.Listen()
+			if err != nil {
+				return err
+			}
+			go func() {
+				if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+					l.Fail(fmt.Errorf("%s: %w", srv.Name(), err))
+				}
+			}()
+			return nil
+		},
+		OnStop:      srv.Shutdown,
+		StopTimeout: drainTimeout,
+	})
+}
+
+// AppendRunner 注册阻塞运行直到ctx结束的组件，停止时取消ctx并等待run返回
+func (l *Lifecycle) AppendRunner(name string, run func(ctx context.Context), dependsOn ...string) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	l.Append(Hook{
+		Name:      name,
+		DependsOn: dependsOn,
+		OnStart: func(context.Context) error {
+			go func() {
+				defer close(done)
+				run(ctx)
+			}()
+			return nil
+		},
+		OnStop: func(stopCtx context.Context) error {
+			cancel()
+			select {
+			case <-done:
+				return nil
+			case <-stopCtx.Done():
+				return stopCtx.Err()
+			}
+		},
+	})
+}
+
+// Fail 组件运行出错，触发退出
+func (l *Lifecycle) Fail(err error) {
+	select {
+	case l.errs <- err:
+	default:
+	}
+}
+
+// Start 按依赖顺序启动组件，失败时已启动的组件需调用Stop停止
+func (l *Lifecycle) Start(ctx context.Context) error {
+	hooks, err := l.order()
+	if err != nil {
+		return err
+	}
+	for _, h := range hooks {
+		if h.OnStart != nil {
+			if err := h.OnStart(ctx); err != nil {
+				return fmt.Errorf("start %s: %w", h.Name, err)
+			}
+		}
+		l.started = append(l.started, h)
+	}
+	return nil
+}
+
+// Stop 按启动的相反顺序停止组件，每个组件有独立的超时，超时后继续停止下一个
+func (l *Lifecycle) Stop(ctx context.Context) error {
+	var errs []string
+	for i := len(l.started) - 1; i >= 0; i-- {
+		h := l.started[i]
+		if h.OnStop == nil {
+			continue
+		}
+
+		timeout := h.StopTimeout
+		if timeout <= 0 {
+			timeout = l.stopTimeout
+		}
+		stopCtx, cancel := context.WithTimeout(ctx, timeout)
+		start := time.Now()
+		err := h.OnStop(stopCtx)
+		cancel()
+		if err != nil {
+			l.log.Errorf("Stop %s err=%v", h.Name, err)
+			errs = append(errs, h.Name+": "+err.Error())
+			continue
+		}
+		l.log.Infof("Stopped %s in %s", h.Name, time.Since(start))
+	}
+	l.started = nil
+
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// Run 启动组件并等待退出信号或组件出错，然后停止组件；
+// 停止过程中再次收到信号时立即退出
+func (l *Lifecycle) Run() error {
+	ctx := context.Background()
+	signalChan := make(chan os.Signal, 2)
+	signal.Notify(signalChan, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signalChan)
+
+	if err := l.Start(ctx); err != nil {
+		l.log.Errorf("Start err=%v", err)
+		_ = l.Stop(ctx)
+		return err
+	}
+
+	var runErr error
+	select {
+	case sig := <-signalChan:
+		l.log.Warnf("About to exit because of signal=%s", sig.String())
+	case runErr = <-l.errs:
+		l.log.Errorf("About to exit because of err=%v", runErr)
+	}
+
+	go func() {
+		sig := <-signalChan
+		l.log.Errorf("Force exit because of second signal=%s", sig.String())
+		os.Exit(1)
+	}()
+
+	if err := l.Stop(ctx); err != nil && runErr == nil {
+		runErr = err
+	}
+	return runErr
+}
+
+// order 按依赖拓扑排序，无依赖关系的组件保持注册顺序
+func (l *Lifecycle) order() ([]*Hook, error) {
+	byName := make(map[string]*Hook, len(l.hooks))
+	for _, h := range l.hooks {
+		byName[h.Name] = h
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int, len(l.hooks))
+	ordered := make([]*Hook, 0, len(l.hooks))
+
+	var visit func(h *Hook, path []string) error
+	visit = func(h *Hook, path []string) error {
+		switch state[h.Name] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("lifecycle dependency cycle: %s", strings.Join(append(path, h.Name), " -> "))
+		}
+		state[h.Name] = visiting
+		for _, dep := range h.DependsOn {
+			if d, ok := byName[dep]; ok {
+				if err := visit(d, append(path, h.Name)); err != nil {
+					return err
+				}
+			}
+		}
+		state[h.Name] = visited
+		ordered = append(ordered, h)
+		return nil
+	}
+
+	for _, h := range l.hooks {
+		if err := visit(h, nil); err != nil {
+			return nil, err
+		}
+	}
+	return ordered, nil
+}
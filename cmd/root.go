@@ -38,6 +38,9 @@ var (
 func init() {
 	cobra.OnInitialize(func() {
 		config.InitConfig("lovebox")
+		if err := logger.Configure(atom, viper.Sub("log")); err != nil {
+			panic(err)
+		}
 	})
 
 	logger.InitLogger(atom)
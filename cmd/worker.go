@@ -0,0 +1,178 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"lovebox/models"
+	"lovebox/pkg/jobqueue"
+	"lovebox/pkg/rabbitmq"
+	"lovebox/services/outbox"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+func init() {
+	rootCmd.AddCommand(workerCmd)
+}
+
+var workerCmd = &cobra.Command{
+	Use:   "worker",
+	Short: "Start background worker",
+	Run: func(cmd *cobra.Command, args []string) {
+		log := zap.S().With("cmd", "worker")
+		pkgs := NewPackages()
+		svcs := NewServices(pkgs)
+
+		if err := pkgs.mysqlClient.AutoMigrate(
+			&models.OutboxMessage{},
+		); err != nil {
+			log.Fatalf("Mysql AutoMigrate Error: %v", err)
+		}
+
+		viper.SetDefault("outbox.exchange", "lovebox.events")
+		viper.SetDefault("outbox.batchSize", 100)
+		viper.SetDefault("outbox.pollInterval", "1s")
+		viper.SetDefault("outbox.retention", "72h")
+		viper.SetDefault("outbox.cleanupInterval", "1h")
+		publisher := rabbitmq.New(
+			viper.GetString("rabbitmq.address"),
+			"topic",
+			"",
+			viper.GetString("outbox.exchange"),
+			"",
+			"application/json",
+			nil,
+			rabbitmq.WithName("outbox"),
+		)
+		pkgs.health.Register("rabbitmq.outbox", publisher.Ping)
+		relay := outbox.NewRelay(
+			pkgs.mysqlClient,
+			pkgs.redSyncClient,
+			publisher,
+			viper.GetString("outbox.exchange"),
+			viper.GetInt("outbox.batchSize"),
+			viper.GetDuration("outbox.pollInterval"),
+			viper.GetDuration("outbox.retention"),
+			viper.GetDuration("outbox.cleanupInterval"),
+		)
+
+		// 消费outbox发布的事件并创建webhook投递，失败时按重试策略重试
+		viper.SetDefault("webhook.queue", "lovebox.webhook")
+		viper.SetDefault("webhook.consumeMaxAttempts", 5)
+		viper.SetDefault("webhook.consumeRetryDelay", "5s")
+		webhookConsumer := rabbitmq.New(
+			viper.GetString("rabbitmq.address"),
+			"topic",
+			viper.GetString("webhook.queue"),
+			viper.GetString("outbox.exchange"),
+			"#",
+			"application/json",
+			svcs.webhookSvc.Consume,
+			rabbitmq.WithName("webhook"),
+			rabbitmq.WithRetryPolicy(rabbitmq.RetryPolicy{
+				MaxAttempts:  viper.GetInt("webhook.consumeMaxAttempts"),
+				InitialDelay: viper.GetDuration("webhook.consumeRetryDelay"),
+			}),
+		)
+		pkgs.health.Register("rabbitmq.webhook", webhookConsumer.Ping)
+
+		registerJobs(pkgs, svcs)
+		scheduler, err := newScheduler(pkgs)
+		if err != nil {
+			log.Fatalf("Init scheduler err %v", err)
+		}
+
+		viper.SetDefault("worker.concurrency", 4)
+		viper.SetDefault("worker.metricsPort", "8009")
+		viper.SetDefault("shutdown.timeout", "10s")
+		viper.SetDefault("shutdown.drainTimeout", "15s")
+		lc := NewLifecycle(viper.GetDuration("shutdown.timeout"))
+		registerPackageHooks(lc, pkgs)
+
+		lc.Append(Hook{
+			Name:   "rabbitmq.outbox",
+			OnStop: publisher.Shutdown,
+		})
+		lc.Append(Hook{
+			Name:      "rabbitmq.webhook",
+			DependsOn: []string{"internal", "mysql", "redis", "tracing"},
+			OnStop:    webhookConsumer.Shutdown,
+		})
+		// 内部管理服务，除端口外与api的http.internal相同
+		internalServer, err := newInternalServer(pkgs, nil, viper.GetString("worker.metricsPort"))
+		if err != nil {
+			log.Fatalf("Init internal server err %v", err)
+		}
+		lc.AppendServer(internalServer, viper.GetDuration("shutdown.timeout"))
+		lc.AppendRunner("outbox", func(ctx context.Context) {
+			log.Infof("Outbox relay started exchange=%s", viper.GetString("outbox.exchange"))
+			relay.Run(ctx)
+		}, "internal", "mysql", "redis", "rabbitmq.outbox", "tracing")
+		lc.AppendRunner("scheduler", scheduler.Run, "internal", "redis")
+		// 停止时等待执行中的任务完成
+		lc.AppendRunner("jobqueue", func(ctx context.Context) {
+			log.Infof("Job queue started concurrency=%d", viper.GetInt("worker.concurrency"))
+			pkgs.jobQueue.Run(ctx, viper.GetInt("worker.concurrency"))
+		}, "internal", "scheduler", "eventbus", "mysql", "redis", "analytics", "influxdb", "tracing")
+		lc.Append(Hook{
+			Name:      "health",
+			DependsOn: []string{"outbox", "jobqueue", "rabbitmq.webhook"},
+			OnStop: func(context.Context) error {
+				pkgs.health.SetShuttingDown()
+				return nil
+			},
+		})
+
+		if err := lc.Run(); err != nil {
+			log.Errorf("Worker err %v", err)
+			os.Exit(1)
+		}
+	},
+}
+
+// registerJobs 注册后台任务
+func registerJobs(pkgs *Packages, svcs *Services) {
+	viper.SetDefault("jobs.operateLogRetention", "2160h")
+
+	pkgs.jobQueue.Register(models.JobRecomputeStats, func(ctx context.Context, job *jobqueue.Job) error {
+		return svcs.accountSvc.RecomputeStats(ctx)
+	})
+	pkgs.jobQueue.Register(models.JobPruneOperateLogs, func(ctx context.Context, job *jobqueue.Job) error {
+		before := time.Now().Add(-viper.GetDuration("jobs.operateLogRetention"))
+		_, err := svcs.systemSvc.PruneOperateLogs(ctx, before)
+		return err
+	})
+	pkgs.jobQueue.Register(models.JobWebhookDeliver, func(ctx context.Context, job *jobqueue.Job) error {
+		params := &models.WebhookDeliverJob{}
+		if err := job.Unmarshal(params); err != nil {
+			return err
+		}
+		return svcs.webhookSvc.Deliver(ctx, params.DeliveryID, job.Attempts >= job.MaxRetries)
+	})
+}
+
+// newScheduler 定时任务，表达式为空时不启用
+func newScheduler(pkgs *Packages) (*jobqueue.Scheduler, error) {
+	viper.SetDefault("schedules.recomputeStats", "*/5 * * * *")
+	viper.SetDefault("schedules.pruneOperateLogs", "0 3 * * *")
+
+	scheduler := jobqueue.NewScheduler(pkgs.jobQueue, pkgs.redSyncClient)
+	schedules := map[string]string{
+		"recomputeStats":   models.JobRecomputeStats,
+		"pruneOperateLogs": models.JobPruneOperateLogs,
+	}
+	for name, jobType := range schedules {
+		spec := viper.GetString("schedules." + name)
+		if spec == "" {
+			continue
+		}
+		if err := scheduler.Add(name, spec, jobType, nil); err != nil {
+			return nil, err
+		}
+	}
+	return scheduler, nil
+}
@@ -0,0 +1,81 @@
+package models
+
+import "time"
+
+const (
+	mergeTreeByTime = "ENGINE=MergeTree() PARTITION BY toYYYYMM(time) ORDER BY (time)"
+)
+
+// RequestLog 请求日志（clickhouse）
+type RequestLog struct {
+	Time      time.Time `gorm:"column:time;type:DateTime" json:"time"`
+	Method    string    `gorm:"column:method;type:LowCardinality(String)" json:"method"`
+	Path      string    `gorm:"column:path;type:String" json:"path"`
+	Status    int32     `gorm:"column:status;type:Int32" json:"status"`
+	Latency   int64     `gorm:"column:latency;type:Int64;comment:毫秒" json:"latency"`
+	IP        string    `gorm:"column:ip;type:String" json:"ip"`
+	AccountID uint64    `gorm:"column:account_id;type:UInt64" json:"accountId"`
+	UserAgent string    `gorm:"column:user_agent;type:String" json:"userAgent"`
+}
+
+func (RequestLog) TableName() string {
+	return "request_logs"
+}
+
+func (RequestLog) TableOptions() string {
+	return mergeTreeByTime
+}
+
+// LoginEvent 登录事件（clickhouse）
+type LoginEvent struct {
+	Time      time.Time `gorm:"column:time;type:DateTime" json:"time"`
+	AccountID uint64    `gorm:"column:account_id;type:UInt64" json:"accountId"`
+	Username  string    `gorm:"column:username;type:String" json:"username"`
+	IP        string    `gorm:"column:ip;type:String" json:"ip"`
+	Success   uint8     `gorm:"column:success;type:UInt8" json:"success"`
+	Reason    string    `gorm:"column:reason;type:String" json:"reason"`
+}
+
+func (LoginEvent) TableName() string {
+	return "login_events"
+}
+
+func (LoginEvent) TableOptions() string {
+	return mergeTreeByTime
+}
+
+// OperateLogEvent 操作日志（clickhouse）
+type OperateLogEvent struct {
+	Time      time.Time `gorm:"column:time;type:DateTime" json:"time"`
+	AccountID uint64    `gorm:"column:account_id;type:UInt64" json:"accountId"`
+	Module    string    `gorm:"column:module;type:LowCardinality(String)" json:"module"`
+	IP        string    `gorm:"column:ip;type:String" json:"ip"`
+	Content   string    `gorm:"column:content;type:String" json:"content"`
+}
+
+func (OperateLogEvent) TableName() string {
+	return "operate_log_events"
+}
+
+func (OperateLogEvent) TableOptions() string {
+	return mergeTreeByTime
+}
+
+// LogRecord 应用日志（clickhouse）
+type LogRecord struct {
+	Time      time.Time `gorm:"column:time;type:DateTime64(3)" json:"time"`
+	Level     string    `gorm:"column:level;type:LowCardinality(String)" json:"level"`
+	Module    string    `gorm:"column:module;type:LowCardinality(String)" json:"module"`
+	Message   string    `gorm:"column:message;type:String" json:"message"`
+	RequestID string    `gorm:"column:request_id;type:String" json:"requestId"`
+	TraceID   string    `gorm:"column:trace_id;type:String" json:"traceId"`
+	Fields    string    `gorm:"column:fields;type:String;comment:json" json:"fields"`
+}
+
+func (LogRecord) TableName() string {
+	return "logs"
+}
+
+func (LogRecord) TableOptions() string {
+	return "ENGINE=MergeTree() PARTITION BY toYYYYMMDD(time) ORDER BY (time) TTL toDateTime(time) + INTERVAL 30 DAY"
+}
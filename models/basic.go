@@ -9,10 +9,11 @@ import (
 	"gorm.io/gorm"
 )
 
+// Model query标签声明分页接口可过滤、排序的字段
 type Model struct {
-	ID        uint           `gorm:"primary_key" json:"id"`
-	CreatedAt time.Time      `json:"createdAt"`
-	UpdatedAt time.Time      `json:"updatedAt"`
+	ID        uint           `gorm:"primary_key" json:"id" query:"filter=eq|in|gt|lt|between,sort"`
+	CreatedAt time.Time      `json:"createdAt" query:"filter=gt|lt|between,sort"`
+	UpdatedAt time.Time      `json:"updatedAt" query:"filter=gt|lt|between,sort"`
 	DeletedAt gorm.DeletedAt `sql:"index" json:"deletedAt"`
 }
 
@@ -20,6 +21,10 @@ type ID struct {
 	ID uint `uri:"id" binding:"required"`
 }
 
+type ObjectID struct {
+	ID string `uri:"id" binding:"required,objectid"`
+}
+
 type IDBulk struct {
 	IDs []uint `form:"ids" bindling:"required"`
 }
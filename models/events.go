@@ -0,0 +1,62 @@
+package models
+
+import "time"
+
+const (
+	AggregateAccount = "account"
+
+	EventAccountRegistered  = "account.registered"
+	EventAccountLoggedIn    = "account.logged_in"
+	EventAccountLoginFailed = "account.login_failed"
+	EventAccountLocked      = "account.locked"
+)
+
+// AccountRegisteredEvent 账号注册事件
+type AccountRegisteredEvent struct {
+	AccountID    uint      `json:"accountId"`
+	Username     string    `json:"username"`
+	IP           string    `json:"ip"`
+	RegisteredAt time.Time `json:"registeredAt"`
+}
+
+func (AccountRegisteredEvent) EventName() string {
+	return EventAccountRegistered
+}
+
+// AccountLoggedInEvent 账号登录成功事件
+type AccountLoggedInEvent struct {
+	AccountID  uint      `json:"accountId"`
+	Username   string    `json:"username"`
+	IP         string    `json:"ip"`
+	LoginTimes uint      `json:"loginTimes"`
+	LoginAt    time.Time `json:"loginAt"`
+}
+
+func (AccountLoggedInEvent) EventName() string {
+	return EventAccountLoggedIn
+}
+
+// AccountLoginFailedEvent 账号登录失败事件，账号不存在时AccountID为0
+type AccountLoginFailedEvent struct {
+	AccountID uint      `json:"accountId"`
+	Username  string    `json:"username"`
+	IP        string    `json:"ip"`
+	Reason    string    `json:"reason"`
+	FailedAt  time.Time `json:"failedAt"`
+}
+
+func (AccountLoginFailedEvent) EventName() string {
+	return EventAccountLoginFailed
+}
+
+// AccountLockedEvent 登录时检测到账号已锁定
+type AccountLockedEvent struct {
+	AccountID  uint      `json:"accountId"`
+	Username   string    `json:"username"`
+	IP         string    `json:"ip"`
+	DetectedAt time.Time `json:"detectedAt"`
+}
+
+func (AccountLockedEvent) EventName() string {
+	return EventAccountLocked
+}
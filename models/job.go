@@ -0,0 +1,7 @@
+package models
+
+const (
+	JobRecomputeStats   = "stats.recompute"
+	JobPruneOperateLogs = "system.prune_operate_logs"
+	JobWebhookDeliver   = "webhook.deliver"
+)
@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+type OutboxStatus string
+
+var (
+	OutboxStatusPending   OutboxStatus = "pending"
+	OutboxStatusDelivered OutboxStatus = "delivered"
+)
+
+// OutboxMessage 待发布的领域事件，与业务数据在同一事务中写入
+type OutboxMessage struct {
+	ID            uint64       `gorm:"primary_key" json:"id"`
+	CreatedAt     time.Time    `json:"createdAt"`
+	AggregateType string       `gorm:"column:aggregate_type;not null;default:'';type:varchar(50);index:idx_outbox_aggregate,priority:1" json:"aggregateType"` //聚合类型
+	AggregateID   string       `gorm:"column:aggregate_id;not null;default:'';type:varchar(64);index:idx_outbox_aggregate,priority:2" json:"aggregateId"`     //聚合id
+	EventType     string       `gorm:"column:event_type;not null;default:'';type:varchar(100)" json:"eventType"`                                              //事件类型
+	Payload       string       `gorm:"column:payload;type:longtext" json:"payload"`                                                                           //事件内容
+	Headers       string       `gorm:"column:headers;type:text" json:"headers"`                                                                               //消息头
+	Status        OutboxStatus `gorm:"column:status;not null;default:'pending';type:varchar(20);index:idx_outbox_status" json:"status"`                       //状态
+	Attempts      uint         `gorm:"column:attempts;not null;default:0" json:"attempts"`                                                                    //发布次数
+	LastError     string       `gorm:"column:last_error;not null;default:'';type:varchar(500)" json:"lastError"`                                              //最后一次错误
+	DeliveredAt   *time.Time   `gorm:"column:delivered_at;index:idx_outbox_delivered" json:"deliveredAt"`                                                     //发布时间
+}
+
+func (OutboxMessage) TableName() string {
+	return "outbox_messages"
+}
@@ -0,0 +1,8 @@
+package models
+
+type StatsSeriesReq struct {
+	Event   string `form:"event" binding:"required,oneof=registrations logins loginFailures"` //事件
+	Range   string `form:"range" binding:"omitempty"`                                         //时间范围，默认24h
+	Every   string `form:"every" binding:"omitempty"`                                         //分桶间隔，默认1h
+	GroupBy string `form:"groupBy" binding:"omitempty,oneof=ip reason"`                       //分组
+}
@@ -0,0 +1,86 @@
+package models
+
+import "time"
+
+type WebhookStatus string
+
+var (
+	WebhookStatusActive   WebhookStatus = "active"
+	WebhookStatusDisabled WebhookStatus = "disabled"
+)
+
+type WebhookDeliveryStatus string
+
+var (
+	WebhookDeliveryPending   WebhookDeliveryStatus = "pending"
+	WebhookDeliverySucceeded WebhookDeliveryStatus = "succeeded"
+	WebhookDeliveryFailed    WebhookDeliveryStatus = "failed"
+	WebhookDeliverySkipped   WebhookDeliveryStatus = "skipped"
+)
+
+// WebhookEvents 可订阅的事件，需写入outbox由worker投递，请求参数通过webhook_event校验
+var WebhookEvents = []string{
+	EventAccountRegistered,
+	EventAccountLoggedIn,
+	EventAccountLocked,
+}
+
+// WebhookEndpoint webhook订阅
+type WebhookEndpoint struct {
+	Model
+	URL           string           `gorm:"column:url;not null;default:'';type:varchar(500)" json:"url" query:"filter=eq|like"`                  //回调地址
+	Secret        string           `gorm:"column:secret;not null;default:'';type:varchar(100)" json:"-"`                                        //签名密钥
+	Events        ArrayFieldString `gorm:"column:events;type:json" json:"events" query:"filter=contains"`                                       //订阅事件
+	Description   string           `gorm:"column:description;not null;default:'';type:varchar(200)" json:"description"`                         //描述
+	Status        WebhookStatus    `gorm:"column:status;not null;default:'active';type:varchar(20)" json:"status" query:"filter=eq|ne|in,sort"` //状态
+	FailureCount  uint             `gorm:"column:failure_count;not null;default:0" json:"failureCount" query:"filter=eq|gt|lt,sort"`            //连续失败次数
+	DisabledAt    *time.Time       `gorm:"column:disabled_at" json:"disabledAt"`                                                                //自动禁用时间
+	DisableReason string           `gorm:"column:disable_reason;not null;default:'';type:varchar(500)" json:"disableReason"`                    //禁用原因
+}
+
+// WebhookDelivery webhook投递记录
+type WebhookDelivery struct {
+	Model
+	EndpointID   uint                  `gorm:"column:endpoint_id;not null;default:0;index:idx_webhook_delivery_endpoint;uniqueIndex:idx_webhook_delivery_event,priority:1" json:"endpointId"` //订阅id
+	EventID      string                `gorm:"column:event_id;not null;default:'';type:varchar(64);uniqueIndex:idx_webhook_delivery_event,priority:2" json:"eventId" query:"filter=eq"`       //事件id
+	EventType    string                `gorm:"column:event_type;not null;default:'';type:varchar(100)" json:"eventType" query:"filter=eq|in|like"`                                            //事件类型
+	Payload      string                `gorm:"column:payload;type:longtext" json:"payload"`                                                                                                   //投递内容
+	Status       WebhookDeliveryStatus `gorm:"column:status;not null;default:'pending';type:varchar(20)" json:"status" query:"filter=eq|ne|in"`                                               //状态
+	Attempts     uint                  `gorm:"column:attempts;not null;default:0" json:"attempts" query:"filter=eq|gt|lt"`                                                                    //投递次数
+	StatusCode   int                   `gorm:"column:status_code;not null;default:0" json:"statusCode" query:"filter=eq|ne|in|gt|lt|between"`                                                 //最后一次响应码
+	ResponseBody string                `gorm:"column:response_body;type:text" json:"responseBody"`                                                                                            //最后一次响应内容
+	Error        string                `gorm:"column:error;not null;default:'';type:varchar(500)" json:"error"`                                                                               //最后一次错误
+	DurationMs   int64                 `gorm:"column:duration_ms;not null;default:0" json:"durationMs"`                                                                                       //最后一次耗时
+	DeliveredAt  *time.Time            `gorm:"column:delivered_at" json:"deliveredAt"`                                                                                                        //投递成功时间
+}
+
+// WebhookPayload 投递内容
+type WebhookPayload struct {
+	ID        string      `json:"id"`
+	Type      string      `json:"type"`
+	CreatedAt time.Time   `json:"createdAt"`
+	Data      interface{} `json:"data"`
+}
+
+// WebhookDeliverJob 投递任务参数
+type WebhookDeliverJob struct {
+	DeliveryID uint `json:"deliveryId"`
+}
+
+type CreateWebhookReq struct {
+	URL         string   `json:"url" binding:"required,url,max=500"`                 //回调地址
+	Events      []string `json:"events" binding:"required,min=1,dive,webhook_event"` //订阅事件
+	Description string   `json:"description" binding:"max=200"`                      //描述
+}
+
+type UpdateWebhookReq struct {
+	URL         string   `json:"url" binding:"omitempty,url,max=500"`                 //回调地址
+	Events      []string `json:"events" binding:"omitempty,min=1,dive,webhook_event"` //订阅事件
+	Description *string  `json:"description" binding:"omitempty,max=200"`             //描述
+	Enabled     *bool    `json:"enabled"`                                             //启用或禁用，启用时重置失败次数
+}
+
+type CreateWebhookRes struct {
+	*WebhookEndpoint
+	Secret string `json:"secret"` //签名密钥，只在创建时返回
+}
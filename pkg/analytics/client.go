@@ -0,0 +1,204 @@
+package analytics
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"time"
+
+	"lovebox/pkg/database"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+var (
+	metricsOnce sync.Once
+
+	eventsWritten *prometheus.CounterVec
+	eventsDropped *prometheus.CounterVec
+)
+
+func registerMetrics() {
+	eventsWritten = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "analytics_events_written_total",
+			Help: "How many analytics events written to clickhouse, partitioned by table.",
+		},
+		[]string{"table"},
+	)
+	prometheus.MustRegister(eventsWritten)
+
+	eventsDropped = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "analytics_events_dropped_total",
+			Help: "How many analytics events dropped, partitioned by reason.",
+		},
+		[]string{"reason"},
+	)
+	prometheus.MustRegister(eventsDropped)
+}
+
+// Table clickhouse表，TableOptions返回建表引擎参数
+type Table interface {
+	TableName() string
+	TableOptions() string
+}
+
+// Client 批量异步写入clickhouse的分析数据客户端
+type Client struct {
+	log *zap.SugaredLogger
+	db  *database.Client
+
+	events        chan Table
+	batchSize     int
+	flushInterval time.Duration
+
+	closeOnce sync.Once
+	closed    chan struct{}
+	done      chan struct{}
+}
+
+// New 创建客户端，bufferSize为内存中最多缓存的事件数，超出后丢弃
+func New(
+	db *database.Client,
+	bufferSize int,
+	batchSize int,
+	flushInterval time.Duration,
+) *Client {
+	metricsOnce.Do(registerMetrics)
+
+	c := &Client{
+		log:           zap.S().With("module", "pkg.analytics"),
+		db:            db,
+		events:        make(chan Table, bufferSize),
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+		closed:        make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+	go c.loop()
+
+	return c
+}
+
+// Db ...
+func (c *Client) Db() *database.Client {
+	return c.db
+}
+
+// Migrate 创建或更新MergeTree表
+func (c *Client) Migrate(tables ...Table) error {
+	for _, table := range tables {
+		err := c.db.AutoMigrateWithSet("gorm:table_options", table.TableOptions(), table)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Record 异步写入事件，不阻塞调用方；c为nil（未启用clickhouse）时忽略
+func (c *Client) Record(event Table) {
+	if c == nil {
+		return
+	}
+
+	select {
+	case <-c.closed:
+		eventsDropped.WithLabelValues("closed").Inc()
+		return
+	default:
+	}
+
+	select {
+	case c.events <- event:
+	default:
+		eventsDropped.WithLabelValues("buffer_full").Inc()
+	}
+}
+
+// Shutdown 停止接收事件并写入缓存中剩余的事件
+func (c *Client) Shutdown(ctx context.Context) error {
+	if c == nil {
+		return nil
+	}
+
+	c.closeOnce.Do(func() {
+		close(c.closed)
+	})
+
+	select {
+	case <-c.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *Client) loop() {
+	defer close(c.done)
+
+	ticker := time.NewTicker(c.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]Table, 0, c.batchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		c.flush(batch)
+		batch = make([]Table, 0, c.batchSize)
+	}
+
+	for {
+		select {
+		case event := <-c.events:
+			batch = append(batch, event)
+			if len(batch) >= c.batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-c.closed:
+			for {
+				select {
+				case event := <-c.events:
+					batch = append(batch, event)
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// flush 按表分组批量写入
+func (c *Client) flush(batch []Table) {
+	groups := map[reflect.Type]reflect.Value{}
+	tables := map[reflect.Type]string{}
+	for _, event := range batch {
+		t := reflect.TypeOf(event)
+		rows, ok := groups[t]
+		if !ok {
+			rows = reflect.MakeSlice(reflect.SliceOf(t), 0, len(batch))
+			tables[t] = event.TableName()
+		}
+		groups[t] = reflect.Append(rows, reflect.ValueOf(event))
+	}
+
+	for t, rows := range groups {
+		table := tables[t]
+		err := c.db.Db().
+			Table(table).
+			CreateInBatches(rows.Interface(), c.batchSize).
+			Error
+		if err != nil {
+			c.log.Errorf("flush table=%s rows=%d err=%v", table, rows.Len(), err)
+			eventsDropped.WithLabelValues("write_error").Add(float64(rows.Len()))
+			continue
+		}
+		eventsWritten.WithLabelValues(table).Add(float64(rows.Len()))
+	}
+}
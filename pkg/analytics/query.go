@@ -0,0 +1,75 @@
+package analytics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrInvalidInterval ...
+	ErrInvalidInterval = errors.New("invalid bucket interval")
+)
+
+// BucketQuery 按时间分桶聚合查询
+type BucketQuery struct {
+	Table    string        // 表名
+	TimeCol  string        // 时间字段，默认time
+	Interval time.Duration // 分桶间隔，最小1秒
+	From     time.Time
+	To       time.Time
+	Value    string        // 聚合表达式，默认count()
+	GroupBy  string        // 可选的分组字段
+	Where    string        // 可选的附加条件，使用?占位
+	Args     []interface{} // Where参数
+}
+
+// Bucket 聚合结果
+type Bucket struct {
+	Time  time.Time `gorm:"column:bucket" json:"time"`
+	Group string    `gorm:"column:grp" json:"group,omitempty"`
+	Value float64   `gorm:"column:value" json:"value"`
+}
+
+// QueryBuckets 查询时间分桶聚合，例如每小时登录失败次数
+func (c *Client) QueryBuckets(ctx context.Context, q *BucketQuery) ([]Bucket, error) {
+	seconds := int64(q.Interval / time.Second)
+	if seconds < 1 {
+		return nil, ErrInvalidInterval
+	}
+	timeCol := q.TimeCol
+	if timeCol == "" {
+		timeCol = "time"
+	}
+	value := q.Value
+	if value == "" {
+		value = "count()"
+	}
+	group := "''"
+	if q.GroupBy != "" {
+		group = "toString(`" + q.GroupBy + "`)"
+	}
+
+	query := c.db.Db().WithContext(ctx).
+		Table(q.Table).
+		Select(fmt.Sprintf(
+			"toStartOfInterval(`%s`, INTERVAL %d second) AS bucket, %s AS grp, toFloat64(%s) AS value",
+			timeCol, seconds, group, value,
+		)).
+		Where(fmt.Sprintf("`%s` >= ? AND `%s` < ?", timeCol, timeCol), q.From, q.To)
+	if q.Where != "" {
+		query = query.Where(q.Where, q.Args...)
+	}
+
+	buckets := []Bucket{}
+	err := query.
+		Group("bucket, grp").
+		Order("bucket, grp").
+		Scan(&buckets).
+		Error
+	if err != nil {
+		return nil, err
+	}
+	return buckets, nil
+}
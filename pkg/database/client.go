@@ -1,8 +1,7 @@
 package database
 
 import (
-	"log"
-	"os"
+	"context"
 	"strconv"
 	"time"
 
@@ -14,6 +13,9 @@ import (
 
 type Client struct {
 	db *gorm.DB
+
+	txMaxRetries   int
+	txRetryBackoff time.Duration
 }
 
 func factory(
@@ -24,6 +26,10 @@ func factory(
 ) (*Client, error) {
 	// gorm first方法忽略记录查不到err
 	_ = db.Callback().Query().Before("gorm:query").Register("disable_raise_record_not_found", MaskNotDataError)
+	// context中存在事务时自动加入
+	if err := registerTxCallbacks(db); err != nil {
+		return nil, err
+	}
 
 	sqlDB, err := db.DB()
 	if err != nil {
@@ -34,7 +40,9 @@ func factory(
 	sqlDB.SetConnMaxLifetime(connMaxLifetime)
 
 	client := &Client{
-		db: db,
+		db:             db,
+		txMaxRetries:   defaultTxMaxRetries,
+		txRetryBackoff: defaultTxRetryBackoff,
 	}
 	return client, nil
 }
@@ -55,7 +63,7 @@ func NewClickHouse(
 		return nil, err
 	}
 
-	return factory(db, maxOpenConns, maxIdleConns, connMaxLifetime)
+	return factory(db, maxIdleConns, maxOpenConns, connMaxLifetime)
 }
 
 func NewMysql(
@@ -74,28 +82,37 @@ func NewMysql(
 		return nil, err
 	}
 
-	return factory(db, maxOpenConns, maxIdleConns, connMaxLifetime)
+	return factory(db, maxIdleConns, maxOpenConns, connMaxLifetime)
 }
 
 func (client *Client) Db() *gorm.DB {
 	return client.db
 }
 
+// Ping 检查数据库连接是否可用
+func (c *Client) Ping(ctx context.Context) error {
+	sqlDB, err := c.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+// Close 关闭连接池
+func (c *Client) Close() error {
+	sqlDB, err := c.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
+
 func MaskNotDataError(gormDB *gorm.DB) {
 	gormDB.Statement.RaiseErrorOnNotFound = false
 }
 
 func GetLogger(logLevel logger.LogLevel) logger.Interface {
-	l := logger.New(
-		log.New(os.Stdout, "\r\n", log.LstdFlags),
-		logger.Config{
-			SlowThreshold:             time.Second, // 慢 SQL 阈值
-			LogLevel:                  logLevel,    // 日志级别
-			IgnoreRecordNotFoundError: true,        // 忽略ErrRecordNotFound（记录未找到）错误
-			Colorful:                  false,       // 禁用彩色打印
-		},
-	)
-	return l
+	return NewLogger(logLevel, defaultSlowThreshold)
 }
 
 func (c *Client) AutoMigrate(models ...interface{}) error {
@@ -0,0 +1,95 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	zaplog "lovebox/pkg/logger"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/utils"
+)
+
+const defaultSlowThreshold = time.Second
+
+// zapLogger 基于zap实现的gorm日志
+type zapLogger struct {
+	log           *zap.SugaredLogger
+	level         logger.LogLevel
+	slowThreshold time.Duration
+}
+
+// NewLogger 创建gorm日志，慢查询与错误输出到zap并附带trace id
+func NewLogger(logLevel logger.LogLevel, slowThreshold time.Duration) logger.Interface {
+	if slowThreshold <= 0 {
+		slowThreshold = defaultSlowThreshold
+	}
+	return &zapLogger{
+		log:           zap.S().With("module", "pkg.database"),
+		level:         logLevel,
+		slowThreshold: slowThreshold,
+	}
+}
+
+func (l *zapLogger) LogMode(level logger.LogLevel) logger.Interface {
+	nl := *l
+	nl.level = level
+	return &nl
+}
+
+func (l *zapLogger) Info(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= logger.Info {
+		l.withContext(ctx).Infof(msg, args...)
+	}
+}
+
+func (l *zapLogger) Warn(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= logger.Warn {
+		l.withContext(ctx).Warnf(msg, args...)
+	}
+}
+
+func (l *zapLogger) Error(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= logger.Error {
+		l.withContext(ctx).Errorf(msg, args...)
+	}
+}
+
+func (l *zapLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	if l.level <= logger.Silent {
+		return
+	}
+
+	elapsed := time.Since(begin)
+	switch {
+	// 忽略ErrRecordNotFound（记录未找到）错误
+	case err != nil && l.level >= logger.Error && !errors.Is(err, gorm.ErrRecordNotFound):
+		sql, rows := fc()
+		l.withContext(ctx).With(
+			"caller", utils.FileWithLineNum(),
+			"latency", elapsed.Milliseconds(),
+			"rows", rows,
+		).Errorf("%s: %v", sql, err)
+	case elapsed > l.slowThreshold && l.level >= logger.Warn:
+		sql, rows := fc()
+		l.withContext(ctx).With(
+			"caller", utils.FileWithLineNum(),
+			"latency", elapsed.Milliseconds(),
+			"rows", rows,
+		).Warnf("SLOW SQL >= %v: %s", l.slowThreshold, sql)
+	case l.level == logger.Info:
+		sql, rows := fc()
+		l.withContext(ctx).With(
+			"caller", utils.FileWithLineNum(),
+			"latency", elapsed.Milliseconds(),
+			"rows", rows,
+		).Info(sql)
+	}
+}
+
+func (l *zapLogger) withContext(ctx context.Context) *zap.SugaredLogger {
+	return zaplog.WithContext(ctx, l.log)
+}
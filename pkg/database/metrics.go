@@ -0,0 +1,172 @@
+package database
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"lovebox/pkg/tracing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const (
+	instanceKeyStartTime = "database:start_time"
+	instanceKeySpan      = "database:span"
+)
+
+var (
+	metricsOnce sync.Once
+
+	queryDuration *prometheus.HistogramVec
+	queryErrors   *prometheus.CounterVec
+	rowsAffected  *prometheus.CounterVec
+)
+
+func registerMetrics() {
+	queryDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "db_query_duration_seconds",
+			Help:    "The database query latencies in seconds, partitioned by table and operation.",
+			Buckets: []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+		},
+		[]string{"db", "table", "operation"},
+	)
+	prometheus.MustRegister(queryDuration)
+
+	queryErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "db_query_errors_total",
+			Help: "How many database queries failed, partitioned by table and operation.",
+		},
+		[]string{"db", "table", "operation"},
+	)
+	prometheus.MustRegister(queryErrors)
+
+	rowsAffected = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "db_rows_affected_total",
+			Help: "How many rows affected by database queries, partitioned by table and operation.",
+		},
+		[]string{"db", "table", "operation"},
+	)
+	prometheus.MustRegister(rowsAffected)
+}
+
+// Instrument 注册查询耗时、错误、影响行数指标，连接池指标，
+// 以及每条查询的tracing span（父span来自查询的context）
+func (c *Client) Instrument(name string, slowThreshold time.Duration) error {
+	metricsOnce.Do(registerMetrics)
+
+	if l, ok := c.db.Logger.(*zapLogger); ok && slowThreshold > 0 {
+		l.slowThreshold = slowThreshold
+	}
+
+	sqlDB, err := c.db.DB()
+	if err != nil {
+		return err
+	}
+	if err := prometheus.Register(collectors.NewDBStatsCollector(sqlDB, name)); err != nil {
+		return err
+	}
+
+	cb := c.db.Callback()
+	errs := []error{
+		cb.Create().Before("*").Register("instrument:before_create", c.beforeQuery("create")),
+		cb.Create().After("*").Register("instrument:after_create", c.afterQuery(name, "create")),
+		cb.Query().Before("*").Register("instrument:before_query", c.beforeQuery("query")),
+		cb.Query().After("*").Register("instrument:after_query", c.afterQuery(name, "query")),
+		cb.Update().Before("*").Register("instrument:before_update", c.beforeQuery("update")),
+		cb.Update().After("*").Register("instrument:after_update", c.afterQuery(name, "update")),
+		cb.Delete().Before("*").Register("instrument:before_delete", c.beforeQuery("delete")),
+		cb.Delete().After("*").Register("instrument:after_delete", c.afterQuery(name, "delete")),
+		cb.Row().Before("*").Register("instrument:before_row", c.beforeQuery("row")),
+		cb.Row().After("*").Register("instrument:after_row", c.afterQuery(name, "row")),
+		cb.Raw().Before("*").Register("instrument:before_raw", c.beforeQuery("raw")),
+		cb.Raw().After("*").Register("instrument:after_raw", c.afterQuery(name, "raw")),
+	}
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *Client) beforeQuery(operation string) func(*gorm.DB) {
+	return func(gormDB *gorm.DB) {
+		gormDB.InstanceSet(instanceKeyStartTime, time.Now())
+
+		ctx := gormDB.Statement.Context
+		if ctx == nil {
+			return
+		}
+		// 只在存在父span时创建，避免产生大量孤立的span
+		if !trace.SpanContextFromContext(ctx).IsValid() {
+			return
+		}
+		_, span := tracing.Tracer().Start(
+			ctx,
+			"gorm:"+operation,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				semconv.DBSystemKey.String(gormDB.Dialector.Name()),
+				attribute.String("component", "gorm"),
+			),
+		)
+		gormDB.InstanceSet(instanceKeySpan, span)
+	}
+}
+
+func (c *Client) afterQuery(name, operation string) func(*gorm.DB) {
+	return func(gormDB *gorm.DB) {
+		table := gormDB.Statement.Table
+		if table == "" {
+			table = "unknown"
+		}
+
+		err := gormDB.Error
+		failed := err != nil && !errors.Is(err, gorm.ErrRecordNotFound)
+
+		if val, ok := gormDB.InstanceGet(instanceKeyStartTime); ok {
+			if start, ok := val.(time.Time); ok {
+				queryDuration.WithLabelValues(name, table, operation).
+					Observe(time.Since(start).Seconds())
+			}
+		}
+		if failed {
+			queryErrors.WithLabelValues(name, table, operation).Inc()
+		}
+		if gormDB.RowsAffected > 0 {
+			rowsAffected.WithLabelValues(name, table, operation).
+				Add(float64(gormDB.RowsAffected))
+		}
+
+		val, ok := gormDB.InstanceGet(instanceKeySpan)
+		if !ok {
+			return
+		}
+		span, ok := val.(trace.Span)
+		if !ok {
+			return
+		}
+		span.SetAttributes(
+			semconv.DBNameKey.String(name),
+			semconv.DBStatementKey.String(gormDB.Statement.SQL.String()),
+			semconv.DBSQLTableKey.String(table),
+			attribute.Int64("db.rows_affected", gormDB.RowsAffected),
+		)
+		if failed {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
@@ -0,0 +1,170 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultTxMaxRetries   = 3
+	defaultTxRetryBackoff = 50 * time.Millisecond
+
+	// mysql 死锁 / 锁等待超时错误码
+	errCodeDeadlock        = 1213
+	errCodeLockWaitTimeout = 1205
+)
+
+type txContextKey struct{}
+
+// txState 保存在context中的事务信息
+type txState struct {
+	tx    *gorm.DB
+	depth int
+}
+
+// TxFromContext 获取context中的事务，不存在返回nil
+func TxFromContext(ctx context.Context) *gorm.DB {
+	if ctx == nil {
+		return nil
+	}
+	if state, ok := ctx.Value(txContextKey{}).(*txState); ok {
+		return state.tx
+	}
+	return nil
+}
+
+// SetTxRetry 设置事务遇到死锁时的重试次数与退避时间
+func (c *Client) SetTxRetry(maxRetries int, backoff time.Duration) {
+	if maxRetries < 0 {
+		maxRetries = 0
+	}
+	c.txMaxRetries = maxRetries
+	c.txRetryBackoff = backoff
+}
+
+// WithTransaction 在事务中执行fn，事务保存在fn收到的ctx中，
+// 使用 Db().WithContext(ctx) 的查询会自动加入该事务。
+// 嵌套调用使用savepoint，最外层事务遇到死锁时整体重试。
+func (c *Client) WithTransaction(
+	ctx context.Context,
+	fn func(ctx context.Context) error,
+	opts ...*sql.TxOptions,
+) error {
+	if state, ok := ctx.Value(txContextKey{}).(*txState); ok {
+		return c.withSavePoint(ctx, state, fn)
+	}
+
+	var err error
+	for attempt := 0; ; attempt++ {
+		err = c.withTransaction(ctx, fn, opts...)
+		if err == nil || !IsRetryableTxError(err) || attempt >= c.txMaxRetries {
+			return err
+		}
+
+		backoff := c.txRetryBackoff * time.Duration(1<<attempt)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+	}
+}
+
+func (c *Client) withTransaction(
+	ctx context.Context,
+	fn func(ctx context.Context) error,
+	opts ...*sql.TxOptions,
+) (err error) {
+	tx := c.db.WithContext(ctx).Begin(opts...)
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	panicked := true
+	defer func() {
+		// panic或出错时回滚
+		if panicked || err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	err = fn(context.WithValue(ctx, txContextKey{}, &txState{tx: tx}))
+	if err == nil {
+		err = tx.Commit().Error
+	}
+	panicked = false
+
+	return err
+}
+
+func (c *Client) withSavePoint(
+	ctx context.Context,
+	parent *txState,
+	fn func(ctx context.Context) error,
+) (err error) {
+	state := &txState{
+		tx:    parent.tx,
+		depth: parent.depth + 1,
+	}
+	name := fmt.Sprintf("sp_%d", state.depth)
+
+	if err = state.tx.SavePoint(name).Error; err != nil {
+		return err
+	}
+
+	panicked := true
+	defer func() {
+		if panicked || err != nil {
+			state.tx.RollbackTo(name)
+		}
+	}()
+
+	err = fn(context.WithValue(ctx, txContextKey{}, state))
+	panicked = false
+
+	return err
+}
+
+// IsRetryableTxError 是否为可重试的事务错误（死锁、锁等待超时）
+func IsRetryableTxError(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == errCodeDeadlock || mysqlErr.Number == errCodeLockWaitTimeout
+	}
+	return false
+}
+
+// useContextTx 将context中的事务连接替换到当前statement
+func useContextTx(gormDB *gorm.DB) {
+	tx := TxFromContext(gormDB.Statement.Context)
+	if tx == nil {
+		return
+	}
+	gormDB.Statement.ConnPool = tx.Statement.ConnPool
+}
+
+func registerTxCallbacks(db *gorm.DB) error {
+	cb := db.Callback()
+	if err := cb.Create().Before("gorm:begin_transaction").Register("use_context_tx", useContextTx); err != nil {
+		return err
+	}
+	if err := cb.Update().Before("gorm:begin_transaction").Register("use_context_tx", useContextTx); err != nil {
+		return err
+	}
+	if err := cb.Delete().Before("gorm:begin_transaction").Register("use_context_tx", useContextTx); err != nil {
+		return err
+	}
+	if err := cb.Query().Before("gorm:query").Register("use_context_tx", useContextTx); err != nil {
+		return err
+	}
+	if err := cb.Row().Before("gorm:row").Register("use_context_tx", useContextTx); err != nil {
+		return err
+	}
+	return cb.Raw().Before("gorm:raw").Register("use_context_tx", useContextTx)
+}
@@ -0,0 +1,204 @@
+package eventbus
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"strings"
+	"sync"
+
+	"lovebox/pkg/requestid"
+
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// Event 领域事件，EventName需为值接收者，订阅时通过零值获取事件名
+type Event interface {
+	EventName() string
+}
+
+// HandlerFunc 处理任意事件
+type HandlerFunc func(ctx context.Context, event Event) error
+
+type subscriber struct {
+	name  string
+	async bool
+	fn    HandlerFunc
+}
+
+type asyncTask struct {
+	ctx   context.Context
+	event Event
+	sub   *subscriber
+}
+
+// Bus 进程内事件总线，同步订阅者在Publish中依次执行，异步订阅者由worker池执行，
+// 订阅者的错误与panic互不影响
+type Bus struct {
+	log  *zap.SugaredLogger
+	opts options
+
+	mu   sync.RWMutex
+	subs map[string][]*subscriber
+
+	// closeMu 保证enqueue的检查与发送不会与关闭交错，关闭后发送的任务不会被遗漏
+	closeMu   sync.RWMutex
+	tasks     chan *asyncTask
+	closeOnce sync.Once
+	closed    chan struct{}
+	wg        sync.WaitGroup
+}
+
+func New(opts ...Option) *Bus {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+	metricsOnce.Do(registerMetrics)
+
+	b := &Bus{
+		log:    zap.S().With("module", "pkg.eventbus"),
+		opts:   o,
+		subs:   map[string][]*subscriber{},
+		tasks:  make(chan *asyncTask, o.bufferSize),
+		closed: make(chan struct{}),
+	}
+	for i := 0; i < o.workers; i++ {
+		b.wg.Add(1)
+		go b.worker()
+	}
+	return b
+}
+
+// Subscribe 订阅类型为E的事件
+func Subscribe[E Event](b *Bus, name string, fn func(ctx context.Context, event E) error, opts ...SubscribeOption) {
+	var zero E
+	b.SubscribeName(zero.EventName(), name, func(ctx context.Context, event Event) error {
+		e, ok := event.(E)
+		if !ok {
+			return fmt.Errorf("unexpected event type %T for %s", event, zero.EventName())
+		}
+		return fn(ctx, e)
+	}, opts...)
+}
+
+// SubscribeName 按事件名订阅，用于不关心具体类型的订阅者
+func (b *Bus) SubscribeName(eventName, name string, fn HandlerFunc, opts ...SubscribeOption) {
+	o := subscribeOptions{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subs[eventName] = append(b.subs[eventName], &subscriber{
+		name:  name,
+		async: o.async,
+		fn:    fn,
+	})
+}
+
+// Publish 发布事件，返回同步订阅者的错误，异步订阅者在队列满时丢弃
+func (b *Bus) Publish(ctx context.Context, event Event) error {
+	eventName := event.EventName()
+	published.WithLabelValues(eventName).Inc()
+
+	b.mu.RLock()
+	subs := b.subs[eventName]
+	b.mu.RUnlock()
+
+	var errs []string
+	for _, sub := range subs {
+		if sub.async {
+			b.enqueue(ctx, event, sub)
+			continue
+		}
+		if err := b.call(ctx, event, sub); err != nil {
+			errs = append(errs, sub.name+": "+err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("eventbus %s: %s", eventName, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func (b *Bus) enqueue(ctx context.Context, event Event, sub *subscriber) {
+	b.closeMu.RLock()
+	defer b.closeMu.RUnlock()
+
+	select {
+	case <-b.closed:
+		dropped.WithLabelValues(event.EventName(), "closed").Inc()
+		return
+	default:
+	}
+
+	// 请求结束后ctx会被取消，异步订阅者只保留span与request id
+	asyncCtx := trace.ContextWithSpanContext(context.Background(), trace.SpanContextFromContext(ctx))
+	asyncCtx = requestid.NewContext(asyncCtx, requestid.FromContext(ctx))
+
+	select {
+	case b.tasks <- &asyncTask{ctx: asyncCtx, event: event, sub: sub}:
+	default:
+		dropped.WithLabelValues(event.EventName(), "buffer_full").Inc()
+		b.log.Warnf("Drop async event=%s subscriber=%s buffer full", event.EventName(), sub.name)
+	}
+}
+
+func (b *Bus) worker() {
+	defer b.wg.Done()
+	for {
+		select {
+		case task := <-b.tasks:
+			_ = b.call(task.ctx, task.event, task.sub)
+		case <-b.closed:
+			// 处理剩余任务
+			for {
+				select {
+				case task := <-b.tasks:
+					_ = b.call(task.ctx, task.event, task.sub)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (b *Bus) call(ctx context.Context, event Event, sub *subscriber) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+			b.log.Errorf("Subscriber panic event=%s subscriber=%s err=%v\n%s", event.EventName(), sub.name, r, debug.Stack())
+		}
+		if err != nil {
+			handlerErrors.WithLabelValues(event.EventName(), sub.name).Inc()
+			b.log.Errorf("Subscriber error event=%s subscriber=%s err=%v", event.EventName(), sub.name, err)
+		}
+	}()
+	return sub.fn(ctx, event)
+}
+
+// Shutdown 停止接收异步事件并等待队列中的事件处理完成
+func (b *Bus) Shutdown(ctx context.Context) error {
+	b.closeOnce.Do(func() {
+		b.closeMu.Lock()
+		close(b.closed)
+		b.closeMu.Unlock()
+	})
+
+	done := make(chan struct{})
+	go func() {
+		b.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,44 @@
+package eventbus
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	metricsOnce sync.Once
+
+	published     *prometheus.CounterVec
+	handlerErrors *prometheus.CounterVec
+	dropped       *prometheus.CounterVec
+)
+
+func registerMetrics() {
+	published = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "eventbus_events_published_total",
+			Help: "How many events published to the in-process bus, partitioned by event.",
+		},
+		[]string{"event"},
+	)
+	prometheus.MustRegister(published)
+
+	handlerErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "eventbus_handler_errors_total",
+			Help: "How many subscriber invocations failed or panicked, partitioned by event and subscriber.",
+		},
+		[]string{"event", "subscriber"},
+	)
+	prometheus.MustRegister(handlerErrors)
+
+	dropped = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "eventbus_async_dropped_total",
+			Help: "How many async deliveries dropped, partitioned by event and reason.",
+		},
+		[]string{"event", "reason"},
+	)
+	prometheus.MustRegister(dropped)
+}
@@ -0,0 +1,48 @@
+package eventbus
+
+type options struct {
+	workers    int
+	bufferSize int
+}
+
+func defaultOptions() options {
+	return options{
+		workers:    4,
+		bufferSize: 1000,
+	}
+}
+
+// Option controls the behavior of the Bus.
+type Option func(*options)
+
+// WithWorkers 设置异步订阅者的worker数量
+func WithWorkers(workers int) Option {
+	return func(o *options) {
+		if workers > 0 {
+			o.workers = workers
+		}
+	}
+}
+
+// WithBufferSize 设置异步事件缓冲大小
+func WithBufferSize(size int) Option {
+	return func(o *options) {
+		if size > 0 {
+			o.bufferSize = size
+		}
+	}
+}
+
+type subscribeOptions struct {
+	async bool
+}
+
+// SubscribeOption controls how a subscriber is invoked.
+type SubscribeOption func(*subscribeOptions)
+
+// Async 异步执行，不阻塞Publish
+func Async() SubscribeOption {
+	return func(o *subscribeOptions) {
+		o.async = true
+	}
+}
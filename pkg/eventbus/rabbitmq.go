@@ -0,0 +1,40 @@
+package eventbus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"lovebox/pkg/rabbitmq"
+
+	"github.com/streadway/amqp"
+)
+
+const HeaderEventName = "x-event-name"
+
+// ForwardToRabbitMQ 将指定事件异步转发到rabbitmq，routingKey为事件名，
+// 转发为尽力而为，需要可靠投递的事件应写入outbox
+func ForwardToRabbitMQ(b *Bus, publisher *rabbitmq.Client, exchange string, eventNames ...string) {
+	for _, eventName := range eventNames {
+		b.SubscribeName(eventName, "rabbitmq", func(ctx context.Context, event Event) error {
+			body, err := json.Marshal(event)
+			if err != nil {
+				return err
+			}
+			err = publisher.ProduceWithParam(ctx, &rabbitmq.ProduceParam{
+				Exchange:    exchange,
+				RoutingKey:  event.EventName(),
+				Body:        body,
+				ContentType: "application/json",
+				Headers: amqp.Table{
+					HeaderEventName: event.EventName(),
+				},
+			})
+			// 没有队列订阅该事件
+			if errors.Is(err, rabbitmq.ErrUnroutable) {
+				return nil
+			}
+			return err
+		}, Async())
+	}
+}
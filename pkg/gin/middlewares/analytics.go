@@ -0,0 +1,30 @@
+package middlewares
+
+import (
+	"time"
+
+	"lovebox/models"
+	"lovebox/pkg/analytics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// NewRequestRecorder 记录请求日志到clickhouse
+func NewRequestRecorder(client *analytics.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		c.Next()
+
+		client.Record(&models.RequestLog{
+			Time:      start,
+			Method:    c.Request.Method,
+			Path:      c.FullPath(),
+			Status:    int32(c.Writer.Status()),
+			Latency:   time.Since(start).Milliseconds(),
+			IP:        c.ClientIP(),
+			AccountID: uint64(c.GetUint("id")),
+			UserAgent: c.Request.UserAgent(),
+		})
+	}
+}
@@ -0,0 +1,258 @@
+package middlewares
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gin-gonic/gin"
+	"github.com/klauspost/compress/zstd"
+)
+
+const defaultCompressMinLength = 1024
+
+// encoder 可复用的压缩器
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+type zstdEncoder struct {
+	*zstd.Encoder
+}
+
+func (e zstdEncoder) Flush() error {
+	return e.Encoder.Flush()
+}
+
+// encoderPools 服务端按顺序优先选择的编码
+var encoderPools = []struct {
+	name string
+	pool *sync.Pool
+}{
+	{"br", &sync.Pool{New: func() interface{} {
+		return brotli.NewWriterLevel(nil, brotli.DefaultCompression)
+	}}},
+	{"zstd", &sync.Pool{New: func() interface{} {
+		enc, _ := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1), zstd.WithLowerEncoderMem(true))
+		return zstdEncoder{enc}
+	}}},
+	{"gzip", &sync.Pool{New: func() interface{} {
+		w, _ := gzip.NewWriterLevel(nil, gzip.DefaultCompression)
+		return w
+	}}},
+}
+
+type compressOptions struct {
+	minLength     int
+	excludedPaths []string
+}
+
+// CompressOption ...
+type CompressOption func(*compressOptions)
+
+// CompressMinLength 响应体小于该字节数时不压缩
+func CompressMinLength(n int) CompressOption {
+	return func(o *compressOptions) {
+		if n > 0 {
+			o.minLength = n
+		}
+	}
+}
+
+// CompressExcludedPaths 不压缩的路径前缀
+func CompressExcludedPaths(paths ...string) CompressOption {
+	return func(o *compressOptions) {
+		o.excludedPaths = append(o.excludedPaths, paths...)
+	}
+}
+
+// Compress 按Accept-Encoding协商压缩响应，支持br、zstd、gzip；
+// 只压缩文本类响应，已设置Content-Encoding或小于最小长度的响应原样返回
+func Compress(opts ...CompressOption) gin.HandlerFunc {
+	o := &compressOptions{
+		minLength: defaultCompressMinLength,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return func(c *gin.Context) {
+		if c.Request.Method == http.MethodHead || c.GetHeader("Upgrade") != "" {
+			c.Next()
+			return
+		}
+		for _, p := range o.excludedPaths {
+			if strings.HasPrefix(c.Request.URL.Path, p) {
+				c.Next()
+				return
+			}
+		}
+		c.Header("Vary", "Accept-Encoding")
+		idx := negotiateEncoding(c.GetHeader("Accept-Encoding"))
+		if idx < 0 {
+			c.Next()
+			return
+		}
+
+		w := &compressWriter{
+			ResponseWriter: c.Writer,
+			name:           encoderPools[idx].name,
+			pool:           encoderPools[idx].pool,
+			minLength:      o.minLength,
+		}
+		c.Writer = w
+		defer w.finish()
+		c.Next()
+	}
+}
+
+// negotiateEncoding 返回客户端接受的q值最高的编码，q值相同时按服务端顺序，不可压缩时返回-1
+func negotiateEncoding(header string) int {
+	if header == "" {
+		return -1
+	}
+	qs := make(map[string]float64)
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(fields[0]))
+		q := 1.0
+		for _, f := range fields[1:] {
+			f = strings.TrimSpace(f)
+			if strings.HasPrefix(f, "q=") {
+				if v, err := strconv.ParseFloat(f[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		qs[name] = q
+	}
+
+	best, bestQ := -1, 0.0
+	for i, e := range encoderPools {
+		q, ok := qs[e.name]
+		if !ok {
+			if q, ok = qs["*"]; !ok {
+				continue
+			}
+		}
+		if q > bestQ {
+			best, bestQ = i, q
+		}
+	}
+	return best
+}
+
+func compressibleType(contentType string) bool {
+	ct := strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	switch {
+	case ct == "":
+		return true
+	case ct == "text/event-stream":
+		return false
+	case strings.HasPrefix(ct, "text/"),
+		strings.HasSuffix(ct, "+json"),
+		strings.HasSuffix(ct, "+xml"):
+		return true
+	}
+	switch ct {
+	case "application/json", "application/javascript", "application/xml",
+		"application/x-ndjson", "image/svg+xml":
+		return true
+	}
+	return false
+}
+
+// compressWriter 先缓冲到minLength再决定是否压缩
+type compressWriter struct {
+	gin.ResponseWriter
+	name      string
+	pool      *sync.Pool
+	minLength int
+
+	buf     []byte
+	decided bool
+	enc     encoder
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if w.decided {
+		if w.enc != nil {
+			return w.enc.Write(b)
+		}
+		return w.ResponseWriter.Write(b)
+	}
+
+	w.buf = append(w.buf, b...)
+	if len(w.buf) >= w.minLength {
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// decide 决定是否压缩并写出缓冲的数据
+func (w *compressWriter) decide(large bool) error {
+	w.decided = true
+	h := w.Header()
+	status := w.Status()
+	if large && h.Get("Content-Encoding") == "" && compressibleType(h.Get("Content-Type")) &&
+		status != http.StatusNoContent && status != http.StatusNotModified && status >= http.StatusOK {
+		h.Set("Content-Encoding", w.name)
+		h.Del("Content-Length")
+		w.enc = w.pool.Get().(encoder)
+		w.enc.Reset(w.ResponseWriter)
+	}
+
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	if w.enc != nil {
+		_, err := w.enc.Write(buf)
+		return err
+	}
+	_, err := w.ResponseWriter.Write(buf)
+	return err
+}
+
+func (w *compressWriter) Flush() {
+	// 流式响应在第一次Flush时决定
+	if !w.decided {
+		_ = w.decide(len(w.buf) > 0)
+	}
+	if w.enc != nil {
+		_ = w.enc.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.decided = true
+	return w.ResponseWriter.Hijack()
+}
+
+func (w *compressWriter) finish() {
+	if !w.decided {
+		_ = w.decide(false)
+	}
+	if w.enc != nil {
+		_ = w.enc.Close()
+		w.enc.Reset(io.Discard)
+		w.pool.Put(w.enc)
+		w.enc = nil
+	}
+}
@@ -0,0 +1,159 @@
+package middlewares
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"lovebox/pkg/requestid"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/cors"
+	"github.com/spf13/viper"
+)
+
+// CORSConfig 跨域配置，AllowedOrigins支持"*"与"https://*.example.com"通配
+type CORSConfig struct {
+	AllowedOrigins   []string      `mapstructure:"allowedOrigins"`
+	AllowedMethods   []string      `mapstructure:"allowedMethods"`
+	AllowedHeaders   []string      `mapstructure:"allowedHeaders"`
+	ExposedHeaders   []string      `mapstructure:"exposedHeaders"`
+	AllowCredentials bool          `mapstructure:"allowCredentials"`
+	MaxAge           time.Duration `mapstructure:"maxAge"`
+}
+
+// CORSGroup 路由分组的跨域配置，Prefix为分组的路径前缀
+type CORSGroup struct {
+	Prefix     string `mapstructure:"prefix"`
+	CORSConfig `mapstructure:",squash"`
+}
+
+// CORSOptions 未匹配到分组的请求使用顶层配置
+type CORSOptions struct {
+	CORSConfig `mapstructure:",squash"`
+	Groups     []CORSGroup `mapstructure:"groups"`
+}
+
+// corsPreset 开发环境允许任意来源，生产环境默认不允许跨域请求
+func corsPreset(production bool) CORSConfig {
+	c := CORSConfig{
+		AllowedMethods: []string{
+			http.MethodGet, http.MethodPost, http.MethodPut,
+			http.MethodPatch, http.MethodDelete, http.MethodHead,
+		},
+		AllowedHeaders: []string{"Content-Type", "Authorization", "Accept-Language", requestid.Header, IdempotencyKeyHeader},
+		ExposedHeaders: []string{requestid.Header, IdempotentReplayedHeader},
+		MaxAge:         10 * time.Minute,
+	}
+	if !production {
+		c.AllowedOrigins = []string{"*"}
+		c.AllowedHeaders = []string{"*"}
+		c.AllowCredentials = true
+	}
+	return c
+}
+
+// CORSOptionsFromViper 读取http.cors配置，未配置的字段使用环境预设
+func CORSOptionsFromViper(cfg *viper.Viper, production bool) (*CORSOptions, error) {
+	if cfg == nil {
+		cfg = viper.New()
+	}
+
+	preset := corsPreset(production)
+	cfg.SetDefault("allowedOrigins", preset.AllowedOrigins)
+	cfg.SetDefault("allowCredentials", preset.AllowCredentials)
+	opts := &CORSOptions{}
+	if err := cfg.Unmarshal(opts); err != nil {
+		return nil, err
+	}
+	opts.CORSConfig.withDefaults(preset)
+	// 分组未配置的字段继承顶层配置
+	for i := range opts.Groups {
+		opts.Groups[i].withDefaults(opts.CORSConfig)
+	}
+	return opts, nil
+}
+
+func (c *CORSConfig) withDefaults(preset CORSConfig) {
+	if len(c.AllowedOrigins) == 0 {
+		c.AllowedOrigins = preset.AllowedOrigins
+	}
+	if len(c.AllowedMethods) == 0 {
+		c.AllowedMethods = preset.AllowedMethods
+	}
+	if len(c.AllowedHeaders) == 0 {
+		c.AllowedHeaders = preset.AllowedHeaders
+	}
+	if len(c.ExposedHeaders) == 0 {
+		c.ExposedHeaders = preset.ExposedHeaders
+	}
+	if c.MaxAge == 0 {
+		c.MaxAge = preset.MaxAge
+	}
+}
+
+func (c *CORSConfig) options() cors.Options {
+	opts := cors.Options{
+		AllowedOrigins:   c.AllowedOrigins,
+		AllowedMethods:   c.AllowedMethods,
+		AllowedHeaders:   c.AllowedHeaders,
+		ExposedHeaders:   c.ExposedHeaders,
+		AllowCredentials: c.AllowCredentials,
+		MaxAge:           int(c.MaxAge.Seconds()),
+	}
+	// rs/cors将空的AllowedOrigins视为允许任意来源，未配置来源时拒绝所有跨域请求
+	if len(c.AllowedOrigins) == 0 {
+		opts.AllowOriginFunc = func(string) bool { return false }
+		return opts
+	}
+	// 携带凭证时浏览器不接受"*"，改为回写请求的Origin
+	if c.AllowCredentials {
+		for _, o := range c.AllowedOrigins {
+			if o == "*" {
+				opts.AllowedOrigins = nil
+				opts.AllowOriginFunc = func(string) bool { return true }
+				break
+			}
+		}
+	}
+	return opts
+}
+
+// NewCORS 跨域中间件，需挂在router上：预检请求没有对应的路由，分组中间件不会执行，
+// 因此按路径最长前缀选择分组配置
+func NewCORS(opts *CORSOptions) gin.HandlerFunc {
+	type group struct {
+		prefix string
+		cors   *cors.Cors
+	}
+	groups := make([]group, 0, len(opts.Groups))
+	for _, g := range opts.Groups {
+		groups = append(groups, group{
+			prefix: strings.TrimRight(g.Prefix, "/"),
+			cors:   cors.New(g.options()),
+		})
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		return len(groups[i].prefix) > len(groups[j].prefix)
+	})
+	def := cors.New(opts.options())
+
+	match := func(path string) *cors.Cors {
+		for _, g := range groups {
+			if path == g.prefix || strings.HasPrefix(path, g.prefix+"/") {
+				return g.cors
+			}
+		}
+		return def
+	}
+
+	return func(c *gin.Context) {
+		match(c.Request.URL.Path).HandlerFunc(c.Writer, c.Request)
+		if c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != "" {
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,260 @@
+package middlewares
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"lovebox/pkg/errors"
+	"lovebox/pkg/resp"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+var (
+	// ErrInvalidCursor cursor被篡改、已过期（排序方式变化）或格式错误
+	ErrInvalidCursor = errors.NewWithStatus("cursor不合法", http.StatusBadRequest)
+)
+
+const (
+	cursorNext = "next"
+	cursorPrev = "prev"
+)
+
+// cursorPayload cursor中的排序方式、翻页方向与排序字段的值
+type cursorPayload struct {
+	Sort      string            `json:"s"`
+	Direction string            `json:"d"`
+	Values    []json.RawMessage `json:"v"`
+}
+
+// keysetColumn 游标分页的排序列，最后一列总是主键以保证顺序唯一
+type keysetColumn struct {
+	field *schema.Field
+	desc  bool
+}
+
+// GetPage 查询一页数据：请求带cursor参数时使用游标分页，否则使用页码分页。
+// 过滤与排序字段按模型的query标签校验，不合法时返回400并列出字段；
+// 游标分页不使用OFFSET，适合深分页，排序字段应为非空列；
+// 总数只在请求total=true（页码分页默认true）时查询，否则为-1
+func (p *Pagination) GetPage(query *gorm.DB, data interface{}) (*resp.CursorPageResult, error) {
+	query, columns, err := p.prepare(query)
+	if err != nil {
+		return nil, err
+	}
+
+	var total int64 = -1
+	if p.WithTotal {
+		if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	if !p.Keyset {
+		if p.Page > 0 && p.Limit > 0 {
+			query = query.
+				Offset((p.Page - 1) * p.Limit).
+				Limit(p.Limit)
+		}
+		if err := orderBy(query, columns, false).Scan(data).Error; err != nil {
+			return nil, err
+		}
+		return &resp.CursorPageResult{
+			PageResult: resp.PageResult{Items: data, Total: total},
+		}, nil
+	}
+
+	return p.getKeysetPage(query, data, total, keysetColumns(query.Statement.Schema, columns))
+}
+
+// prepare 解析模型，添加过滤条件并校验排序字段
+func (p *Pagination) prepare(query *gorm.DB) (*gorm.DB, []keysetColumn, error) {
+	if err := query.Statement.Parse(query.Statement.Model); err != nil {
+		return nil, nil, err
+	}
+	sch := query.Statement.Schema
+	query, invalid := p.applyFilters(query, sch)
+	columns, invalidSorts := p.sortColumns(sch)
+	invalid = append(invalid, invalidSorts...)
+	if len(invalid) > 0 {
+		return nil, nil, ErrInvalidQueryFields(invalid)
+	}
+	return query, columns, nil
+}
+
+// orderBy 按列排序，backward为true时反向
+func orderBy(query *gorm.DB, columns []keysetColumn, backward bool) *gorm.DB {
+	for _, col := range columns {
+		query = query.Order(clause.OrderByColumn{
+			Column: clause.Column{Table: clause.CurrentTable, Name: col.field.DBName},
+			Desc:   col.desc != backward,
+		})
+	}
+	return query
+}
+
+func (p *Pagination) getKeysetPage(query *gorm.DB, data interface{}, total int64, columns []keysetColumn) (*resp.CursorPageResult, error) {
+	sortSpec := keysetSortSpec(columns)
+
+	backward := false
+	if p.Cursor != "" {
+		payload, err := p.decodeCursor(p.Cursor)
+		if err != nil || payload.Sort != sortSpec || len(payload.Values) != len(columns) {
+			return nil, ErrInvalidCursor
+		}
+		backward = payload.Direction == cursorPrev
+		values := make([]interface{}, len(columns))
+		for i, col := range columns {
+			v := reflect.New(col.field.FieldType)
+			if err := json.Unmarshal(payload.Values[i], v.Interface()); err != nil {
+				return nil, ErrInvalidCursor
+			}
+			values[i] = v.Elem().Interface()
+		}
+		query = query.Where(keysetWhere(query.Statement, columns, values, backward))
+	}
+
+	if err := orderBy(query, columns, backward).Limit(p.Limit + 1).Scan(data).Error; err != nil {
+		return nil, err
+	}
+
+	items := reflect.ValueOf(data).Elem()
+	hasMore := items.Len() > p.Limit
+	if hasMore {
+		items.Set(items.Slice(0, p.Limit))
+	}
+	// 向前翻页时按相反顺序查询，结果需要反转
+	if backward {
+		for i, j := 0, items.Len()-1; i < j; i, j = i+1, j-1 {
+			a, b := items.Index(i).Interface(), items.Index(j).Interface()
+			items.Index(i).Set(reflect.ValueOf(b))
+			items.Index(j).Set(reflect.ValueOf(a))
+		}
+	}
+
+	result := &resp.CursorPageResult{
+		PageResult: resp.PageResult{Items: data, Total: total},
+	}
+	n := items.Len()
+	if n == 0 {
+		return result, nil
+	}
+	ctx := query.Statement.Context
+	hasNext, hasPrev := hasMore, p.Cursor != ""
+	if backward {
+		hasNext, hasPrev = true, hasMore
+	}
+	var err error
+	if hasNext {
+		if result.NextCursor, err = p.encodeCursor(ctx, columns, sortSpec, cursorNext, items.Index(n-1)); err != nil {
+			return nil, err
+		}
+	}
+	if hasPrev {
+		if result.PrevCursor, err = p.encodeCursor(ctx, columns, sortSpec, cursorPrev, items.Index(0)); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// keysetColumns 未包含主键时追加主键，保证顺序唯一
+func keysetColumns(sch *schema.Schema, columns []keysetColumn) []keysetColumn {
+	primary := sch.PrioritizedPrimaryField
+	if primary == nil {
+		return columns
+	}
+	for _, col := range columns {
+		if col.field == primary {
+			return columns
+		}
+	}
+	desc := len(columns) > 0 && columns[len(columns)-1].desc
+	return append(columns, keysetColumn{field: primary, desc: desc})
+}
+
+func keysetSortSpec(columns []keysetColumn) string {
+	parts := make([]string, len(columns))
+	for i, col := range columns {
+		order := "asc"
+		if col.desc {
+			order = "desc"
+		}
+		parts[i] = col.field.DBName + ":" + order
+	}
+	return strings.Join(parts, "|")
+}
+
+// keysetWhere 多列排序的游标条件，如a升序b降序：(a > ?) OR (a = ? AND b < ?)
+func keysetWhere(stmt *gorm.Statement, columns []keysetColumn, values []interface{}, backward bool) clause.Expr {
+	ors := make([]string, 0, len(columns))
+	args := []interface{}{}
+	for i, col := range columns {
+		conds := make([]string, 0, i+1)
+		for j := 0; j < i; j++ {
+			conds = append(conds, stmt.Quote(columns[j].field.DBName)+" = ?")
+			args = append(args, values[j])
+		}
+		op := " > ?"
+		if col.desc != backward {
+			op = " < ?"
+		}
+		conds = append(conds, stmt.Quote(col.field.DBName)+op)
+		args = append(args, values[i])
+		ors = append(ors, "("+strings.Join(conds, " AND ")+")")
+	}
+	return clause.Expr{SQL: "(" + strings.Join(ors, " OR ") + ")", Vars: args}
+}
+
+func (p *Pagination) encodeCursor(ctx context.Context, columns []keysetColumn, sortSpec, direction string, item reflect.Value) (string, error) {
+	item = reflect.Indirect(item)
+	payload := cursorPayload{Sort: sortSpec, Direction: direction}
+	for _, col := range columns {
+		value, _ := col.field.ValueOf(ctx, item)
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return "", err
+		}
+		payload.Values = append(payload.Values, raw)
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data) + "." +
+		base64.RawURLEncoding.EncodeToString(p.signCursor(data)), nil
+}
+
+func (p *Pagination) decodeCursor(cursor string) (*cursorPayload, error) {
+	parts := strings.SplitN(cursor, ".", 2)
+	if len(parts) != 2 {
+		return nil, ErrInvalidCursor
+	}
+	data, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || !hmac.Equal(sig, p.signCursor(data)) {
+		return nil, ErrInvalidCursor
+	}
+	payload := &cursorPayload{}
+	if err := json.Unmarshal(data, payload); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return payload, nil
+}
+
+func (p *Pagination) signCursor(data []byte) []byte {
+	mac := hmac.New(sha256.New, p.cursorKey)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,239 @@
+package middlewares
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"lovebox/pkg/errors"
+	"lovebox/pkg/resp"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type queryTestItem struct {
+	ID        uint      `gorm:"primarykey" query:"filter=eq|in|gt|lt,sort"`
+	Name      string    `query:"filter=eq|like,sort"`
+	Score     int       `query:"filter,sort"`
+	CreatedAt time.Time `query:"filter=between|gt|lt,sort"`
+	Secret    string
+}
+
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&queryTestItem{}); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// newTestPagination 经过分页中间件解析查询参数
+func newTestPagination(t *testing.T, rawQuery string) *Pagination {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	var p *Pagination
+	router := gin.New()
+	router.Use(NewPaginationMiddleware(PaginationCursorKey([]byte("test-key"))))
+	router.GET("/", func(c *gin.Context) {
+		p = c.MustGet("pagination").(*Pagination)
+	})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/?"+rawQuery, nil))
+	if p == nil {
+		t.Fatalf("pagination middleware aborted: status=%d errors=%s", w.Code, w.Body.String())
+	}
+	return p
+}
+
+func seedCursorItems(t *testing.T) []*queryTestItem {
+	t.Helper()
+	items := []*queryTestItem{}
+	for i, score := range []int{3, 1, 2, 3, 1, 2, 3} {
+		items = append(items, &queryTestItem{Name: string(rune('a' + i)), Score: score})
+	}
+	return items
+}
+
+// getCursorPage 按cursor查询一页，返回结果与id
+func getCursorPage(t *testing.T, db *gorm.DB, query, cursor string) (*resp.CursorPageResult, []uint) {
+	t.Helper()
+	p := newTestPagination(t, query+"&cursor="+url.QueryEscape(cursor))
+	items := []*queryTestItem{}
+	result, err := p.GetPage(db.Model(&queryTestItem{}), &items)
+	if err != nil {
+		t.Fatalf("GetPage(%s) err=%v", query, err)
+	}
+	ids := []uint{}
+	for _, item := range items {
+		ids = append(ids, item.ID)
+	}
+	return result, ids
+}
+
+func TestGetPageKeysetRoundTrip(t *testing.T) {
+	db := openTestDB(t)
+	if err := db.Create(seedCursorItems(t)).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		pages [][]uint
+	}{
+		{
+			name:  "single field desc with id tie breaker",
+			query: "limit=3&sort=score:desc",
+			pages: [][]uint{{7, 4, 1}, {6, 3, 5}, {2}},
+		},
+		{
+			name:  "single field asc",
+			query: "limit=3&sort=score",
+			pages: [][]uint{{2, 5, 3}, {6, 1, 4}, {7}},
+		},
+		{
+			name:  "multiple fields",
+			query: "limit=2&sort=score:desc|name:asc",
+			pages: [][]uint{{1, 4}, {7, 3}, {6, 2}, {5}},
+		},
+		{
+			name:  "multiple fields bare field ascends",
+			query: "limit=2&sort=score:desc|name",
+			pages: [][]uint{{1, 4}, {7, 3}, {6, 2}, {5}},
+		},
+		{
+			name:  "exact page size",
+			query: "limit=7&sort=id",
+			pages: [][]uint{{1, 2, 3, 4, 5, 6, 7}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 向后翻页到最后一页
+			cursor := ""
+			prevCursors := []string{}
+			for i, want := range tt.pages {
+				result, ids := getCursorPage(t, db, tt.query, cursor)
+				if !reflect.DeepEqual(ids, want) {
+					t.Fatalf("next page %d ids = %v, want %v", i, ids, want)
+				}
+				if result.Total != -1 {
+					t.Errorf("next page %d total = %d, want -1", i, result.Total)
+				}
+				last := i == len(tt.pages)-1
+				if (result.NextCursor == "") != last {
+					t.Fatalf("next page %d nextCursor = %q", i, result.NextCursor)
+				}
+				if (result.PrevCursor == "") != (i == 0) {
+					t.Fatalf("next page %d prevCursor = %q", i, result.PrevCursor)
+				}
+				prevCursors = append(prevCursors, result.PrevCursor)
+				cursor = result.NextCursor
+			}
+
+			// 从最后一页向前翻页回到第一页
+			for i := len(tt.pages) - 1; i > 0; i-- {
+				result, ids := getCursorPage(t, db, tt.query, prevCursors[i])
+				if want := tt.pages[i-1]; !reflect.DeepEqual(ids, want) {
+					t.Fatalf("prev page %d ids = %v, want %v", i-1, ids, want)
+				}
+				if result.NextCursor == "" {
+					t.Errorf("prev page %d nextCursor is empty", i-1)
+				}
+				if (result.PrevCursor == "") != (i-1 == 0) {
+					t.Errorf("prev page %d prevCursor = %q", i-1, result.PrevCursor)
+				}
+			}
+		})
+	}
+}
+
+func TestGetPageKeysetWithTotal(t *testing.T) {
+	db := openTestDB(t)
+	if err := db.Create(seedCursorItems(t)).Error; err != nil {
+		t.Fatal(err)
+	}
+	p := newTestPagination(t, "limit=3&cursor=&total=true")
+	items := []*queryTestItem{}
+	result, err := p.GetPage(db.Model(&queryTestItem{}), &items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Total != 7 || len(items) != 3 {
+		t.Errorf("total = %d items = %d, want 7 and 3", result.Total, len(items))
+	}
+}
+
+func TestGetPageInvalidCursor(t *testing.T) {
+	db := openTestDB(t)
+	if err := db.Create(seedCursorItems(t)).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	first := newTestPagination(t, "limit=2&sort=score:desc&cursor=")
+	items := []*queryTestItem{}
+	result, err := first.GetPage(db.Model(&queryTestItem{}), &items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	next := result.NextCursor
+	payload, sig, _ := strings.Cut(next, ".")
+
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey := &Pagination{cursorKey: []byte("other-key")}
+	resigned := payload + "." + base64.RawURLEncoding.EncodeToString(otherKey.signCursor(data))
+
+	tests := []struct {
+		name   string
+		query  string
+		cursor string
+	}{
+		{name: "garbage", query: "limit=2&sort=score:desc", cursor: "not-a-cursor"},
+		{name: "missing signature", query: "limit=2&sort=score:desc", cursor: payload},
+		{name: "tampered signature", query: "limit=2&sort=score:desc", cursor: payload + "." + flipLastChar(sig)},
+		{name: "tampered payload", query: "limit=2&sort=score:desc", cursor: flipLastChar(payload) + "." + sig},
+		{name: "signed with another key", query: "limit=2&sort=score:desc", cursor: resigned},
+		{name: "sort direction changed", query: "limit=2&sort=score", cursor: next},
+		{name: "sort field changed", query: "limit=2&sort=name:desc", cursor: next},
+		{name: "extra sort field", query: "limit=2&sort=score:desc|name", cursor: next},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestPagination(t, tt.query+"&cursor="+url.QueryEscape(tt.cursor))
+			items := []*queryTestItem{}
+			_, err := p.GetPage(db.Model(&queryTestItem{}), &items)
+			if !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("err = %v, want ErrInvalidCursor", err)
+			}
+		})
+	}
+}
+
+func flipLastChar(s string) string {
+	if s == "" {
+		return "A"
+	}
+	last := s[len(s)-1]
+	if last == 'A' {
+		return s[:len(s)-1] + "B"
+	}
+	return s[:len(s)-1] + "A"
+}
@@ -0,0 +1,206 @@
+package middlewares
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strings"
+
+	"lovebox/pkg/errors"
+	"lovebox/pkg/requestid"
+	"lovebox/pkg/resp"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gin-gonic/gin"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	defaultDecompressMaxSize  = 10 << 20
+	defaultDecompressMaxRatio = 100
+	// 解压后小于该大小时不校验压缩比，避免小请求体因头部开销误判
+	ratioCheckMinSize = 1 << 20
+)
+
+var (
+	// ErrRequestBodyTooLarge 解压后的请求体超过大小或压缩比限制
+	ErrRequestBodyTooLarge = errors.NewWithStatus("请求体过大", http.StatusRequestEntityTooLarge)
+	// ErrRequestBodyEncoding 请求体无法按Content-Encoding解压
+	ErrRequestBodyEncoding = errors.NewWithStatus("请求体解压失败", http.StatusBadRequest)
+)
+
+type decompressOptions struct {
+	maxSize  int64
+	maxRatio int64
+}
+
+// DecompressOption ...
+type DecompressOption func(*decompressOptions)
+
+// DecompressMaxSize 解压后请求体的最大字节数，<=0时使用默认值
+func DecompressMaxSize(n int64) DecompressOption {
+	return func(o *decompressOptions) {
+		if n > 0 {
+			o.maxSize = n
+		}
+	}
+}
+
+// DecompressMaxRatio 解压后与压缩前大小的最大比值，<=0时使用默认值
+func DecompressMaxRatio(n int64) DecompressOption {
+	return func(o *decompressOptions) {
+		if n > 0 {
+			o.maxRatio = n
+		}
+	}
+}
+
+// Decompress 按Content-Encoding解压请求体，支持gzip、deflate、br、zstd及多层编码；
+// 不支持的编码返回415，格式错误返回400，超过限制时读取请求体返回ErrRequestBodyTooLarge
+func Decompress(opts ...DecompressOption) gin.HandlerFunc {
+	o := &decompressOptions{
+		maxSize:  defaultDecompressMaxSize,
+		maxRatio: defaultDecompressMaxRatio,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return func(c *gin.Context) {
+		encodings := contentEncodings(c.GetHeader("Content-Encoding"))
+		if len(encodings) == 0 || c.Request.Body == nil || c.Request.Body == http.NoBody {
+			c.Next()
+			return
+		}
+
+		compressed := &countingReader{r: c.Request.Body}
+		body := &decompressBody{
+			orig:       c.Request.Body,
+			compressed: compressed,
+			maxSize:    o.maxSize,
+			maxRatio:   o.maxRatio,
+		}
+		var r io.Reader = compressed
+		// 编码按应用顺序列出，解压时逆序
+		for i := len(encodings) - 1; i >= 0; i-- {
+			dr, status, err := newDecoder(encodings[i], r, o.maxSize)
+			if err != nil {
+				body.Close()
+				msg := ErrRequestBodyEncoding.Error()
+				if status == http.StatusUnsupportedMediaType {
+					msg = "不支持的Content-Encoding: " + encodings[i]
+				}
+				c.AbortWithStatusJSON(status, resp.Response{
+					Code:      resp.ERROR,
+					Message:   msg,
+					Result:    msg,
+					RequestID: requestid.FromContext(c.Request.Context()),
+				})
+				return
+			}
+			body.closers = append(body.closers, dr)
+			r = dr
+		}
+		body.r = r
+
+		c.Request.Body = body
+		c.Request.Header.Del("Content-Encoding")
+		c.Request.Header.Del("Content-Length")
+		c.Request.ContentLength = -1
+		c.Next()
+	}
+}
+
+func contentEncodings(header string) []string {
+	var encodings []string
+	for _, e := range strings.Split(header, ",") {
+		e = strings.ToLower(strings.TrimSpace(e))
+		if e != "" && e != "identity" {
+			encodings = append(encodings, e)
+		}
+	}
+	return encodings
+}
+
+func newDecoder(encoding string, r io.Reader, maxSize int64) (io.ReadCloser, int, error) {
+	switch encoding {
+	case "gzip", "x-gzip":
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, http.StatusBadRequest, err
+		}
+		return zr, 0, nil
+	case "deflate":
+		zr, err := zlib.NewReader(r)
+		if err != nil {
+			return nil, http.StatusBadRequest, err
+		}
+		return zr, 0, nil
+	case "br":
+		return io.NopCloser(brotli.NewReader(r)), 0, nil
+	case "zstd":
+		zr, err := zstd.NewReader(r,
+			zstd.WithDecoderConcurrency(1),
+			zstd.WithDecoderMaxMemory(uint64(maxSize)),
+		)
+		if err != nil {
+			return nil, http.StatusBadRequest, err
+		}
+		return zr.IOReadCloser(), 0, nil
+	default:
+		return nil, http.StatusUnsupportedMediaType, errors.Errorf("unsupported content encoding %s", encoding)
+	}
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+// decompressBody 限制解压后的大小与压缩比，解压错误统一返回ErrRequestBodyEncoding
+type decompressBody struct {
+	r          io.Reader
+	orig       io.ReadCloser
+	closers    []io.Closer
+	compressed *countingReader
+	n          int64
+	maxSize    int64
+	maxRatio   int64
+	err        error
+}
+
+func (b *decompressBody) Read(p []byte) (int, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+	n, err := b.r.Read(p)
+	b.n += int64(n)
+	if b.n > b.maxSize ||
+		(b.n > ratioCheckMinSize && b.n > b.compressed.n*b.maxRatio) {
+		b.err = ErrRequestBodyTooLarge
+		return 0, b.err
+	}
+	if err != nil && err != io.EOF {
+		b.err = ErrRequestBodyEncoding
+		// zstd在解码器内部先校验了大小
+		if errors.Is(err, zstd.ErrDecoderSizeExceeded) || errors.Is(err, zstd.ErrWindowSizeExceeded) {
+			b.err = ErrRequestBodyTooLarge
+		}
+		return n, b.err
+	}
+	return n, err
+}
+
+func (b *decompressBody) Close() error {
+	for i := len(b.closers) - 1; i >= 0; i-- {
+		_ = b.closers[i].Close()
+	}
+	return b.orig.Close()
+}
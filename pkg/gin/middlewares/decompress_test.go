@@ -0,0 +1,170 @@
+package middlewares
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"lovebox/pkg/errors"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gin-gonic/gin"
+	"github.com/klauspost/compress/zstd"
+)
+
+func gzipBytes(t *testing.T, data []byte) []byte {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	w := gzip.NewWriter(buf)
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func deflateBytes(t *testing.T, data []byte) []byte {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	w := zlib.NewWriter(buf)
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func brotliBytes(t *testing.T, data []byte) []byte {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	w := brotli.NewWriter(buf)
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func zstdBytes(t *testing.T, data []byte) []byte {
+	t.Helper()
+	w, err := zstd.NewWriter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	return w.EncodeAll(data, nil)
+}
+
+// serveDecompress 读取解压后的请求体并原样返回，读取失败时按错误的状态码响应
+func serveDecompress(encoding string, body []byte, opts ...DecompressOption) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Decompress(opts...))
+	router.POST("/", func(c *gin.Context) {
+		data, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			status := http.StatusInternalServerError
+			var sc errors.StatusCodeGetter
+			if errors.As(err, &sc) {
+				status = sc.HTTPStatusCode()
+			}
+			c.String(status, err.Error())
+			return
+		}
+		c.Data(http.StatusOK, "application/octet-stream", data)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	if encoding != "" {
+		req.Header.Set("Content-Encoding", encoding)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestDecompress(t *testing.T) {
+	plain := []byte(`{"username":"bob","password":"secret"}`)
+
+	tests := []struct {
+		name     string
+		encoding string
+		body     []byte
+		status   int
+		want     []byte
+	}{
+		{name: "no encoding", body: plain, status: http.StatusOK, want: plain},
+		{name: "identity", encoding: "identity", body: plain, status: http.StatusOK, want: plain},
+		{name: "gzip", encoding: "gzip", body: gzipBytes(t, plain), status: http.StatusOK, want: plain},
+		{name: "x-gzip upper case", encoding: "X-GZIP", body: gzipBytes(t, plain), status: http.StatusOK, want: plain},
+		{name: "deflate", encoding: "deflate", body: deflateBytes(t, plain), status: http.StatusOK, want: plain},
+		{name: "br", encoding: "br", body: brotliBytes(t, plain), status: http.StatusOK, want: plain},
+		{name: "zstd", encoding: "zstd", body: zstdBytes(t, plain), status: http.StatusOK, want: plain},
+		{
+			name:     "multiple encodings in applied order",
+			encoding: "deflate, gzip",
+			body:     gzipBytes(t, deflateBytes(t, plain)),
+			status:   http.StatusOK,
+			want:     plain,
+		},
+		{name: "malformed gzip header", encoding: "gzip", body: []byte("not gzip"), status: http.StatusBadRequest},
+		{name: "truncated gzip", encoding: "gzip", body: gzipBytes(t, plain)[:20], status: http.StatusBadRequest},
+		{name: "malformed deflate", encoding: "deflate", body: []byte("not deflate"), status: http.StatusBadRequest},
+		{name: "malformed zstd", encoding: "zstd", body: []byte("not zstd"), status: http.StatusBadRequest},
+		{name: "unsupported encoding", encoding: "compress", body: plain, status: http.StatusUnsupportedMediaType},
+		{name: "unsupported layer", encoding: "gzip, lzma", body: plain, status: http.StatusUnsupportedMediaType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serveDecompress(tt.encoding, tt.body)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d body=%s", w.Code, tt.status, w.Body.String())
+			}
+			if tt.want != nil && !bytes.Equal(w.Body.Bytes(), tt.want) {
+				t.Errorf("body = %q, want %q", w.Body.Bytes(), tt.want)
+			}
+		})
+	}
+}
+
+func TestDecompressLimits(t *testing.T) {
+	// bomb压缩比很高，用于测试压缩比限制；text用于测试大小限制
+	bomb := bytes.Repeat([]byte("a"), 8<<20)
+	text := []byte(strings.Repeat("lovebox decompress limit ", 4096))
+
+	tests := []struct {
+		name     string
+		encoding string
+		body     []byte
+		opts     []DecompressOption
+		status   int
+	}{
+		{name: "within size", encoding: "gzip", body: gzipBytes(t, text), opts: []DecompressOption{DecompressMaxSize(int64(len(text)))}, status: http.StatusOK},
+		{name: "gzip over size", encoding: "gzip", body: gzipBytes(t, text), opts: []DecompressOption{DecompressMaxSize(int64(len(text)) - 1)}, status: http.StatusRequestEntityTooLarge},
+		{name: "br over size", encoding: "br", body: brotliBytes(t, text), opts: []DecompressOption{DecompressMaxSize(1024)}, status: http.StatusRequestEntityTooLarge},
+		{name: "zstd over size", encoding: "zstd", body: zstdBytes(t, text), opts: []DecompressOption{DecompressMaxSize(1024)}, status: http.StatusRequestEntityTooLarge},
+		{name: "gzip over ratio", encoding: "gzip", body: gzipBytes(t, bomb), status: http.StatusRequestEntityTooLarge},
+		{name: "zstd over ratio", encoding: "zstd", body: zstdBytes(t, bomb), status: http.StatusRequestEntityTooLarge},
+		{name: "nested gzip over ratio", encoding: "gzip, gzip", body: gzipBytes(t, gzipBytes(t, bomb)), status: http.StatusRequestEntityTooLarge},
+		{name: "ratio allowed when raised", encoding: "gzip", body: gzipBytes(t, bomb), opts: []DecompressOption{DecompressMaxRatio(10000)}, status: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serveDecompress(tt.encoding, tt.body, tt.opts...)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d body=%.100s", w.Code, tt.status, w.Body.String())
+			}
+		})
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"net/http"
 
 	"lovebox/pkg/errors"
+	"lovebox/pkg/logger"
+	"lovebox/pkg/requestid"
 	"lovebox/pkg/resp"
 
 	"github.com/gin-gonic/gin"
@@ -46,7 +48,9 @@ func (h *ErrorHandler) HandleErrors(c *gin.Context) {
 	if errorToPrint == nil {
 		return
 	}
-	h.log.Errorf("Error captured, stacktrace: %+v", errorToPrint.Err)
+	ctx := c.Request.Context()
+	logger.WithContext(ctx, h.log).Errorf("Error captured, stacktrace: %+v", errorToPrint.Err)
+	requestID := requestid.FromContext(ctx)
 
 	al := c.Request.Header.Get("Accept-Language")
 	lang, _ := c.Request.Cookie("lang")
@@ -60,9 +64,10 @@ func (h *ErrorHandler) HandleErrors(c *gin.Context) {
 		// 	"errors":  errs.Translate(trans),
 		// })
 		c.JSON(http.StatusOK, resp.Response{
-			Code:    1,
-			Message: resp.PARAM_INVALID,
-			Result:  errorToPrint.Error(),
+			Code:      1,
+			Message:   resp.PARAM_INVALID,
+			Result:    errorToPrint.Error(),
+			RequestID: requestID,
 		})
 		return
 	}
@@ -73,9 +78,10 @@ func (h *ErrorHandler) HandleErrors(c *gin.Context) {
 			msg = t.Translate(trans)
 		}
 		data := resp.Response{
-			Code:    1,
-			Message: msg,
-			Result:  msg,
+			Code:      1,
+			Message:   msg,
+			Result:    msg,
+			RequestID: requestID,
 		}
 		// if t, ok := errorToPrint.Err.(errors.DetailGetter); ok {
 		// 	data["details"] = t.Details()
@@ -92,8 +98,9 @@ func (h *ErrorHandler) HandleErrors(c *gin.Context) {
 	// 	}
 	// }
 	c.JSON(http.StatusOK, resp.Response{
-		Code:    1,
-		Message: errorToPrint.Error(),
-		Result:  errorToPrint.Error(),
+		Code:      1,
+		Message:   errorToPrint.Error(),
+		Result:    errorToPrint.Error(),
+		RequestID: requestID,
 	})
 }
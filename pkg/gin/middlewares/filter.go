@@ -0,0 +1,274 @@
+package middlewares
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"lovebox/pkg/errors"
+	"lovebox/pkg/utils"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// queryTag 模型字段的查询白名单，如`query:"filter,sort"`、`query:"filter=eq|in"`；
+// 只写filter时允许eq、ne、like、in、gt、lt、between，contains（json包含）需显式声明
+const queryTag = "query"
+
+// 过滤操作
+const (
+	FilterEq       = "eq"
+	FilterNe       = "ne"
+	FilterLike     = "like"
+	FilterIn       = "in"
+	FilterGt       = "gt"
+	FilterLt       = "lt"
+	FilterBetween  = "between"
+	FilterContains = "contains"
+)
+
+var defaultFilterOps = []string{FilterEq, FilterNe, FilterLike, FilterIn, FilterGt, FilterLt, FilterBetween}
+
+var dateLayouts = []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02"}
+
+// ErrInvalidQueryFields 不在白名单内或值不合法的过滤、排序字段
+func ErrInvalidQueryFields(fields []string) error {
+	return errors.NewWithStatusDetail(
+		"查询参数不合法: "+strings.Join(fields, ", "),
+		http.StatusBadRequest,
+		fields,
+	)
+}
+
+// FilterParam 过滤参数：filter[field][op]=value，省略op时为eq；
+// in的多个值与between的上下限用逗号分隔
+type FilterParam struct {
+	Field  string
+	Op     string
+	Values []string
+}
+
+func (f FilterParam) String() string {
+	return "filter[" + f.Field + "][" + f.Op + "]"
+}
+
+// parseFilterParams 解析查询参数中的filter[...]，格式错误的参数名原样返回
+func parseFilterParams(query url.Values) ([]FilterParam, []string) {
+	var params []FilterParam
+	var invalid []string
+	for key, values := range query {
+		if !strings.HasPrefix(key, "filter[") {
+			continue
+		}
+		parts := strings.Split(strings.TrimSuffix(strings.TrimPrefix(key, "filter["), "]"), "][")
+		if !strings.HasSuffix(key, "]") || len(parts) > 2 || parts[0] == "" {
+			invalid = append(invalid, key)
+			continue
+		}
+		param := FilterParam{Field: parts[0], Op: FilterEq}
+		if len(parts) == 2 {
+			param.Op = strings.ToLower(parts[1])
+		}
+		for _, v := range values {
+			if param.Op == FilterIn || param.Op == FilterBetween {
+				param.Values = append(param.Values, strings.Split(v, ",")...)
+			} else {
+				param.Values = append(param.Values, v)
+			}
+		}
+		params = append(params, param)
+	}
+	// 保证生成的sql稳定
+	sort.Slice(params, func(i, j int) bool {
+		return params[i].String() < params[j].String()
+	})
+	sort.Strings(invalid)
+	return params, invalid
+}
+
+// fieldRule 字段的查询规则
+type fieldRule struct {
+	filterOps map[string]bool
+	sortable  bool
+}
+
+func parseFieldRule(field *schema.Field) fieldRule {
+	rule := fieldRule{}
+	tag, ok := field.Tag.Lookup(queryTag)
+	if !ok {
+		return rule
+	}
+	for _, item := range strings.Split(tag, ",") {
+		name, ops, _ := strings.Cut(strings.TrimSpace(item), "=")
+		switch name {
+		case "sort":
+			rule.sortable = true
+		case "filter":
+			list := defaultFilterOps
+			if ops != "" {
+				list = strings.Split(ops, "|")
+			}
+			rule.filterOps = make(map[string]bool, len(list))
+			for _, op := range list {
+				rule.filterOps[op] = true
+			}
+		}
+	}
+	return rule
+}
+
+// lookupField 按json名（camelCase）或列名查找字段
+func lookupField(sch *schema.Schema, name string) *schema.Field {
+	field := sch.LookUpField(utils.ToSnakeCase(name))
+	if field == nil {
+		field = sch.LookUpField(name)
+	}
+	if field == nil || field.DBName == "" {
+		return nil
+	}
+	return field
+}
+
+// applyFilters 校验过滤参数并生成参数化的where条件
+func (p *Pagination) applyFilters(query *gorm.DB, sch *schema.Schema) (*gorm.DB, []string) {
+	invalid := append([]string{}, p.InvalidFilters...)
+	for _, param := range p.Filters {
+		field := lookupField(sch, param.Field)
+		if field == nil || !parseFieldRule(field).filterOps[param.Op] {
+			invalid = append(invalid, param.String())
+			continue
+		}
+		expr, err := filterExpr(field, param)
+		if err != nil {
+			invalid = append(invalid, param.String())
+			continue
+		}
+		query = query.Where(expr)
+	}
+	return query, invalid
+}
+
+func filterExpr(field *schema.Field, param FilterParam) (clause.Expression, error) {
+	column := clause.Column{Table: clause.CurrentTable, Name: field.DBName}
+	switch param.Op {
+	case FilterIn:
+		values := make([]interface{}, 0, len(param.Values))
+		for _, raw := range param.Values {
+			v, err := filterValue(field, raw, false)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+		}
+		if len(values) == 0 {
+			return nil, ErrInvalidFilterValue
+		}
+		return clause.IN{Column: column, Values: values}, nil
+	case FilterBetween:
+		if len(param.Values) != 2 {
+			return nil, ErrInvalidFilterValue
+		}
+		from, err := filterValue(field, param.Values[0], false)
+		if err != nil {
+			return nil, err
+		}
+		to, err := filterValue(field, param.Values[1], true)
+		if err != nil {
+			return nil, err
+		}
+		return clause.Expr{SQL: "? BETWEEN ? AND ?", Vars: []interface{}{column, from, to}}, nil
+	}
+
+	if len(param.Values) != 1 {
+		return nil, ErrInvalidFilterValue
+	}
+	raw := param.Values[0]
+	switch param.Op {
+	case FilterLike:
+		r := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+		return clause.Like{Column: column, Value: "%" + r.Replace(raw) + "%"}, nil
+	case FilterContains:
+		// json数组或对象包含该值，值不是合法json时按字符串处理
+		doc := []byte(raw)
+		if !json.Valid(doc) {
+			doc, _ = json.Marshal(raw)
+		}
+		return clause.Expr{SQL: "JSON_CONTAINS(?, ?)", Vars: []interface{}{column, string(doc)}}, nil
+	}
+
+	v, err := filterValue(field, raw, false)
+	if err != nil {
+		return nil, err
+	}
+	switch param.Op {
+	case FilterEq:
+		return clause.Eq{Column: column, Value: v}, nil
+	case FilterNe:
+		return clause.Neq{Column: column, Value: v}, nil
+	case FilterGt:
+		return clause.Gt{Column: column, Value: v}, nil
+	case FilterLt:
+		return clause.Lt{Column: column, Value: v}, nil
+	}
+	return nil, ErrInvalidFilterValue
+}
+
+// ErrInvalidFilterValue 过滤值无法转换为字段类型
+var ErrInvalidFilterValue = errors.NewWithStatus("过滤值不合法", http.StatusBadRequest)
+
+// filterValue 按字段类型转换过滤值，upper为true时只有日期的上限取当天结束
+func filterValue(field *schema.Field, raw string, upper bool) (interface{}, error) {
+	t := field.FieldType
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == reflect.TypeOf(time.Time{}) || field.DataType == schema.Time {
+		for _, layout := range dateLayouts {
+			v, err := time.ParseInLocation(layout, raw, time.Local)
+			if err != nil {
+				continue
+			}
+			if upper && layout == "2006-01-02" {
+				v = v.Add(24*time.Hour - time.Nanosecond)
+			}
+			return v, nil
+		}
+		return nil, ErrInvalidFilterValue
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return raw, nil
+	case reflect.Bool:
+		return strconv.ParseBool(raw)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.ParseInt(raw, 10, 64)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.ParseUint(raw, 10, 64)
+	case reflect.Float32, reflect.Float64:
+		return strconv.ParseFloat(raw, 64)
+	}
+	return nil, ErrInvalidFilterValue
+}
+
+// sortColumns 校验排序字段是否在白名单内
+func (p *Pagination) sortColumns(sch *schema.Schema) ([]keysetColumn, []string) {
+	var columns []keysetColumn
+	var invalid []string
+	for _, s := range p.Sorts() {
+		field := lookupField(sch, s.Field)
+		if field == nil || !parseFieldRule(field).sortable {
+			invalid = append(invalid, "sort["+s.Field+"]")
+			continue
+		}
+		columns = append(columns, keysetColumn{field: field, desc: s.Desc})
+	}
+	return columns, invalid
+}
@@ -0,0 +1,260 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"lovebox/pkg/errors"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+func TestParseFilterParams(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		params  []FilterParam
+		invalid []string
+	}{
+		{
+			name:   "default op",
+			query:  "filter[name]=bob",
+			params: []FilterParam{{Field: "name", Op: FilterEq, Values: []string{"bob"}}},
+		},
+		{
+			name:   "op is lower cased",
+			query:  "filter[name][LIKE]=bo",
+			params: []FilterParam{{Field: "name", Op: FilterLike, Values: []string{"bo"}}},
+		},
+		{
+			name:   "in splits values",
+			query:  "filter[id][in]=1,2&filter[id][in]=3",
+			params: []FilterParam{{Field: "id", Op: FilterIn, Values: []string{"1", "2", "3"}}},
+		},
+		{
+			name:   "between splits bounds",
+			query:  "filter[createdAt][between]=2022-01-01,2022-01-31",
+			params: []FilterParam{{Field: "createdAt", Op: FilterBetween, Values: []string{"2022-01-01", "2022-01-31"}}},
+		},
+		{
+			name:   "eq keeps commas",
+			query:  "filter[name]=a,b",
+			params: []FilterParam{{Field: "name", Op: FilterEq, Values: []string{"a,b"}}},
+		},
+		{
+			name:  "sorted by key",
+			query: "filter[score][gt]=1&filter[name]=bob",
+			params: []FilterParam{
+				{Field: "name", Op: FilterEq, Values: []string{"bob"}},
+				{Field: "score", Op: FilterGt, Values: []string{"1"}},
+			},
+		},
+		{
+			name:    "malformed keys",
+			query:   "filter[name=1&filter[]=1&filter[a][b][c]=1&filter[name]x=1&filter=1",
+			invalid: []string{"filter[]", "filter[a][b][c]", "filter[name", "filter[name]x"},
+		},
+		{
+			name:  "other params are ignored",
+			query: "limit=10&sort=name&filters=1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			params, invalid := parseFilterParams(values)
+			if !reflect.DeepEqual(params, tt.params) {
+				t.Errorf("params = %+v, want %+v", params, tt.params)
+			}
+			if !reflect.DeepEqual(invalid, tt.invalid) {
+				t.Errorf("invalid = %v, want %v", invalid, tt.invalid)
+			}
+		})
+	}
+}
+
+func TestGetPageRejectsInvalidFields(t *testing.T) {
+	db := openTestDB(t)
+
+	tests := []struct {
+		name   string
+		query  string
+		fields []string
+	}{
+		{
+			name:   "field not whitelisted",
+			query:  "filter[secret]=x",
+			fields: []string{"filter[secret][eq]"},
+		},
+		{
+			name:   "unknown field",
+			query:  "filter[password]=x",
+			fields: []string{"filter[password][eq]"},
+		},
+		{
+			name:   "op not whitelisted",
+			query:  "filter[name][gt]=a&filter[id][like]=1",
+			fields: []string{"filter[id][like]", "filter[name][gt]"},
+		},
+		{
+			name:   "contains requires explicit op",
+			query:  "filter[score][contains]=1",
+			fields: []string{"filter[score][contains]"},
+		},
+		{
+			name:   "value does not match field type",
+			query:  "filter[score][gt]=abc&filter[createdAt][between]=2022-01-01",
+			fields: []string{"filter[createdAt][between]", "filter[score][gt]"},
+		},
+		{
+			name:   "sort not whitelisted",
+			query:  "sort=secret",
+			fields: []string{"sort[secret]"},
+		},
+		{
+			name:   "malformed keys are listed first",
+			query:  "filter[name=1&filter[secret]=x&sort=score|secret:desc",
+			fields: []string{"filter[name", "filter[secret][eq]", "sort[secret]"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestPagination(t, tt.query)
+			items := []*queryTestItem{}
+			_, err := p.GetPage(db.Model(&queryTestItem{}), &items)
+			if err == nil {
+				t.Fatal("want error")
+			}
+			var status errors.StatusCodeGetter
+			if !errors.As(err, &status) || status.HTTPStatusCode() != http.StatusBadRequest {
+				t.Fatalf("err = %v, want status 400", err)
+			}
+			var details errors.DetailGetter
+			if !errors.As(err, &details) || !reflect.DeepEqual(details.Details(), tt.fields) {
+				t.Errorf("fields = %v, want %v", details.Details(), tt.fields)
+			}
+		})
+	}
+}
+
+func TestGetPageFilters(t *testing.T) {
+	db := openTestDB(t)
+	day := time.Date(2022, 1, 31, 0, 0, 0, 0, time.Local)
+	items := []*queryTestItem{
+		{Name: "50%_off", Score: 1, CreatedAt: day.Add(-48 * time.Hour)},
+		{Name: "500 off", Score: 2, CreatedAt: day.Add(-time.Hour)},
+		{Name: "50x_off", Score: 3, CreatedAt: day.Add(12 * time.Hour)},
+		{Name: "bob", Score: 4, CreatedAt: day.Add(36 * time.Hour)},
+	}
+	if err := db.Create(items).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		names []string
+	}{
+		{name: "eq", query: "filter[name]=bob", names: []string{"bob"}},
+		{name: "in", query: "filter[id][in]=1,4", names: []string{"50%_off", "bob"}},
+		{name: "gt and lt", query: "filter[score][gt]=1&filter[score][lt]=4", names: []string{"500 off", "50x_off"}},
+		{name: "like matches substring", query: "filter[name][like]=off", names: []string{"50%_off", "500 off", "50x_off"}},
+		{name: "between date includes upper day", query: "filter[createdAt][between]=2022-01-30,2022-01-31", names: []string{"500 off", "50x_off"}},
+		{name: "between datetime is exact", query: "filter[createdAt][between]=2022-01-30,2022-01-31%2000:00:00", names: []string{"500 off"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestPagination(t, tt.query)
+			result := []*queryTestItem{}
+			if _, err := p.GetPage(db.Model(&queryTestItem{}), &result); err != nil {
+				t.Fatal(err)
+			}
+			names := []string{}
+			for _, item := range result {
+				names = append(names, item.Name)
+			}
+			if !reflect.DeepEqual(names, tt.names) {
+				t.Errorf("names = %v, want %v", names, tt.names)
+			}
+		})
+	}
+}
+
+func TestFilterExprLikeEscapes(t *testing.T) {
+	field := testSchemaField(t, "name")
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{raw: "off", want: "%off%"},
+		{raw: "50%", want: `%50\%%`},
+		{raw: "a_b", want: `%a\_b%`},
+		{raw: `c:\dir`, want: `%c:\\dir%`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			expr, err := filterExpr(field, FilterParam{Field: "name", Op: FilterLike, Values: []string{tt.raw}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			like, ok := expr.(clause.Like)
+			if !ok || like.Value != tt.want {
+				t.Errorf("expr = %#v, want value %q", expr, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterValueDateUpperBound(t *testing.T) {
+	field := testSchemaField(t, "created_at")
+	tests := []struct {
+		raw   string
+		upper bool
+		want  time.Time
+	}{
+		{raw: "2022-01-31", upper: false, want: time.Date(2022, 1, 31, 0, 0, 0, 0, time.Local)},
+		{raw: "2022-01-31", upper: true, want: time.Date(2022, 1, 31, 23, 59, 59, 999999999, time.Local)},
+		{raw: "2022-01-31 08:30:00", upper: true, want: time.Date(2022, 1, 31, 8, 30, 0, 0, time.Local)},
+		{raw: "2022-01-31T08:30:00Z", upper: true, want: time.Date(2022, 1, 31, 8, 30, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			v, err := filterValue(field, tt.raw, tt.upper)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := v.(time.Time); !got.Equal(tt.want) {
+				t.Errorf("filterValue = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := filterValue(field, "31/01/2022", true); !errors.Is(err, ErrInvalidFilterValue) {
+		t.Errorf("err = %v, want ErrInvalidFilterValue", err)
+	}
+}
+
+func testSchemaField(t *testing.T, name string) *schema.Field {
+	t.Helper()
+	stmt := &gorm.Statement{DB: openTestDB(t)}
+	if err := stmt.Parse(&queryTestItem{}); err != nil {
+		t.Fatal(err)
+	}
+	field := lookupField(stmt.Schema, name)
+	if field == nil {
+		t.Fatalf("field %s not found", name)
+	}
+	if !strings.EqualFold(field.DBName, name) {
+		t.Fatalf("field %s resolved to %s", name, field.DBName)
+	}
+	return field
+}
@@ -0,0 +1,264 @@
+package middlewares
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"lovebox/pkg/errors"
+	"lovebox/pkg/logger"
+	"lovebox/pkg/requestid"
+	"lovebox/pkg/resp"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/go-redsync/redsync/v4"
+	"go.uber.org/zap"
+)
+
+const (
+	// IdempotencyKeyHeader 客户端为每个业务操作生成的唯一键，重试时保持不变
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader 响应来自已保存的结果
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLength = 255
+)
+
+// replayExcludedHeaders 与本次请求相关、不应重放的响应头
+var replayExcludedHeaders = map[string]bool{
+	"Content-Length":   true,
+	"Content-Encoding": true,
+	"Vary":             true,
+	"Date":             true,
+	"Refresh-Token":    true,
+	requestid.Header:   true,
+}
+
+type idempotencyOptions struct {
+	prefix      string
+	ttl         time.Duration
+	lockTimeout time.Duration
+}
+
+// IdempotencyOption ...
+type IdempotencyOption func(*idempotencyOptions)
+
+// IdempotencyPrefix redis键前缀
+func IdempotencyPrefix(prefix string) IdempotencyOption {
+	return func(o *idempotencyOptions) {
+		o.prefix = prefix
+	}
+}
+
+// IdempotencyTTL 保存响应的时长，过期后同一个键视为新请求
+func IdempotencyTTL(ttl time.Duration) IdempotencyOption {
+	return func(o *idempotencyOptions) {
+		if ttl > 0 {
+			o.ttl = ttl
+		}
+	}
+}
+
+// IdempotencyLockTimeout 处理请求时持有锁的最长时间，应大于接口的最长处理时间
+func IdempotencyLockTimeout(timeout time.Duration) IdempotencyOption {
+	return func(o *idempotencyOptions) {
+		if timeout > 0 {
+			o.lockTimeout = timeout
+		}
+	}
+}
+
+// idempotencyRecord 保存的响应
+type idempotencyRecord struct {
+	Fingerprint string      `json:"fingerprint"`
+	Status      int         `json:"status"`
+	Header      http.Header `json:"header"`
+	Body        []byte      `json:"body"`
+}
+
+// NewIdempotency 带Idempotency-Key的POST/PUT/PATCH/DELETE请求：
+// 首次请求成功后保存响应，之后同一个键的请求直接重放；
+// 同一个键用于不同的请求（方法、路径、参数或请求体不同）返回422，处理中的重复请求返回409。
+// 出错或5xx的响应不保存，客户端可以用同一个键重试。登录后的接口按账号区分键
+func NewIdempotency(
+	redisClient *redis.Client,
+	redSyncClient *redsync.Redsync,
+	opts ...IdempotencyOption,
+) gin.HandlerFunc {
+	o := &idempotencyOptions{
+		prefix:      "idempotency",
+		ttl:         24 * time.Hour,
+		lockTimeout: 30 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	log := zap.S().With("module", "idempotency")
+
+	abort := func(c *gin.Context, status int, msg string) {
+		c.AbortWithStatusJSON(status, resp.Response{
+			Code:      resp.ERROR,
+			Message:   msg,
+			Result:    msg,
+			RequestID: requestid.FromContext(c.Request.Context()),
+		})
+	}
+
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		default:
+			c.Next()
+			return
+		}
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			abort(c, http.StatusBadRequest, resp.IDEMPOTENCY_KEY_INVALID)
+			return
+		}
+
+		ctx := c.Request.Context()
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			status := http.StatusBadRequest
+			if getter, ok := err.(errors.StatusCodeGetter); ok {
+				status = getter.HTTPStatusCode()
+			}
+			abort(c, status, err.Error())
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		fingerprint := idempotencyFingerprint(c.Request, body)
+
+		scope := "anonymous"
+		if id := c.GetUint("id"); id > 0 {
+			scope = strconv.FormatUint(uint64(id), 10)
+		}
+		hash := sha256.Sum256([]byte(key))
+		recordKey := o.prefix + ":" + scope + ":" + hex.EncodeToString(hash[:])
+
+		load := func() (*idempotencyRecord, error) {
+			data, err := redisClient.Get(ctx, recordKey).Bytes()
+			if err == redis.Nil {
+				return nil, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			record := &idempotencyRecord{}
+			return record, json.Unmarshal(data, record)
+		}
+		replay := func(record *idempotencyRecord) {
+			if record.Fingerprint != fingerprint {
+				abort(c, http.StatusUnprocessableEntity, resp.IDEMPOTENCY_KEY_REUSED)
+				return
+			}
+			for k, values := range record.Header {
+				c.Writer.Header()[k] = values
+			}
+			c.Header(IdempotentReplayedHeader, "true")
+			c.Status(record.Status)
+			_, _ = c.Writer.Write(record.Body)
+			c.Abort()
+		}
+
+		record, err := load()
+		if err != nil {
+			logger.WithContext(ctx, log).Errorf("Load idempotency record err=%v", err)
+			abort(c, http.StatusInternalServerError, resp.SERVER_ERROR)
+			return
+		}
+		if record != nil {
+			replay(record)
+			return
+		}
+
+		mutex := redSyncClient.NewMutex(
+			recordKey+":lock",
+			redsync.WithExpiry(o.lockTimeout),
+			redsync.WithTries(1),
+		)
+		if err := mutex.LockContext(ctx); err != nil {
+			abort(c, http.StatusConflict, resp.IDEMPOTENCY_IN_PROGRESS)
+			return
+		}
+		defer func() {
+			_, _ = mutex.Unlock()
+		}()
+		// 获得锁前另一个请求可能刚处理完
+		if record, err = load(); err == nil && record != nil {
+			replay(record)
+			return
+		}
+
+		w := &recordingWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Next()
+
+		status := w.Status()
+		if len(c.Errors) > 0 || status >= http.StatusInternalServerError {
+			return
+		}
+		record = &idempotencyRecord{
+			Fingerprint: fingerprint,
+			Status:      status,
+			Header:      make(http.Header),
+			Body:        w.body.Bytes(),
+		}
+		for k, values := range w.Header() {
+			if !replayExcludedHeaders[k] {
+				record.Header[k] = values
+			}
+		}
+		// 客户端断开时请求已处理完成，仍需保存结果
+		saveCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		data, err := json.Marshal(record)
+		if err == nil {
+			err = redisClient.Set(saveCtx, recordKey, data, o.ttl).Err()
+		}
+		if err != nil {
+			logger.WithContext(ctx, log).Errorf("Save idempotency record err=%v", err)
+		}
+	}
+}
+
+// idempotencyFingerprint 方法、路径、查询参数与请求体的摘要
+func idempotencyFingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(r.Method))
+	h.Write([]byte{0})
+	h.Write([]byte(r.URL.Path))
+	h.Write([]byte{0})
+	h.Write([]byte(r.URL.RawQuery))
+	h.Write([]byte{0})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// recordingWriter 记录写出的响应体
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
@@ -32,17 +32,14 @@ func NewIPAuthMiddleware(ipRanges []string) gin.HandlerFunc {
 	}
 
 	return func(c *gin.Context) {
-		clientIP := c.ClientIP()
-		if clientIP == "" {
-			c.Next()
-			return
-		}
-
-		ip := net.ParseIP(clientIP)
+		// unix socket等无法确定客户端地址的请求一律拒绝
+		ip := net.ParseIP(c.ClientIP())
 		contains := false
-		for _, m := range masks {
-			if m.Contains(ip) {
-				contains = true
+		if ip != nil {
+			for _, m := range masks {
+				if m.Contains(ip) {
+					contains = true
+				}
 			}
 		}
 
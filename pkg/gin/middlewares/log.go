@@ -5,6 +5,8 @@ import (
 	"math"
 	"time"
 
+	"lovebox/pkg/logger"
+
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
@@ -23,7 +25,7 @@ func Logger(log *zap.SugaredLogger) gin.HandlerFunc {
 		statusCode := c.Writer.Status()
 		clientIP := c.ClientIP()
 
-		entry := log.With(
+		entry := logger.WithContext(c.Request.Context(), log).With(
 			"host", c.Request.Host,
 			"status", statusCode,
 			"latency", latency, // time to process
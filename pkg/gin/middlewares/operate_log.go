@@ -1,7 +1,12 @@
 package middlewares
 
 import (
+	"context"
+	"errors"
+	"sync"
+
 	"lovebox/models"
+	"lovebox/pkg/analytics"
 	"lovebox/pkg/database"
 	"lovebox/pkg/utils"
 	"lovebox/services/system/operate_log"
@@ -10,62 +15,122 @@ import (
 	"go.uber.org/zap"
 )
 
-func NewOperateLogger(log *zap.SugaredLogger, mysqlClient *database.Client) gin.HandlerFunc {
+// OperateLogger 请求结束后异步写入操作日志，Shutdown时等待写入完成
+type OperateLogger struct {
+	log             *zap.SugaredLogger
+	mysqlClient     *database.Client
+	analyticsClient *analytics.Client
+
+	wg sync.WaitGroup
+}
+
+func NewOperateLogger(
+	log *zap.SugaredLogger,
+	mysqlClient *database.Client,
+	analyticsClient *analytics.Client,
+) *OperateLogger {
+	return &OperateLogger{
+		log:             log,
+		mysqlClient:     mysqlClient,
+		analyticsClient: analyticsClient,
+	}
+}
+
+// Middleware 在请求内读取gin.Context（请求结束后会被复用），再异步写入
+func (o *OperateLogger) Middleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Next()
 
+		operateLog, err := o.collect(c)
+		if err != nil {
+			o.log.Errorf("Collect operateLog err %v", err)
+			return
+		}
+		if operateLog == nil {
+			return
+		}
+
+		o.wg.Add(1)
 		go func() {
-			op, exists := c.Get(operate_log.KEY_LOG)
-			if !exists {
-				return
-			}
-			operateLog, ok := op.(*models.OperateLogs)
-			if !ok {
-				log.Errorf("op.(*models.OperateLogs) err")
-				return
-			}
-			operateLog.IP = utils.ConvIP(c.Request.Header)
-			operateLog.AccountID = c.GetUint("id")
-			operateLog.GroupID = c.GetUint("groupId")
-
-			needFields := []string{}
-			if val, exists := c.Get(operate_log.KEY_NEED_FIELDS); exists {
-				needFields, ok = val.([]string)
-				if !ok {
-					log.Errorf("needFields get err")
-					return
-				}
-			}
-			expectFields := []string{}
-			if val, exists := c.Get(operate_log.KEY_EXPECT_FIELDS); exists {
-				expectFields, ok = val.([]string)
-				if !ok {
-					log.Errorf("existsFields get err")
-					return
-				}
-			}
-
-			beforeFields, existsBefore := c.Get(operate_log.KEY_BEFORE)
-			afterFields, existsAfter := c.Get(operate_log.KEY_AFTER)
-
-			if existsBefore || existsAfter {
-				fieldsSlice, beforeSlice, afterSlice, err := operate_log.GetFieldsLogSlice(beforeFields, afterFields, needFields, expectFields)
-				if err != nil {
-					log.Errorf("GetFieldsLogSlice err %v", err)
-					return
-				}
-				operateLog.Fields = fieldsSlice
-				operateLog.FieldsBefore = beforeSlice
-				operateLog.FieldsAfter = afterSlice
-			}
-
-			err := mysqlClient.Db().
-				Create(operateLog).
-				Error
-			if err != nil {
-				log.Errorf("create operateLog %v", err)
-				return
-			}
+			defer o.wg.Done()
+			o.save(operateLog)
 		}()
 	}
 }
+
+// Shutdown 等待写入中的操作日志
+func (o *OperateLogger) Shutdown(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		o.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (o *OperateLogger) collect(c *gin.Context) (*models.OperateLogs, error) {
+	op, exists := c.Get(operate_log.KEY_LOG)
+	if !exists {
+		return nil, nil
+	}
+	operateLog, ok := op.(*models.OperateLogs)
+	if !ok {
+		return nil, errors.New("op.(*models.OperateLogs) err")
+	}
+	operateLog.IP = utils.ConvIP(c.Request.Header)
+	operateLog.AccountID = c.GetUint("id")
+	operateLog.GroupID = c.GetUint("groupId")
+
+	needFields := []string{}
+	if val, exists := c.Get(operate_log.KEY_NEED_FIELDS); exists {
+		needFields, ok = val.([]string)
+		if !ok {
+			return nil, errors.New("needFields get err")
+		}
+	}
+	expectFields := []string{}
+	if val, exists := c.Get(operate_log.KEY_EXPECT_FIELDS); exists {
+		expectFields, ok = val.([]string)
+		if !ok {
+			return nil, errors.New("existsFields get err")
+		}
+	}
+
+	beforeFields, existsBefore := c.Get(operate_log.KEY_BEFORE)
+	afterFields, existsAfter := c.Get(operate_log.KEY_AFTER)
+
+	if existsBefore || existsAfter {
+		fieldsSlice, beforeSlice, afterSlice, err := operate_log.GetFieldsLogSlice(beforeFields, afterFields, needFields, expectFields)
+		if err != nil {
+			return nil, err
+		}
+		operateLog.Fields = fieldsSlice
+		operateLog.FieldsBefore = beforeSlice
+		operateLog.FieldsAfter = afterSlice
+	}
+	return operateLog, nil
+}
+
+func (o *OperateLogger) save(operateLog *models.OperateLogs) {
+	err := o.mysqlClient.Db().
+		Create(operateLog).
+		Error
+	if err != nil {
+		o.log.Errorf("create operateLog %v", err)
+		return
+	}
+
+	o.analyticsClient.Record(&models.OperateLogEvent{
+		Time:      operateLog.CreatedAt,
+		AccountID: uint64(operateLog.AccountID),
+		Module:    operateLog.Module,
+		IP:        operateLog.IP,
+		Content:   operateLog.Content,
+	})
+}
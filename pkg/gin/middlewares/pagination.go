@@ -1,17 +1,16 @@
 package middlewares
 
 import (
-	"fmt"
 	"math"
 	"net/http"
 	"strconv"
 	"strings"
 
 	"lovebox/pkg/errors"
+	"lovebox/pkg/query"
 	"lovebox/pkg/utils"
 
 	"github.com/gin-gonic/gin"
-	"github.com/iancoleman/strcase"
 	"gorm.io/gorm"
 )
 
@@ -34,6 +33,48 @@ type Pagination struct {
 	SortBy    string `json:"sortBy" form:"sortBy" mapstructure:"sortBy" binding:"omitempty"`                                                   // 排序字段
 	SortOrder string `json:"sortOrder" form:"sortOrder" mapstructure:"sortOrder" binding:"omitempty,oneof=asc desc" enums:"asc,desc"`          // 排序方式
 	MultiSort string // 多字段排序
+	Cursor    string // 游标分页的cursor，第一页为空
+	Keyset    bool   // 请求带cursor参数时使用游标分页
+	WithTotal bool   // 是否查询总数
+
+	Filters        []FilterParam // 过滤参数，查询时按模型的query标签校验
+	InvalidFilters []string      // 格式错误的过滤参数
+
+	cursorKey []byte
+}
+
+// Query 转换为与存储无关的分页参数
+func (p *Pagination) Query() query.Page {
+	return query.Page{
+		Page:  p.Page,
+		Limit: p.Limit,
+		Sorts: p.Sorts(),
+	}
+}
+
+// Sorts 解析排序字段，多字段排序优先
+func (p *Pagination) Sorts() []query.SortField {
+	fields := []query.SortField{}
+	if len(p.MultiSort) > 0 {
+		for _, item := range strings.Split(p.MultiSort, ",") {
+			parts := strings.Fields(item)
+			if len(parts) == 0 {
+				continue
+			}
+			fields = append(fields, query.SortField{
+				Field: strings.ReplaceAll(parts[0], "`", ""),
+				Desc:  len(parts) > 1 && parts[1] == "desc",
+			})
+		}
+		return fields
+	}
+	if p.SortBy != "" {
+		fields = append(fields, query.SortField{
+			Field: strings.ReplaceAll(p.SortBy, "`", ""),
+			Desc:  p.SortOrder == "desc",
+		})
+	}
+	return fields
 }
 
 func (p *Pagination) SetDefault() {
@@ -45,7 +86,11 @@ func (p *Pagination) SetDefault() {
 
 // GetDocsAndTotal ...
 func (p *Pagination) GetDocsAndTotal(query *gorm.DB, data interface{}, total *int64) error {
-	err := query.
+	query, columns, err := p.prepare(query)
+	if err != nil {
+		return err
+	}
+	err = query.
 		Count(total).
 		Error
 	if err != nil {
@@ -56,16 +101,7 @@ func (p *Pagination) GetDocsAndTotal(query *gorm.DB, data interface{}, total *in
 			Offset((p.Page - 1) * p.Limit).
 			Limit(p.Limit)
 	}
-	if len(p.MultiSort) > 0 {
-		query = query.Order(p.MultiSort)
-	} else {
-		if p.SortBy != "" && p.SortOrder != "" {
-			if p.SortOrder != "asc" && p.SortOrder != "desc" {
-				return ErrInvalidSortOption
-			}
-			query = query.Order(p.SortBy + " " + p.SortOrder)
-		}
-	}
+	query = orderBy(query, columns, false)
 
 	err = query.
 		Scan(data).
@@ -77,114 +113,6 @@ func (p *Pagination) GetDocsAndTotal(query *gorm.DB, data interface{}, total *in
 	return nil
 }
 
-// ScanWhereQuery 检索请求参数拼接where，支持：默认单字段where等于 | 多字段whereOr | 自定义查询方法例如like > <
-// example: 表单key：name|number|idCardNo|mobile#like 表单val：123 处理后：
-//
-//	`name` like '%4442322%' OR `number` like '%4442322%' OR `id_card_no` like '%4442322%' OR `mobile` like '%4442322%'
-func (p *Pagination) ScanWhereOrQuery(c *gin.Context, fields ...string) []string {
-	where := make([]string, 0)
-	fieldVal := ""
-	for _, field := range fields {
-		if strings.HasSuffix(field, "[]") {
-			// 兼容前端框架
-			fieldVal = strings.Join(c.QueryArray(field), "|")
-			field = strings.TrimRight(field, "[]") + "#in"
-		} else {
-			fieldVal = c.DefaultQuery(field, "")
-			if fieldVal == "" {
-				fieldVal = c.DefaultPostForm(field, "")
-			}
-		}
-
-		if fieldVal != "" {
-			fieldName := utils.ToSnakeCase(field)
-			sql := ""
-			method := "="
-			// scan custom method
-			customMethod := strings.Split(fieldName, "#")
-			if len(customMethod) > 1 {
-				fieldName = customMethod[0]
-				method = customMethod[1]
-			}
-			if method == "like" {
-				fieldVal = "%" + fieldVal + "%"
-			}
-			// scan whereOr
-			whereOrFields := strings.Split(fieldName, "|")
-			for index, thisField := range whereOrFields {
-				switch method {
-				case "ojs":
-					orValues := strings.Split(fieldVal, "|")
-					fn := strings.Split(thisField, ":")
-					var tql string
-					for i, fs := range orValues {
-						tql2 := fmt.Sprintf("json_search(`%s`,'one','%s',null,'$[*].%s') is not null", fn[0], fs, strcase.ToLowerCamel(fn[1]))
-						if i > 0 {
-							tql += " OR " + tql2
-						} else {
-							tql += tql2
-						}
-					}
-					if index > 0 {
-						sql += " OR " + tql
-					} else {
-						sql += tql
-					}
-
-				case "in":
-					fv := strings.Split(fieldVal, "|")
-					fieldVal = "('" + strings.Join(fv, "','") + "')"
-					sql = fmt.Sprintf("`%s` %s %s", thisField, method, fieldVal)
-				default:
-					fieldVal = strings.ReplaceAll(fieldVal, "'", "")
-					tempSql := fmt.Sprintf("`%s` %s '%s'", thisField, method, fieldVal)
-					if index > 0 {
-						tempSql = " OR " + tempSql
-					}
-					sql += tempSql
-				}
-			}
-			where = append(where, sql)
-		}
-	}
-	return where
-}
-
-func (p *Pagination) ScanWhereDateRange(c *gin.Context, field string, toTimestamp bool) []string {
-	where := make([]string, 0)
-	dateRange := c.QueryArray("dateRange[]")
-	if len(dateRange) != 2 {
-		return where
-	}
-	start := dateRange[0]
-	end := dateRange[1]
-	startArr := strings.Split(start, " ")
-	endArr := strings.Split(end, " ")
-	if len(startArr) < 1 || len(endArr) < 1 {
-		return where
-	}
-
-	startTime := startArr[0] + " 00:00:00"
-	endTime := endArr[0] + " 23:59:59"
-	if toTimestamp {
-		where = append(where, fmt.Sprintf("`%s` >= %d", utils.ToSnakeCase(field), utils.StringToTimestamp(startTime)))
-		where = append(where, fmt.Sprintf("`%s` <= %d", utils.ToSnakeCase(field), utils.StringToTimestamp(endTime)))
-	} else {
-		where = append(where, fmt.Sprintf("`%s` >= '%s'", utils.ToSnakeCase(field), startTime))
-		where = append(where, fmt.Sprintf("`%s` <= '%s'", utils.ToSnakeCase(field), endTime))
-	}
-	return where
-}
-
-func (p *Pagination) InsertQueryWhere(query *gorm.DB, where []string) *gorm.DB {
-	if len(where) > 0 {
-		for _, v := range where {
-			query = query.Where(v)
-		}
-	}
-	return query
-}
-
 // Response ...
 func (p *Pagination) Response(c *gin.Context, total uint64, data interface{}) {
 
@@ -194,10 +122,31 @@ func (p *Pagination) Response(c *gin.Context, total uint64, data interface{}) {
 	})
 }
 
-// NewPaginationMiddleware ...
-func NewPaginationMiddleware() gin.HandlerFunc {
+type paginationOptions struct {
+	cursorKey []byte
+}
+
+// PaginationOption ...
+type PaginationOption func(*paginationOptions)
+
+// PaginationCursorKey 游标签名密钥，多个实例需使用相同的密钥
+func PaginationCursorKey(key []byte) PaginationOption {
+	return func(o *paginationOptions) {
+		o.cursorKey = key
+	}
+}
+
+// NewPaginationMiddleware 解析limit、page、sort、cursor与total参数
+func NewPaginationMiddleware(opts ...PaginationOption) gin.HandlerFunc {
+	o := &paginationOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
 	return func(c *gin.Context) {
-		p := &Pagination{}
+		p := &Pagination{cursorKey: o.cursorKey}
+		p.Cursor, p.Keyset = c.GetQuery("cursor")
+		p.Filters, p.InvalidFilters = parseFilterParams(c.Request.URL.Query())
 
 		limitQuery := c.DefaultQuery("limit", defaultLimit)
 		pageQuery := c.DefaultQuery("page", defaultPage)
@@ -208,7 +157,7 @@ func NewPaginationMiddleware() gin.HandlerFunc {
 			for _, m := range ms {
 				sorts := strings.Split(m, ":")
 				so := defaultSortOrder
-				if len(sorts) == 1 || (len(sorts) > 1 && sorts[1] != defaultSortOrder) {
+				if len(sorts) > 1 && sorts[1] != defaultSortOrder {
 					so = "desc"
 				}
 				sk := utils.ToSnakeCase(sorts[0])
@@ -220,7 +169,7 @@ func NewPaginationMiddleware() gin.HandlerFunc {
 		} else if len(ms[0]) > 0 {
 			sorts := strings.Split(ms[0], ":")
 			p.SortOrder = defaultSortOrder
-			if len(sorts) > 0 && sorts[1] != defaultSortOrder {
+			if len(sorts) > 1 && sorts[1] != defaultSortOrder {
 				p.SortOrder = "desc"
 			}
 			p.SortBy = utils.ToSnakeCase(sorts[0])
@@ -249,6 +198,17 @@ func NewPaginationMiddleware() gin.HandlerFunc {
 
 		p.Page = int(math.Max(1, float64(page)))
 
+		// 页码分页默认查询总数，游标分页默认不查询
+		p.WithTotal = !p.Keyset
+		if totalQuery := c.Query("total"); totalQuery != "" {
+			p.WithTotal, err = strconv.ParseBool(totalQuery)
+			if err != nil {
+				_ = c.Error(err).SetType(gin.ErrorTypePublic)
+				c.Abort()
+				return
+			}
+		}
+
 		c.Set("pagination", p)
 		c.Next()
 	}
@@ -0,0 +1,22 @@
+package middlewares
+
+import (
+	"lovebox/pkg/requestid"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestID 沿用客户端传入的X-Request-ID（不合法时重新生成），
+// 放入请求的context并写回响应头
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(requestid.Header)
+		if !requestid.Valid(id) {
+			id = requestid.New()
+		}
+		c.Request = c.Request.WithContext(requestid.NewContext(c.Request.Context(), id))
+		c.Header(requestid.Header, id)
+
+		c.Next()
+	}
+}
@@ -2,29 +2,96 @@ package middlewares
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
 	"github.com/unrolled/secure"
 )
 
-func Secure() gin.HandlerFunc {
+const cspNonceKey = "cspNonce"
+
+// SecureConfig 安全响应头配置，ContentSecurityPolicy中的$NONCE会替换为每个请求的随机nonce
+type SecureConfig struct {
+	// IsDevelopment 为true时不校验AllowedHosts、不跳转https、不返回HSTS
+	IsDevelopment         bool              `mapstructure:"isDevelopment"`
+	AllowedHosts          []string          `mapstructure:"allowedHosts"`
+	HostsProxyHeaders     []string          `mapstructure:"hostsProxyHeaders"`
+	SSLRedirect           bool              `mapstructure:"sslRedirect"`
+	SSLProxyHeaders       map[string]string `mapstructure:"sslProxyHeaders"`
+	STSSeconds            int64             `mapstructure:"stsSeconds"`
+	STSIncludeSubdomains  bool              `mapstructure:"stsIncludeSubdomains"`
+	STSPreload            bool              `mapstructure:"stsPreload"`
+	FrameDeny             bool              `mapstructure:"frameDeny"`
+	ContentTypeNosniff    bool              `mapstructure:"contentTypeNosniff"`
+	BrowserXssFilter      bool              `mapstructure:"browserXssFilter"`
+	ContentSecurityPolicy string            `mapstructure:"contentSecurityPolicy"`
+	ReferrerPolicy        string            `mapstructure:"referrerPolicy"`
+	PermissionsPolicy     string            `mapstructure:"permissionsPolicy"`
+}
+
+// SecureConfigFromViper 读取http.secure配置，未配置的字段使用环境预设
+func SecureConfigFromViper(cfg *viper.Viper, production bool) (*SecureConfig, error) {
+	if cfg == nil {
+		cfg = viper.New()
+	}
+
+	cfg.SetDefault("isDevelopment", !production)
+	cfg.SetDefault("sslRedirect", production)
+	cfg.SetDefault("sslProxyHeaders", map[string]string{"X-Forwarded-Proto": "https"})
+	cfg.SetDefault("stsSeconds", 31536000)
+	cfg.SetDefault("stsIncludeSubdomains", true)
+	cfg.SetDefault("stsPreload", production)
+	cfg.SetDefault("frameDeny", true)
+	cfg.SetDefault("contentTypeNosniff", true)
+	cfg.SetDefault("browserXssFilter", true)
+	cfg.SetDefault("contentSecurityPolicy", "default-src 'none'; frame-ancestors 'none'")
+	cfg.SetDefault("referrerPolicy", "strict-origin-when-cross-origin")
+	c := &SecureConfig{}
+	if err := cfg.Unmarshal(c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Secure 安全响应头中间件，配置在启动时构建一次
+func Secure(cfg *SecureConfig) gin.HandlerFunc {
+	s := secure.New(secure.Options{
+		IsDevelopment:         cfg.IsDevelopment,
+		AllowedHosts:          cfg.AllowedHosts,
+		HostsProxyHeaders:     cfg.HostsProxyHeaders,
+		SSLRedirect:           cfg.SSLRedirect,
+		SSLProxyHeaders:       cfg.SSLProxyHeaders,
+		STSSeconds:            cfg.STSSeconds,
+		STSIncludeSubdomains:  cfg.STSIncludeSubdomains,
+		STSPreload:            cfg.STSPreload,
+		FrameDeny:             cfg.FrameDeny,
+		ContentTypeNosniff:    cfg.ContentTypeNosniff,
+		BrowserXssFilter:      cfg.BrowserXssFilter,
+		ContentSecurityPolicy: cfg.ContentSecurityPolicy,
+		ReferrerPolicy:        cfg.ReferrerPolicy,
+		PermissionsPolicy:     cfg.PermissionsPolicy,
+	})
+
 	return func(c *gin.Context) {
-		secureMiddleware := secure.New(secure.Options{
-			SSLRedirect:           true,
-			SSLProxyHeaders:       map[string]string{"X-Forwarded-Proto": "https"},
-			STSSeconds:            31536000,
-			STSIncludeSubdomains:  true,
-			STSPreload:            true,
-			FrameDeny:             true,
-			ContentTypeNosniff:    true,
-			BrowserXssFilter:      true,
-			ContentSecurityPolicy: "script-src $NONCE",
-		})
-		err := secureMiddleware.Process(c.Writer, c.Request)
-
-		// If there was an error, do not continue.
+		headers, r, err := s.ProcessNoModifyRequest(c.Writer, c.Request)
+		// https跳转或host不合法，响应已写入
 		if err != nil {
+			c.Abort()
 			return
 		}
+		for key, values := range headers {
+			for _, value := range values {
+				c.Writer.Header().Set(key, value)
+			}
+		}
 
+		c.Request = r
+		if nonce := secure.CSPNonce(r.Context()); nonce != "" {
+			c.Set(cspNonceKey, nonce)
+		}
 		c.Next()
 	}
 }
+
+// CSPNonce 当前请求的CSP nonce，用于内联script/style的nonce属性
+func CSPNonce(c *gin.Context) string {
+	return c.GetString(cspNonceKey)
+}
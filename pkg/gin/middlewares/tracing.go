@@ -4,16 +4,23 @@ import (
 	"net/http"
 	"net/url"
 
+	"lovebox/pkg/requestid"
+	"lovebox/pkg/tracing"
+
 	"github.com/gin-gonic/gin"
-	opentracing "github.com/opentracing/opentracing-go"
-	"github.com/opentracing/opentracing-go/ext"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const defaultComponentName = "net/http"
 
 type trOptions struct {
 	opNameFunc    func(r *http.Request) string
-	spanObserver  func(span opentracing.Span, r *http.Request)
+	spanObserver  func(span trace.Span, r *http.Request)
 	urlTagFunc    func(u *url.URL) string
 	componentName string
 }
@@ -47,7 +54,7 @@ func TracingComponentName(componentName string) TracingOption {
 
 // MWSpanObserver returns a TracingOption that observe the span
 // for the server-side span.
-func TracingSpanObserver(f func(span opentracing.Span, r *http.Request)) TracingOption {
+func TracingSpanObserver(f func(span trace.Span, r *http.Request)) TracingOption {
 	return func(options *trOptions) {
 		options.spanObserver = f
 	}
@@ -62,15 +69,14 @@ func TracingURLTagFunc(f func(u *url.URL) string) TracingOption {
 	}
 }
 
-// Middleware is a gin native version of the equivalent middleware in:
-//
-//	https://github.com/opentracing-contrib/go-stdlib/
+// Tracing 从请求头中提取上游span（W3C traceparent、B3等，取决于全局propagator），
+// 创建服务端span并放入请求的context
 func Tracing(options ...TracingOption) gin.HandlerFunc {
 	opts := trOptions{
 		opNameFunc: func(r *http.Request) string {
 			return r.Method + " " + r.URL.Path
 		},
-		spanObserver: func(span opentracing.Span, r *http.Request) {},
+		spanObserver: func(span trace.Span, r *http.Request) {},
 		urlTagFunc: func(u *url.URL) string {
 			return u.String()
 		},
@@ -80,23 +86,39 @@ func Tracing(options ...TracingOption) gin.HandlerFunc {
 	}
 
 	return func(c *gin.Context) {
-		tr := opentracing.GlobalTracer()
-		carrier := opentracing.HTTPHeadersCarrier(c.Request.Header)
-		ctx, _ := tr.Extract(opentracing.HTTPHeaders, carrier)
+		ctx := otel.GetTextMapPropagator().Extract(
+			c.Request.Context(),
+			propagation.HeaderCarrier(c.Request.Header),
+		)
 		componentName := opts.GetComponentName()
 		op := componentName + " " + opts.opNameFunc(c.Request)
-		sp := tr.StartSpan(op, ext.RPCServerOption(ctx))
-		ext.HTTPMethod.Set(sp, c.Request.Method)
-		ext.HTTPUrl.Set(sp, opts.urlTagFunc(c.Request.URL))
+		ctx, sp := tracing.Tracer().Start(
+			ctx,
+			op,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPMethodKey.String(c.Request.Method),
+				semconv.HTTPURLKey.String(opts.urlTagFunc(c.Request.URL)),
+				attribute.String("component", componentName),
+			),
+		)
+		if id := requestid.FromContext(ctx); id != "" {
+			sp.SetAttributes(attribute.String("http.request_id", id))
+		}
 		opts.spanObserver(sp, c.Request)
 
-		ext.Component.Set(sp, componentName)
-		c.Request = c.Request.WithContext(
-			opentracing.ContextWithSpan(c.Request.Context(), sp))
+		c.Request = c.Request.WithContext(ctx)
 
 		c.Next()
 
-		ext.HTTPStatusCode.Set(sp, uint16(c.Writer.Status()))
-		sp.Finish()
+		status := c.Writer.Status()
+		sp.SetAttributes(semconv.HTTPStatusCodeKey.Int(status))
+		if route := c.FullPath(); route != "" {
+			sp.SetAttributes(semconv.HTTPRouteKey.String(route))
+		}
+		if status >= http.StatusInternalServerError {
+			sp.SetStatus(codes.Error, http.StatusText(status))
+		}
+		sp.End()
 	}
 }
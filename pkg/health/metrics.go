@@ -0,0 +1,35 @@
+package health
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	metricsOnce sync.Once
+
+	checkStatus   *prometheus.GaugeVec
+	checkDuration *prometheus.HistogramVec
+)
+
+func registerMetrics() {
+	checkStatus = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "health_check_status",
+			Help: "The last health check result (1 ok, 0 failed), partitioned by check.",
+		},
+		[]string{"check"},
+	)
+	prometheus.MustRegister(checkStatus)
+
+	checkDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "health_check_duration_seconds",
+			Help:    "The health check latencies in seconds, partitioned by check.",
+			Buckets: []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+		},
+		[]string{"check"},
+	)
+	prometheus.MustRegister(checkDuration)
+}
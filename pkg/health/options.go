@@ -0,0 +1,44 @@
+package health
+
+import "time"
+
+type options struct {
+	timeout time.Duration
+}
+
+func defaultOptions() options {
+	return options{
+		timeout: 2 * time.Second,
+	}
+}
+
+// Option controls the behavior of the Registry.
+type Option func(*options)
+
+// WithTimeout 设置检查的默认超时
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		if timeout > 0 {
+			o.timeout = timeout
+		}
+	}
+}
+
+// CheckOption controls the behavior of a single check.
+type CheckOption func(*check)
+
+// WithCheckTimeout 设置单个检查的超时
+func WithCheckTimeout(timeout time.Duration) CheckOption {
+	return func(c *check) {
+		if timeout > 0 {
+			c.timeout = timeout
+		}
+	}
+}
+
+// Optional 检查失败时只在结果中体现，不影响readiness
+func Optional() CheckOption {
+	return func(c *check) {
+		c.optional = true
+	}
+}
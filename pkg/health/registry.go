@@ -0,0 +1,196 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/atomic"
+	"go.uber.org/zap"
+)
+
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// ErrShuttingDown 正在优雅退出，readiness返回失败让负载均衡摘除实例
+var ErrShuttingDown = errors.New("shutting down")
+
+// CheckFunc 检查依赖是否可用，ctx带有超时
+type CheckFunc func(ctx context.Context) error
+
+type check struct {
+	name     string
+	fn       CheckFunc
+	timeout  time.Duration
+	optional bool
+}
+
+// Result 单个依赖的检查结果
+type Result struct {
+	Status   string `json:"status"`
+	Latency  int64  `json:"latency"` // 毫秒
+	Error    string `json:"error,omitempty"`
+	Optional bool   `json:"optional,omitempty"`
+}
+
+// Report readiness检查结果
+type Report struct {
+	Status string             `json:"status"`
+	Checks map[string]*Result `json:"checks,omitempty"`
+}
+
+// Registry 依赖健康检查注册表
+type Registry struct {
+	log  *zap.SugaredLogger
+	opts options
+
+	mu     sync.RWMutex
+	checks []*check
+
+	shuttingDown atomic.Bool
+}
+
+func New(opts ...Option) *Registry {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+	metricsOnce.Do(registerMetrics)
+
+	return &Registry{
+		log:  zap.S().With("module", "pkg.health"),
+		opts: o,
+	}
+}
+
+// Register 注册依赖检查，同名检查会被替换
+func (r *Registry) Register(name string, fn CheckFunc, opts ...CheckOption) {
+	c := &check{
+		name:    name,
+		fn:      fn,
+		timeout: r.opts.timeout,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// 复制后修改，Check中读取的切片不受影响
+	checks := make([]*check, 0, len(r.checks)+1)
+	for _, exist := range r.checks {
+		if exist.name != name {
+			checks = append(checks, exist)
+		}
+	}
+	checks = append(checks, c)
+	sort.Slice(checks, func(i, j int) bool {
+		return checks[i].name < checks[j].name
+	})
+	r.checks = checks
+}
+
+// SetShuttingDown 开始优雅退出，之后readiness始终失败
+func (r *Registry) SetShuttingDown() {
+	r.shuttingDown.Store(true)
+}
+
+// Check 并发执行所有检查，任一非optional的检查失败则整体失败
+func (r *Registry) Check(ctx context.Context) *Report {
+	r.mu.RLock()
+	checks := r.checks
+	r.mu.RUnlock()
+
+	report := &Report{
+		Status: StatusOK,
+		Checks: make(map[string]*Result, len(checks)),
+	}
+	results := make([]*Result, len(checks))
+
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func(i int, c *check) {
+			defer wg.Done()
+			results[i] = r.run(ctx, c)
+		}(i, c)
+	}
+	wg.Wait()
+
+	for i, c := range checks {
+		res := results[i]
+		report.Checks[c.name] = res
+		if res.Status != StatusOK && !c.optional {
+			report.Status = StatusFail
+		}
+	}
+	if r.shuttingDown.Load() {
+		report.Status = StatusFail
+		report.Checks["shutdown"] = &Result{Status: StatusFail, Error: ErrShuttingDown.Error()}
+	}
+	return report
+}
+
+func (r *Registry) run(ctx context.Context, c *check) (res *Result) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	res = &Result{Status: StatusOK, Optional: c.optional}
+	defer func() {
+		elapsed := time.Since(start)
+		res.Latency = elapsed.Milliseconds()
+		checkDuration.WithLabelValues(c.name).Observe(elapsed.Seconds())
+		if res.Status == StatusOK {
+			checkStatus.WithLabelValues(c.name).Set(1)
+		} else {
+			checkStatus.WithLabelValues(c.name).Set(0)
+		}
+	}()
+
+	// 检查函数不响应ctx时也按超时返回
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				r.log.Errorf("Health check %s panic: %v", c.name, p)
+				done <- fmt.Errorf("panic: %v", p)
+			}
+		}()
+		done <- c.fn(ctx)
+	}()
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	if err != nil {
+		res.Status = StatusFail
+		res.Error = err.Error()
+		r.log.Warnf("Health check %s failed: %v", c.name, err)
+	}
+	return res
+}
+
+// Liveness 进程存活即返回200，不检查依赖，避免依赖故障导致实例被反复重启
+func (r *Registry) Liveness(c *gin.Context) {
+	c.JSON(http.StatusOK, &Report{Status: StatusOK})
+}
+
+// Readiness 依赖全部可用时返回200，否则返回503
+func (r *Registry) Readiness(c *gin.Context) {
+	report := r.Check(c.Request.Context())
+	status := http.StatusOK
+	if report.Status != StatusOK {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, report)
+}
@@ -0,0 +1,295 @@
+package httpcache
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"lovebox/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/cache/v8"
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+)
+
+// AccountTag 账号相关接口的失效标签，与Tags("account:{account}")对应
+func AccountTag(accountID uint) string {
+	return fmt.Sprintf("account:%d", accountID)
+}
+
+// Cache GET接口的响应缓存：按路由配置缓存时长与缓存键，生成ETag并处理If-None-Match。
+// 失效通过标签版本号实现：缓存键包含各标签的当前版本，Invalidate递增版本号，旧缓存随TTL过期
+type Cache struct {
+	log         *zap.SugaredLogger
+	cacheClient *cache.Cache
+	redisClient *redis.Client
+	opts        options
+}
+
+func New(cacheClient *cache.Cache, redisClient *redis.Client, opts ...Option) *Cache {
+	metricsOnce.Do(registerMetrics)
+
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &Cache{
+		log:         zap.S().With("module", "pkg.httpcache"),
+		cacheClient: cacheClient,
+		redisClient: redisClient,
+		opts:        o,
+	}
+}
+
+// entry 缓存的响应
+type entry struct {
+	Status int
+	Header http.Header
+	Body   []byte
+	ETag   string
+}
+
+// Invalidate 使带有任一标签的缓存失效，在写操作完成后调用
+func (c *Cache) Invalidate(ctx context.Context, tags ...string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+	pipe := c.redisClient.Pipeline()
+	for _, tag := range tags {
+		pipe.Incr(ctx, c.tagKey(tag))
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (c *Cache) tagKey(tag string) string {
+	return c.opts.prefix + ":tag:" + tag
+}
+
+// Handle 路由的缓存中间件，只缓存没有错误的200响应；
+// 响应头Cache-Control包含no-store时不缓存
+func (c *Cache) Handle(opts ...RouteOption) gin.HandlerFunc {
+	r := defaultRule()
+	for _, opt := range opts {
+		opt(&r)
+	}
+
+	return func(ctx *gin.Context) {
+		if ctx.Request.Method != http.MethodGet {
+			ctx.Next()
+			return
+		}
+		route := ctx.FullPath()
+		reqCtx := ctx.Request.Context()
+
+		key, err := c.key(ctx, &r)
+		if err != nil {
+			logger.WithContext(reqCtx, c.log).Warnf("Build cache key err=%v", err)
+			requestsTotal.WithLabelValues(route, "bypass").Inc()
+			ctx.Next()
+			return
+		}
+
+		cached := &entry{}
+		if err := c.cacheClient.Get(reqCtx, key, cached); err == nil {
+			c.writeHeaders(ctx, &r, cached.Header, cached.ETag)
+			ctx.Header("X-Cache", "HIT")
+			if matchETag(ctx.GetHeader("If-None-Match"), cached.ETag) {
+				requestsTotal.WithLabelValues(route, "not_modified").Inc()
+				ctx.AbortWithStatus(http.StatusNotModified)
+				return
+			}
+			requestsTotal.WithLabelValues(route, "hit").Inc()
+			ctx.Status(cached.Status)
+			_, _ = ctx.Writer.Write(cached.Body)
+			ctx.Abort()
+			return
+		} else if err != cache.ErrCacheMiss {
+			logger.WithContext(reqCtx, c.log).Warnf("Get cache %s err=%v", route, err)
+		}
+
+		before := ctx.Writer.Header().Clone()
+		w := &bufferWriter{ResponseWriter: ctx.Writer}
+		ctx.Writer = w
+		ctx.Next()
+		ctx.Writer = w.ResponseWriter
+
+		status := w.Status()
+		if len(ctx.Errors) > 0 || status != http.StatusOK ||
+			strings.Contains(w.Header().Get("Cache-Control"), "no-store") {
+			requestsTotal.WithLabelValues(route, "bypass").Inc()
+			w.flush()
+			return
+		}
+
+		sum := sha256.Sum256(w.body.Bytes())
+		e := &entry{
+			Status: status,
+			Header: changedHeaders(before, w.Header()),
+			Body:   w.body.Bytes(),
+			ETag:   `"` + hex.EncodeToString(sum[:16]) + `"`,
+		}
+		err = c.cacheClient.Set(&cache.Item{
+			Ctx:   reqCtx,
+			Key:   key,
+			Value: e,
+			TTL:   r.ttl,
+		})
+		if err != nil {
+			logger.WithContext(reqCtx, c.log).Warnf("Set cache %s err=%v", route, err)
+		}
+
+		c.writeHeaders(ctx, &r, nil, e.ETag)
+		ctx.Header("X-Cache", "MISS")
+		if matchETag(ctx.GetHeader("If-None-Match"), e.ETag) {
+			requestsTotal.WithLabelValues(route, "not_modified").Inc()
+			w.ResponseWriter.WriteHeader(http.StatusNotModified)
+			w.ResponseWriter.WriteHeaderNow()
+			return
+		}
+		requestsTotal.WithLabelValues(route, "miss").Inc()
+		w.flush()
+	}
+}
+
+// key 路由、路径、按策略选择的请求信息与标签版本的摘要
+func (c *Cache) key(ctx *gin.Context, r *rule) (string, error) {
+	h := sha256.New()
+	write := func(s string) {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	write(ctx.FullPath())
+	write(ctx.Request.URL.Path)
+
+	account := strconv.FormatUint(uint64(ctx.GetUint("id")), 10)
+	if r.keyBy&KeyQuery != 0 {
+		write(ctx.Request.URL.Query().Encode())
+	}
+	if r.keyBy&KeyAccount != 0 {
+		write(account)
+	}
+	if r.keyBy&KeyLanguage != 0 {
+		write(ctx.GetHeader("Accept-Language"))
+		lang, _ := ctx.Cookie("lang")
+		write(lang)
+	}
+
+	if len(r.tags) > 0 {
+		keys := make([]string, len(r.tags))
+		for i, tag := range r.tags {
+			keys[i] = c.tagKey(strings.ReplaceAll(tag, "{account}", account))
+		}
+		versions, err := c.redisClient.MGet(ctx.Request.Context(), keys...).Result()
+		if err != nil {
+			return "", err
+		}
+		for _, v := range versions {
+			write(fmt.Sprint(v))
+		}
+	}
+
+	return c.opts.prefix + ":" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func (c *Cache) writeHeaders(ctx *gin.Context, r *rule, header http.Header, etag string) {
+	for k, values := range header {
+		ctx.Writer.Header()[k] = values
+	}
+	ctx.Header("ETag", etag)
+
+	scope := "public"
+	if r.private {
+		scope = "private"
+	}
+	if r.maxAge > 0 {
+		ctx.Header("Cache-Control", scope+", max-age="+strconv.Itoa(int(r.maxAge/time.Second)))
+	} else {
+		ctx.Header("Cache-Control", scope+", no-cache")
+	}
+
+	var vary []string
+	if r.keyBy&KeyAccount != 0 {
+		vary = append(vary, "Authorization")
+	}
+	if r.keyBy&KeyLanguage != 0 {
+		vary = append(vary, "Accept-Language")
+	}
+	for _, v := range vary {
+		ctx.Writer.Header().Add("Vary", v)
+	}
+}
+
+// excludedHeaders 由外层中间件或缓存自身设置的响应头
+var excludedHeaders = map[string]bool{
+	"Content-Length": true,
+	"Date":           true,
+	"Set-Cookie":     true,
+	"Cache-Control":  true,
+	"Etag":           true,
+	"Vary":           true,
+}
+
+// changedHeaders 处理函数新增或修改的响应头
+func changedHeaders(before, after http.Header) http.Header {
+	changed := make(http.Header)
+	for k, values := range after {
+		if excludedHeaders[k] {
+			continue
+		}
+		if old, ok := before[k]; ok && strings.Join(old, "\n") == strings.Join(values, "\n") {
+			continue
+		}
+		changed[k] = values
+	}
+	return changed
+}
+
+// matchETag If-None-Match是否包含etag，按弱比较
+func matchETag(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	for _, tag := range strings.Split(ifNoneMatch, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// bufferWriter 缓冲响应体，以便写出前设置ETag
+type bufferWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *bufferWriter) Write(b []byte) (int, error) {
+	return w.body.Write(b)
+}
+
+func (w *bufferWriter) WriteString(s string) (int, error) {
+	return w.body.WriteString(s)
+}
+
+func (w *bufferWriter) Written() bool {
+	return w.body.Len() > 0 || w.ResponseWriter.Written()
+}
+
+// Flush 缓冲期间忽略
+func (w *bufferWriter) Flush() {}
+
+func (w *bufferWriter) flush() {
+	if w.body.Len() == 0 {
+		return
+	}
+	_, _ = w.ResponseWriter.Write(w.body.Bytes())
+}
@@ -0,0 +1,24 @@
+package httpcache
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	metricsOnce sync.Once
+
+	requestsTotal *prometheus.CounterVec
+)
+
+func registerMetrics() {
+	requestsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "http_cache_requests_total",
+			Help: "How many cacheable requests processed, partitioned by route and result (hit, miss, not_modified, bypass).",
+		},
+		[]string{"route", "result"},
+	)
+	prometheus.MustRegister(requestsTotal)
+}
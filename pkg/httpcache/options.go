@@ -0,0 +1,87 @@
+package httpcache
+
+import "time"
+
+// KeyStrategy 缓存键包含的请求信息，路由与路径总是包含在内
+type KeyStrategy int
+
+const (
+	// KeyQuery 查询参数（按参数名排序）
+	KeyQuery KeyStrategy = 1 << iota
+	// KeyAccount 当前登录账号，需在jwt中间件之后使用
+	KeyAccount
+	// KeyLanguage Accept-Language与lang cookie
+	KeyLanguage
+)
+
+type options struct {
+	prefix string
+}
+
+func defaultOptions() options {
+	return options{
+		prefix: "httpcache",
+	}
+}
+
+// Option controls the behavior of the Cache.
+type Option func(*options)
+
+// WithPrefix 设置redis键前缀
+func WithPrefix(prefix string) Option {
+	return func(o *options) {
+		if prefix != "" {
+			o.prefix = prefix
+		}
+	}
+}
+
+type rule struct {
+	ttl     time.Duration
+	maxAge  time.Duration
+	keyBy   KeyStrategy
+	tags    []string
+	private bool
+}
+
+func defaultRule() rule {
+	return rule{
+		ttl: time.Minute,
+	}
+}
+
+// RouteOption controls the behavior of a single route.
+type RouteOption func(*rule)
+
+// TTL 服务端缓存时长
+func TTL(ttl time.Duration) RouteOption {
+	return func(r *rule) {
+		if ttl > 0 {
+			r.ttl = ttl
+		}
+	}
+}
+
+// MaxAge 客户端可直接使用缓存的时长，为0时客户端每次需用If-None-Match重新验证
+func MaxAge(maxAge time.Duration) RouteOption {
+	return func(r *rule) {
+		r.maxAge = maxAge
+	}
+}
+
+// KeyBy 设置缓存键包含的请求信息，KeyAccount同时使响应只能被客户端私有缓存
+func KeyBy(strategy KeyStrategy) RouteOption {
+	return func(r *rule) {
+		r.keyBy = strategy
+		if strategy&KeyAccount != 0 {
+			r.private = true
+		}
+	}
+}
+
+// Tags 失效标签，Invalidate任一标签后缓存失效；"{account}"替换为当前账号id
+func Tags(tags ...string) RouteOption {
+	return func(r *rule) {
+		r.tags = append(r.tags, tags...)
+	}
+}
@@ -0,0 +1,82 @@
+package httpclient
+
+import (
+	"sync"
+	"time"
+)
+
+type breakerState int
+
+const (
+	stateClosed breakerState = iota
+	stateHalfOpen
+	stateOpen
+)
+
+// breaker 单个host的熔断器
+type breaker struct {
+	mu          sync.Mutex
+	state       breakerState
+	failures    int
+	openedAt    time.Time
+	probing     bool
+	threshold   int
+	openTimeout time.Duration
+}
+
+func newBreaker(threshold int, openTimeout time.Duration) *breaker {
+	return &breaker{
+		threshold:   threshold,
+		openTimeout: openTimeout,
+	}
+}
+
+// allow 熔断中返回false，半开状态只放行一个请求
+func (b *breaker) allow() bool {
+	if b.threshold == 0 {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case stateOpen:
+		if time.Since(b.openedAt) < b.openTimeout {
+			return false
+		}
+		b.state = stateHalfOpen
+		b.probing = true
+		return true
+	case stateHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	}
+	return true
+}
+
+func (b *breaker) success() breakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.state = stateClosed
+	b.failures = 0
+	b.probing = false
+	return b.state
+}
+
+func (b *breaker) failure() breakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.threshold == 0 {
+		return b.state
+	}
+	b.failures++
+	b.probing = false
+	if b.state == stateHalfOpen || b.failures >= b.threshold {
+		b.state = stateOpen
+		b.openedAt = time.Now()
+	}
+	return b.state
+}
@@ -0,0 +1,229 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"sync"
+	"time"
+
+	"lovebox/pkg/requestid"
+	"lovebox/pkg/tracing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrCircuitOpen 目标host熔断中
+	ErrCircuitOpen = errors.New("httpclient circuit breaker open")
+)
+
+// HeaderIdempotencyKey 带该请求头的非幂等请求也会重试
+const HeaderIdempotencyKey = "Idempotency-Key"
+
+// Client 带链路追踪、指标、重试与按host熔断的http客户端
+type Client struct {
+	log    *zap.SugaredLogger
+	opts   options
+	client *http.Client
+
+	breakers sync.Map
+}
+
+func New(opts ...Option) *Client {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+	metricsOnce.Do(registerMetrics)
+
+	return &Client{
+		log:  zap.S().With("module", "pkg.httpclient", "name", o.name),
+		opts: o,
+		client: &http.Client{
+			Transport:     o.transport,
+			Timeout:       o.timeout,
+			CheckRedirect: o.checkRedirect,
+		},
+	}
+}
+
+// Get ...
+func (c *Client) Get(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.Do(req)
+}
+
+// Post ...
+func (c *Client) Post(ctx context.Context, url, contentType string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	return c.Do(req)
+}
+
+// Do 发送请求，幂等请求在网络错误或429/502/503/504时重试，
+// 重试时需要req.GetBody重新读取请求体
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	host := req.URL.Host
+	b := c.breaker(host)
+	retryable := c.retryable(req)
+
+	var res *http.Response
+	var err error
+	for attempt := 0; ; attempt++ {
+		if !b.allow() {
+			requests.WithLabelValues(c.opts.name, host, req.Method, "circuit_open").Inc()
+			return nil, ErrCircuitOpen
+		}
+
+		res, err = c.do(req, attempt)
+		if c.failed(res, err) {
+			circuitState.WithLabelValues(c.opts.name, host).Set(float64(b.failure()))
+		} else {
+			circuitState.WithLabelValues(c.opts.name, host).Set(float64(b.success()))
+		}
+
+		if !retryable || attempt >= c.opts.maxRetries || !c.shouldRetry(res, err) {
+			return res, err
+		}
+
+		delay := c.backoff(attempt, res)
+		if res != nil {
+			// 复用连接
+			_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, 4096))
+			res.Body.Close()
+		}
+		retries.WithLabelValues(c.opts.name, host).Inc()
+		c.log.Warnf("Retry %s %s attempt=%d delay=%s err=%v", req.Method, req.URL.Redacted(), attempt+1, delay, err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (c *Client) do(req *http.Request, attempt int) (*http.Response, error) {
+	ctx, span := tracing.Tracer().Start(
+		req.Context(),
+		"HTTP "+req.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.HTTPMethodKey.String(req.Method),
+			semconv.HTTPURLKey.String(req.URL.Redacted()),
+			semconv.NetPeerNameKey.String(req.URL.Hostname()),
+		),
+	)
+	defer span.End()
+	if attempt > 0 {
+		span.SetAttributes(attribute.Int("http.retry", attempt))
+	}
+
+	r := req.Clone(httptrace.WithClientTrace(ctx, tracing.NewClientTrace(span)))
+	if attempt > 0 && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		r.Body = body
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(r.Header))
+	if id := requestid.FromContext(ctx); id != "" && r.Header.Get(requestid.Header) == "" {
+		r.Header.Set(requestid.Header, id)
+	}
+
+	start := time.Now()
+	res, err := c.client.Do(r)
+	duration.WithLabelValues(c.opts.name, req.URL.Host, req.Method).Observe(time.Since(start).Seconds())
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		requests.WithLabelValues(c.opts.name, req.URL.Host, req.Method, "error").Inc()
+		return nil, err
+	}
+
+	span.SetAttributes(semconv.HTTPStatusCodeKey.Int(res.StatusCode))
+	if res.StatusCode >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, http.StatusText(res.StatusCode))
+	}
+	requests.WithLabelValues(c.opts.name, req.URL.Host, req.Method, strconv.Itoa(res.StatusCode)).Inc()
+	return res, nil
+}
+
+func (c *Client) breaker(host string) *breaker {
+	if b, ok := c.breakers.Load(host); ok {
+		return b.(*breaker)
+	}
+	b, _ := c.breakers.LoadOrStore(host, newBreaker(c.opts.failureThreshold, c.opts.openTimeout))
+	return b.(*breaker)
+}
+
+// retryable 幂等方法或带幂等键，且请求体可以重新读取
+func (c *Client) retryable(req *http.Request) bool {
+	if c.opts.maxRetries == 0 {
+		return false
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return req.Header.Get(HeaderIdempotencyKey) != ""
+}
+
+// failed 计入熔断的失败：网络错误或5xx
+func (c *Client) failed(res *http.Response, err error) bool {
+	return err != nil || res.StatusCode >= http.StatusInternalServerError
+}
+
+func (c *Client) shouldRetry(res *http.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, context.Canceled)
+	}
+	switch res.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// backoff full jitter，响应带Retry-After秒数时使用较大值
+func (c *Client) backoff(attempt int, res *http.Response) time.Duration {
+	max := c.opts.retryBackoff << uint(attempt)
+	if max <= 0 || max > c.opts.maxRetryBackoff {
+		max = c.opts.maxRetryBackoff
+	}
+	delay := time.Duration(rand.Int63n(int64(max) + 1))
+
+	if res != nil {
+		if seconds, err := strconv.Atoi(res.Header.Get("Retry-After")); err == nil {
+			retryAfter := time.Duration(seconds) * time.Second
+			if retryAfter > delay && retryAfter <= c.opts.maxRetryBackoff {
+				delay = retryAfter
+			}
+		}
+	}
+	return delay
+}
@@ -0,0 +1,54 @@
+package httpclient
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	metricsOnce sync.Once
+
+	requests     *prometheus.CounterVec
+	duration     *prometheus.HistogramVec
+	retries      *prometheus.CounterVec
+	circuitState *prometheus.GaugeVec
+)
+
+func registerMetrics() {
+	requests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "httpclient_requests_total",
+			Help: "How many outbound HTTP requests made, partitioned by client, host, method and status code.",
+		},
+		[]string{"client", "host", "method", "code"},
+	)
+	prometheus.MustRegister(requests)
+
+	duration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name: "httpclient_request_duration_seconds",
+			Help: "The outbound HTTP request latencies in seconds.",
+		},
+		[]string{"client", "host", "method"},
+	)
+	prometheus.MustRegister(duration)
+
+	retries = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "httpclient_retries_total",
+			Help: "How many outbound HTTP requests retried, partitioned by client and host.",
+		},
+		[]string{"client", "host"},
+	)
+	prometheus.MustRegister(retries)
+
+	circuitState = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "httpclient_circuit_state",
+			Help: "The circuit breaker state per host: 0 closed, 1 half-open, 2 open.",
+		},
+		[]string{"client", "host"},
+	)
+	prometheus.MustRegister(circuitState)
+}
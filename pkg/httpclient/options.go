@@ -0,0 +1,91 @@
+package httpclient
+
+import (
+	"net/http"
+	"time"
+)
+
+type options struct {
+	name             string
+	timeout          time.Duration
+	transport        http.RoundTripper
+	checkRedirect    func(req *http.Request, via []*http.Request) error
+	maxRetries       int
+	retryBackoff     time.Duration
+	maxRetryBackoff  time.Duration
+	failureThreshold int
+	openTimeout      time.Duration
+}
+
+func defaultOptions() options {
+	return options{
+		name:             "default",
+		timeout:          10 * time.Second,
+		maxRetries:       2,
+		retryBackoff:     100 * time.Millisecond,
+		maxRetryBackoff:  2 * time.Second,
+		failureThreshold: 5,
+		openTimeout:      30 * time.Second,
+	}
+}
+
+// Option controls the behavior of the Client.
+type Option func(*options)
+
+// WithName 设置指标中的客户端名称
+func WithName(name string) Option {
+	return func(o *options) {
+		o.name = name
+	}
+}
+
+// WithTimeout 设置单次请求超时时间（含读取响应体）
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		if timeout > 0 {
+			o.timeout = timeout
+		}
+	}
+}
+
+// WithTransport 设置底层Transport
+func WithTransport(transport http.RoundTripper) Option {
+	return func(o *options) {
+		o.transport = transport
+	}
+}
+
+// WithCheckRedirect 设置重定向策略，与http.Client.CheckRedirect相同
+func WithCheckRedirect(fn func(req *http.Request, via []*http.Request) error) Option {
+	return func(o *options) {
+		o.checkRedirect = fn
+	}
+}
+
+// WithRetry 设置幂等请求的重试次数与退避间隔，退避使用full jitter
+func WithRetry(maxRetries int, min, max time.Duration) Option {
+	return func(o *options) {
+		if maxRetries >= 0 {
+			o.maxRetries = maxRetries
+		}
+		if min > 0 {
+			o.retryBackoff = min
+		}
+		if max >= o.retryBackoff {
+			o.maxRetryBackoff = max
+		}
+	}
+}
+
+// WithCircuitBreaker 同一host连续失败threshold次后熔断openTimeout，之后放行一个探测请求，
+// threshold为0时不熔断
+func WithCircuitBreaker(threshold int, openTimeout time.Duration) Option {
+	return func(o *options) {
+		if threshold >= 0 {
+			o.failureThreshold = threshold
+		}
+		if openTimeout > 0 {
+			o.openTimeout = openTimeout
+		}
+	}
+}
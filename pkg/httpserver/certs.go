@@ -0,0 +1,118 @@
+package httpserver
+
+import (
+	"crypto/tls"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"go.uber.org/zap"
+)
+
+// reloadDebounce 证书与私钥通常先后写入，合并短时间内的多次变化
+const reloadDebounce = 500 * time.Millisecond
+
+// certReloader 监听证书目录，文件变化时重新加载证书；
+// 监听目录而不是文件，以支持kubernetes secret通过符号链接替换文件
+type certReloader struct {
+	log      *zap.SugaredLogger
+	certFile string
+	keyFile  string
+
+	mu   sync.RWMutex
+	cert *tls.Certificate
+
+	watcher *fsnotify.Watcher
+	done    chan struct{}
+}
+
+func newCertReloader(log *zap.SugaredLogger, certFile, keyFile string) (*certReloader, error) {
+	r := &certReloader{
+		log:      log,
+		certFile: certFile,
+		keyFile:  keyFile,
+		done:     make(chan struct{}),
+	}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	dirs := map[string]bool{
+		filepath.Dir(certFile): true,
+		filepath.Dir(keyFile):  true,
+	}
+	for dir := range dirs {
+		if err := watcher.Add(dir); err != nil {
+			watcher.Close()
+			return nil, err
+		}
+	}
+	r.watcher = watcher
+	go r.watch()
+
+	return r, nil
+}
+
+func (r *certReloader) reload() error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.cert = &cert
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+func (r *certReloader) watch() {
+	var timer *time.Timer
+	var fire <-chan time.Time
+	for {
+		select {
+		case event, ok := <-r.watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename|fsnotify.Remove) == 0 {
+				continue
+			}
+			if timer == nil {
+				timer = time.NewTimer(reloadDebounce)
+			} else {
+				timer.Reset(reloadDebounce)
+			}
+			fire = timer.C
+		case <-fire:
+			fire = nil
+			// 加载失败时继续使用旧证书
+			if err := r.reload(); err != nil {
+				r.log.Errorf("Reload certificate %s err=%v", r.certFile, err)
+				continue
+			}
+			r.log.Infof("Reloaded certificate %s", r.certFile)
+		case err, ok := <-r.watcher.Errors:
+			if !ok {
+				return
+			}
+			r.log.Warnf("Watch certificate err=%v", err)
+		case <-r.done:
+			return
+		}
+	}
+}
+
+func (r *certReloader) Close() error {
+	close(r.done)
+	return r.watcher.Close()
+}
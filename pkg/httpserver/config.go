@@ -0,0 +1,87 @@
+package httpserver
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Config 监听配置
+type Config struct {
+	Network string `mapstructure:"network"` // tcp | unix
+	Address string `mapstructure:"address"` // tcp为host:port，unix为socket路径
+	Port    string `mapstructure:"port"`    // Address为空时监听":port"
+	// SocketMode unix socket文件权限，八进制字符串，如"0660"
+	SocketMode string `mapstructure:"socketMode"`
+	// ClientIPHeader unix socket没有客户端地址，从反向代理设置的请求头（如X-Real-IP）读取；
+	// 为空时客户端地址未知，ip白名单的接口不可访问
+	ClientIPHeader string `mapstructure:"clientIPHeader"`
+
+	ReadTimeout       time.Duration `mapstructure:"readTimeout"`
+	ReadHeaderTimeout time.Duration `mapstructure:"readHeaderTimeout"`
+	WriteTimeout      time.Duration `mapstructure:"writeTimeout"`
+	IdleTimeout       time.Duration `mapstructure:"idleTimeout"`
+	MaxHeaderBytes    int           `mapstructure:"maxHeaderBytes"`
+
+	// H2C 明文HTTP/2，TLS时总是支持HTTP/2
+	H2C bool      `mapstructure:"h2c"`
+	TLS TLSConfig `mapstructure:"tls"`
+}
+
+// TLSConfig CertFile与KeyFile都不为空时启用TLS，文件变化时自动重新加载；
+// ClientCAFile不为空时要求客户端证书（mTLS）
+type TLSConfig struct {
+	CertFile     string `mapstructure:"certFile"`
+	KeyFile      string `mapstructure:"keyFile"`
+	ClientCAFile string `mapstructure:"clientCAFile"`
+}
+
+func (c *TLSConfig) Enabled() bool {
+	return c.CertFile != "" && c.KeyFile != ""
+}
+
+func (c *Config) address() string {
+	if c.Address != "" {
+		return c.Address
+	}
+	return ":" + c.Port
+}
+
+func (c *Config) network() string {
+	if c.Network == "" {
+		return "tcp"
+	}
+	return c.Network
+}
+
+func (c *Config) socketMode() (os.FileMode, error) {
+	if c.SocketMode == "" {
+		return 0660, nil
+	}
+	mode, err := strconv.ParseUint(c.SocketMode, 8, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid socketMode %q: %w", c.SocketMode, err)
+	}
+	return os.FileMode(mode), nil
+}
+
+// ConfigFromViper 读取监听配置，cfg为nil时使用默认配置
+func ConfigFromViper(cfg *viper.Viper) (*Config, error) {
+	if cfg == nil {
+		cfg = viper.New()
+	}
+
+	cfg.SetDefault("network", "tcp")
+	cfg.SetDefault("readHeaderTimeout", "10s")
+	cfg.SetDefault("readTimeout", "30s")
+	cfg.SetDefault("writeTimeout", "30s")
+	cfg.SetDefault("idleTimeout", "120s")
+	c := &Config{}
+	if err := cfg.Unmarshal(c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
@@ -0,0 +1,151 @@
+package httpserver
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"go.uber.org/zap"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// Server 可配置超时、TLS（证书热加载、mTLS）、h2c与unix socket的http服务
+type Server struct {
+	log  *zap.SugaredLogger
+	name string
+	cfg  Config
+
+	srv      *http.Server
+	reloader *certReloader
+}
+
+func New(name string, cfg *Config, handler http.Handler) (*Server, error) {
+	s := &Server{
+		log:  zap.S().With("module", "pkg.httpserver", "name", name),
+		name: name,
+		cfg:  *cfg,
+	}
+
+	if cfg.network() == "unix" && cfg.ClientIPHeader != "" {
+		handler = clientIPFromHeader(handler, cfg.ClientIPHeader)
+	}
+	if cfg.H2C && !cfg.TLS.Enabled() {
+		handler = h2c.NewHandler(handler, &http2.Server{
+			IdleTimeout: cfg.IdleTimeout,
+		})
+	}
+	s.srv = &http.Server{
+		Handler:           handler,
+		ReadTimeout:       cfg.ReadTimeout,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
+	}
+
+	if cfg.TLS.Enabled() {
+		tlsConfig, err := s.newTLSConfig()
+		if err != nil {
+			return nil, err
+		}
+		s.srv.TLSConfig = tlsConfig
+	}
+
+	return s, nil
+}
+
+func (s *Server) Name() string {
+	return s.name
+}
+
+func (s *Server) newTLSConfig() (*tls.Config, error) {
+	reloader, err := newCertReloader(s.log, s.cfg.TLS.CertFile, s.cfg.TLS.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load certificate: %w", err)
+	}
+	s.reloader = reloader
+
+	tlsConfig := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+	}
+	if s.cfg.TLS.ClientCAFile != "" {
+		pem, err := os.ReadFile(s.cfg.TLS.ClientCAFile)
+		if err != nil {
+			reloader.Close()
+			return nil, fmt.Errorf("load client ca: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			reloader.Close()
+			return nil, fmt.Errorf("no certificate found in %s", s.cfg.TLS.ClientCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConfig, nil
+}
+
+// Listen 监听地址，unix socket会先删除残留的socket文件
+func (s *Server) Listen() (net.Listener, error) {
+	network, address := s.cfg.network(), s.cfg.address()
+	if network != "unix" {
+		return net.Listen(network, address)
+	}
+
+	mode, err := s.cfg.socketMode()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Remove(address); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	ln, err := net.Listen(network, address)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(address, mode); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return ln, nil
+}
+
+// Serve 阻塞处理请求，Shutdown后返回http.ErrServerClosed
+func (s *Server) Serve(ln net.Listener) error {
+	s.log.Infof("HTTP %s server listen on %s://%s tls=%t h2c=%t",
+		s.name, ln.Addr().Network(), ln.Addr(), s.srv.TLSConfig != nil, s.cfg.H2C)
+	if s.srv.TLSConfig != nil {
+		// 证书由GetCertificate提供，ServeTLS同时配置HTTP/2
+		return s.srv.ServeTLS(ln, "", "")
+	}
+	return s.srv.Serve(ln)
+}
+
+// Shutdown 停止接收新连接并等待处理中的请求
+func (s *Server) Shutdown(ctx context.Context) error {
+	err := s.srv.Shutdown(ctx)
+	if s.reloader != nil {
+		_ = s.reloader.Close()
+	}
+	return err
+}
+
+// clientIPFromHeader 用反向代理传入的客户端地址作为RemoteAddr，
+// 多个地址时取最后一个（由最近的代理追加），不合法时保持原值
+func clientIPFromHeader(next http.Handler, header string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		values := strings.Split(r.Header.Get(header), ",")
+		if ip := net.ParseIP(strings.TrimSpace(values[len(values)-1])); ip != nil {
+			r.RemoteAddr = net.JoinHostPort(ip.String(), "0")
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,178 @@
+package influxdb
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/influxdata/influxdb-client-go/v2/api"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+var (
+	metricsOnce sync.Once
+
+	pointsWritten prometheus.Counter
+	pointsDropped *prometheus.CounterVec
+)
+
+func registerMetrics() {
+	pointsWritten = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "influxdb_points_written_total",
+			Help: "How many points written to influxdb.",
+		},
+	)
+	prometheus.MustRegister(pointsWritten)
+
+	pointsDropped = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "influxdb_points_dropped_total",
+			Help: "How many points dropped, partitioned by reason.",
+		},
+		[]string{"reason"},
+	)
+	prometheus.MustRegister(pointsDropped)
+}
+
+// BatchWriter 非阻塞的批量写入，缓存满时丢弃，写入失败按指数退避重试
+type BatchWriter struct {
+	log    *zap.SugaredLogger
+	writer api.WriteAPIBlocking
+
+	points        chan *Point
+	batchSize     int
+	flushInterval time.Duration
+	maxRetries    int
+	retryInterval time.Duration
+
+	closeOnce sync.Once
+	closed    chan struct{}
+	done      chan struct{}
+}
+
+// NewBatchWriter 创建批量写入，bufferSize为内存中最多缓存的点数
+func (c *Client) NewBatchWriter(
+	bufferSize int,
+	batchSize int,
+	flushInterval time.Duration,
+	maxRetries int,
+	retryInterval time.Duration,
+) *BatchWriter {
+	metricsOnce.Do(registerMetrics)
+
+	w := &BatchWriter{
+		log:           zap.S().With("module", "pkg.influxdb"),
+		writer:        c.writer,
+		points:        make(chan *Point, bufferSize),
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+		maxRetries:    maxRetries,
+		retryInterval: retryInterval,
+		closed:        make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+	go w.loop()
+
+	return w
+}
+
+// WritePoint 写入点，不阻塞调用方，返回是否进入缓存
+func (w *BatchWriter) WritePoint(point *Point) bool {
+	select {
+	case <-w.closed:
+		pointsDropped.WithLabelValues("closed").Inc()
+		return false
+	default:
+	}
+
+	select {
+	case w.points <- point:
+		return true
+	default:
+		pointsDropped.WithLabelValues("buffer_full").Inc()
+		return false
+	}
+}
+
+// Shutdown 停止接收并写入缓存中剩余的点
+func (w *BatchWriter) Shutdown(ctx context.Context) error {
+	w.closeOnce.Do(func() {
+		close(w.closed)
+	})
+
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (w *BatchWriter) loop() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*Point, 0, w.batchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		w.flush(batch)
+		batch = make([]*Point, 0, w.batchSize)
+	}
+
+	for {
+		select {
+		case point := <-w.points:
+			batch = append(batch, point)
+			if len(batch) >= w.batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-w.closed:
+			for {
+				select {
+				case point := <-w.points:
+					batch = append(batch, point)
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+func (w *BatchWriter) flush(batch []*Point) {
+	var err error
+retry:
+	for attempt := 0; attempt <= w.maxRetries; attempt++ {
+		// 关闭时不再等待重试，避免阻塞Shutdown
+		if attempt > 0 {
+			timer := time.NewTimer(w.retryInterval * time.Duration(1<<(attempt-1)))
+			select {
+			case <-timer.C:
+			case <-w.closed:
+				timer.Stop()
+				break retry
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		err = w.writer.WritePoint(ctx, batch...)
+		cancel()
+		if err == nil {
+			pointsWritten.Add(float64(len(batch)))
+			return
+		}
+		w.log.Warnf("flush points=%d attempt=%d err=%v", len(batch), attempt+1, err)
+	}
+
+	w.log.Errorf("flush points=%d dropped err=%v", len(batch), err)
+	pointsDropped.WithLabelValues("write_error").Add(float64(len(batch)))
+}
@@ -1,6 +1,10 @@
 package influxdb
 
 import (
+	"context"
+	"errors"
+	"time"
+
 	influxdb "github.com/influxdata/influxdb-client-go/v2"
 	"github.com/influxdata/influxdb-client-go/v2/api"
 	"github.com/influxdata/influxdb-client-go/v2/api/write"
@@ -8,10 +12,26 @@ import (
 
 type Point = write.Point
 
+var (
+	// ErrNotReady ...
+	ErrNotReady = errors.New("influxdb not ready")
+)
+
+// NewPoint ...
+func NewPoint(
+	measurement string,
+	tags map[string]string,
+	fields map[string]interface{},
+	ts time.Time,
+) *Point {
+	return write.NewPoint(measurement, tags, fields, ts)
+}
+
 type Client struct {
 	influxClient influxdb.Client
 	writer       api.WriteAPIBlocking
 	reader       api.QueryAPI
+	bucket       string
 }
 
 func New(
@@ -20,6 +40,7 @@ func New(
 ) *Client {
 	c := &Client{
 		influxClient: influxdb.NewClient(serverURL, authToken),
+		bucket:       bucket,
 	}
 
 	c.writer = c.influxClient.WriteAPIBlocking(org, bucket)
@@ -35,3 +56,24 @@ func (c *Client) Writer() api.WriteAPIBlocking {
 func (c *Client) Reader() api.QueryAPI {
 	return c.reader
 }
+
+func (c *Client) Bucket() string {
+	return c.bucket
+}
+
+// Ping 检查influxdb是否可用
+func (c *Client) Ping(ctx context.Context) error {
+	ok, err := c.influxClient.Ping(ctx)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNotReady
+	}
+	return nil
+}
+
+// Close ...
+func (c *Client) Close() {
+	c.influxClient.Close()
+}
@@ -0,0 +1,102 @@
+package influxdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInvalidAggregate ...
+	ErrInvalidAggregate = errors.New("invalid aggregate function")
+
+	aggregateFns = map[string]bool{
+		"sum":   true,
+		"count": true,
+		"mean":  true,
+		"max":   true,
+		"min":   true,
+		"last":  true,
+	}
+)
+
+// SeriesQuery 时间分桶查询
+type SeriesQuery struct {
+	Measurement string
+	Field       string
+	Start       time.Time
+	Stop        time.Time
+	Every       time.Duration // 分桶间隔
+	Fn          string        // 聚合函数，默认sum
+	GroupBy     string        // 可选的分组tag
+	Tags        map[string]string
+}
+
+// SeriesPoint 分桶结果
+type SeriesPoint struct {
+	Time  time.Time   `json:"time"`
+	Group string      `json:"group,omitempty"`
+	Value interface{} `json:"value"`
+}
+
+// QuerySeries 使用flux查询时间分桶序列
+func (c *Client) QuerySeries(ctx context.Context, q *SeriesQuery) ([]SeriesPoint, error) {
+	fn := q.Fn
+	if fn == "" {
+		fn = "sum"
+	}
+	if !aggregateFns[fn] {
+		return nil, ErrInvalidAggregate
+	}
+
+	filters := []string{
+		"r._measurement == " + strconv.Quote(q.Measurement),
+		"r._field == " + strconv.Quote(q.Field),
+	}
+	for k, v := range q.Tags {
+		filters = append(filters, fmt.Sprintf("r[%s] == %s", strconv.Quote(k), strconv.Quote(v)))
+	}
+
+	flux := fmt.Sprintf(
+		"from(bucket: %s)\n"+
+			"  |> range(start: %s, stop: %s)\n"+
+			"  |> filter(fn: (r) => %s)\n",
+		strconv.Quote(c.bucket),
+		q.Start.UTC().Format(time.RFC3339),
+		q.Stop.UTC().Format(time.RFC3339),
+		strings.Join(filters, " and "),
+	)
+	if q.GroupBy != "" {
+		flux += fmt.Sprintf("  |> group(columns: [%s])\n", strconv.Quote(q.GroupBy))
+	} else {
+		flux += "  |> group()\n"
+	}
+	flux += fmt.Sprintf("  |> aggregateWindow(every: %s, fn: %s, createEmpty: true)\n", q.Every.String(), fn)
+
+	result, err := c.reader.Query(ctx, flux)
+	if err != nil {
+		return nil, err
+	}
+	defer result.Close()
+
+	points := []SeriesPoint{}
+	for result.Next() {
+		record := result.Record()
+		point := SeriesPoint{
+			Time:  record.Time(),
+			Value: record.Value(),
+		}
+		if q.GroupBy != "" {
+			point.Group = fmt.Sprintf("%v", record.ValueByKey(q.GroupBy))
+		}
+		points = append(points, point)
+	}
+	if result.Err() != nil {
+		return nil, result.Err()
+	}
+
+	return points, nil
+}
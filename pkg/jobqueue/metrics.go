@@ -0,0 +1,64 @@
+package jobqueue
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	metricsOnce sync.Once
+
+	enqueued      *prometheus.CounterVec
+	processed     *prometheus.CounterVec
+	duration      *prometheus.HistogramVec
+	queueLength   *prometheus.GaugeVec
+	scheduledRuns *prometheus.CounterVec
+)
+
+func registerMetrics() {
+	enqueued = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "jobs_enqueued_total",
+			Help: "How many jobs enqueued, partitioned by job type.",
+		},
+		[]string{"type"},
+	)
+	prometheus.MustRegister(enqueued)
+
+	processed = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "jobs_processed_total",
+			Help: "How many jobs processed, partitioned by job type and result (ok, retry, dead).",
+		},
+		[]string{"type", "result"},
+	)
+	prometheus.MustRegister(processed)
+
+	duration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name: "job_duration_seconds",
+			Help: "The job processing latencies in seconds.",
+		},
+		[]string{"type"},
+	)
+	prometheus.MustRegister(duration)
+
+	queueLength = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "job_queue_length",
+			Help: "How many jobs in the queue, partitioned by state.",
+		},
+		[]string{"state"},
+	)
+	prometheus.MustRegister(queueLength)
+
+	scheduledRuns = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "job_schedule_runs_total",
+			Help: "How many scheduled runs, partitioned by schedule and result (enqueued, skipped, error).",
+		},
+		[]string{"schedule", "result"},
+	)
+	prometheus.MustRegister(scheduledRuns)
+}
@@ -0,0 +1,117 @@
+package jobqueue
+
+import "time"
+
+type options struct {
+	visibilityTimeout time.Duration
+	pollInterval      time.Duration
+	maxRetries        int
+	retryBackoff      time.Duration
+	maxRetryBackoff   time.Duration
+	deadLimit         int
+}
+
+func defaultOptions() options {
+	return options{
+		visibilityTimeout: 5 * time.Minute,
+		pollInterval:      time.Second,
+		maxRetries:        3,
+		retryBackoff:      5 * time.Second,
+		maxRetryBackoff:   10 * time.Minute,
+		deadLimit:         1000,
+	}
+}
+
+// Option controls the behavior of the Queue.
+type Option func(*options)
+
+// WithVisibilityTimeout 设置任务处理超时时间，超时后任务重新进入就绪列表
+func WithVisibilityTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		if timeout > 0 {
+			o.visibilityTimeout = timeout
+		}
+	}
+}
+
+// WithPollInterval 设置队列为空时的轮询间隔
+func WithPollInterval(interval time.Duration) Option {
+	return func(o *options) {
+		if interval > 0 {
+			o.pollInterval = interval
+		}
+	}
+}
+
+// WithMaxRetries 设置默认最大重试次数
+func WithMaxRetries(retries int) Option {
+	return func(o *options) {
+		if retries >= 0 {
+			o.maxRetries = retries
+		}
+	}
+}
+
+// WithRetryBackoff 设置重试退避的初始与最大间隔
+func WithRetryBackoff(min, max time.Duration) Option {
+	return func(o *options) {
+		if min > 0 {
+			o.retryBackoff = min
+		}
+		if max >= o.retryBackoff {
+			o.maxRetryBackoff = max
+		}
+	}
+}
+
+// WithDeadLimit 设置死信列表保留的任务数量
+func WithDeadLimit(limit int) Option {
+	return func(o *options) {
+		if limit > 0 {
+			o.deadLimit = limit
+		}
+	}
+}
+
+type enqueueOptions struct {
+	processAt  time.Time
+	maxRetries int
+	uniqueKey  string
+	uniqueTTL  time.Duration
+}
+
+// EnqueueOption controls how a job is enqueued.
+type EnqueueOption func(*enqueueOptions)
+
+// WithDelay 延迟执行
+func WithDelay(delay time.Duration) EnqueueOption {
+	return func(o *enqueueOptions) {
+		o.processAt = time.Now().Add(delay)
+	}
+}
+
+// WithProcessAt 在指定时间执行
+func WithProcessAt(t time.Time) EnqueueOption {
+	return func(o *enqueueOptions) {
+		o.processAt = t
+	}
+}
+
+// WithJobMaxRetries 设置该任务的最大重试次数
+func WithJobMaxRetries(retries int) EnqueueOption {
+	return func(o *enqueueOptions) {
+		if retries >= 0 {
+			o.maxRetries = retries
+		}
+	}
+}
+
+// WithUnique 相同key的任务在完成或ttl过期前只会存在一个
+func WithUnique(key string, ttl time.Duration) EnqueueOption {
+	return func(o *enqueueOptions) {
+		if key != "" && ttl > 0 {
+			o.uniqueKey = key
+			o.uniqueTTL = ttl
+		}
+	}
+}
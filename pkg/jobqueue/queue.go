@@ -0,0 +1,290 @@
+package jobqueue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrDuplicate 相同唯一键的任务已在队列中
+	ErrDuplicate = errors.New("job with same unique key already enqueued")
+)
+
+// Job 任务，Payload为json编码的参数
+type Job struct {
+	ID         string          `json:"id"`
+	Type       string          `json:"type"`
+	Payload    json.RawMessage `json:"payload,omitempty"`
+	Attempts   int             `json:"attempts"`
+	MaxRetries int             `json:"maxRetries"`
+	UniqueKey  string          `json:"uniqueKey,omitempty"`
+	EnqueuedAt time.Time       `json:"enqueuedAt"`
+	LastError  string          `json:"lastError,omitempty"`
+}
+
+// Unmarshal 解析任务参数
+func (j *Job) Unmarshal(v interface{}) error {
+	if len(j.Payload) == 0 {
+		return nil
+	}
+	return json.Unmarshal(j.Payload, v)
+}
+
+// Handler 处理任务，返回错误时按退避重试，超过最大重试次数进入死信列表
+type Handler func(ctx context.Context, job *Job) error
+
+// 任务数据保存在hash中，列表与有序集合中只保存id
+var enqueueScript = redis.NewScript(`
+if tonumber(ARGV[4]) > 0 then
+	if not redis.call('SET', KEYS[4], ARGV[1], 'NX', 'PX', ARGV[4]) then
+		return 0
+	end
+end
+redis.call('HSET', KEYS[1], ARGV[1], ARGV[2])
+if tonumber(ARGV[3]) > 0 then
+	redis.call('ZADD', KEYS[3], ARGV[3], ARGV[1])
+else
+	redis.call('LPUSH', KEYS[2], ARGV[1])
+end
+return 1
+`)
+
+var dequeueScript = redis.NewScript(`
+local id = redis.call('RPOP', KEYS[1])
+if not id then
+	return false
+end
+redis.call('ZADD', KEYS[2], ARGV[1], id)
+local data = redis.call('HGET', KEYS[3], id)
+if not data then
+	redis.call('ZREM', KEYS[2], id)
+	return false
+end
+return data
+`)
+
+// promoteScript 将到期的id从有序集合移到就绪列表
+var promoteScript = redis.NewScript(`
+local ids = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, ARGV[2])
+for _, id in ipairs(ids) do
+	redis.call('ZREM', KEYS[1], id)
+	redis.call('RPUSH', KEYS[2], id)
+end
+return #ids
+`)
+
+var releaseUniqueScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+type Queue struct {
+	log      *zap.SugaredLogger
+	rdb      *redis.Client
+	prefix   string
+	opts     options
+	handlers map[string]Handler
+}
+
+// New prefix为redis键前缀
+func New(rdb *redis.Client, prefix string, opts ...Option) *Queue {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+	metricsOnce.Do(registerMetrics)
+
+	return &Queue{
+		log:      zap.S().With("module", "pkg.jobqueue"),
+		rdb:      rdb,
+		prefix:   prefix,
+		opts:     o,
+		handlers: map[string]Handler{},
+	}
+}
+
+func (q *Queue) key(name string) string {
+	return q.prefix + ":" + name
+}
+
+// Enqueue 添加任务，payload会被json编码，设置唯一键且已存在时返回ErrDuplicate
+func (q *Queue) Enqueue(
+	ctx context.Context,
+	jobType string,
+	payload interface{},
+	opts ...EnqueueOption,
+) (*Job, error) {
+	o := enqueueOptions{maxRetries: q.opts.maxRetries}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	job := &Job{
+		ID:         uuid.New().String(),
+		Type:       jobType,
+		MaxRetries: o.maxRetries,
+		UniqueKey:  o.uniqueKey,
+		EnqueuedAt: time.Now(),
+	}
+	if payload != nil {
+		buf, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		job.Payload = buf
+	}
+	data, err := json.Marshal(job)
+	if err != nil {
+		return nil, err
+	}
+
+	var runAt int64
+	if !o.processAt.IsZero() && o.processAt.After(time.Now()) {
+		runAt = o.processAt.UnixMilli()
+	}
+	var uniqueTTL int64
+	if o.uniqueKey != "" {
+		uniqueTTL = o.uniqueTTL.Milliseconds()
+	}
+
+	ok, err := enqueueScript.Run(ctx, q.rdb, []string{
+		q.key("jobs"),
+		q.key("ready"),
+		q.key("delayed"),
+		q.key("unique:" + o.uniqueKey),
+	}, job.ID, data, runAt, uniqueTTL).Int()
+	if err != nil {
+		return nil, err
+	}
+	if ok == 0 {
+		return nil, ErrDuplicate
+	}
+
+	enqueued.WithLabelValues(jobType).Inc()
+	return job, nil
+}
+
+// dequeue 取出一个就绪任务并放入处理中集合，没有任务时返回nil
+func (q *Queue) dequeue(ctx context.Context) (*Job, error) {
+	deadline := time.Now().Add(q.opts.visibilityTimeout).UnixMilli()
+	data, err := dequeueScript.Run(ctx, q.rdb, []string{
+		q.key("ready"),
+		q.key("inflight"),
+		q.key("jobs"),
+	}, deadline).Text()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	job := &Job{}
+	if err := json.Unmarshal([]byte(data), job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// complete 任务处理成功，删除任务数据
+func (q *Queue) complete(ctx context.Context, job *Job) error {
+	_, err := q.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRem(ctx, q.key("inflight"), job.ID)
+		pipe.HDel(ctx, q.key("jobs"), job.ID)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return q.releaseUnique(ctx, job)
+}
+
+// retry 更新处理次数并放入延迟集合
+func (q *Queue) retry(ctx context.Context, job *Job, delay time.Duration) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	_, err = q.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, q.key("jobs"), job.ID, data)
+		pipe.ZRem(ctx, q.key("inflight"), job.ID)
+		pipe.ZAdd(ctx, q.key("delayed"), &redis.Z{
+			Score:  float64(time.Now().Add(delay).UnixMilli()),
+			Member: job.ID,
+		})
+		return nil
+	})
+	return err
+}
+
+// kill 超过重试次数，移入死信列表，只保留最近deadLimit条
+func (q *Queue) kill(ctx context.Context, job *Job) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	_, err = q.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRem(ctx, q.key("inflight"), job.ID)
+		pipe.HDel(ctx, q.key("jobs"), job.ID)
+		pipe.LPush(ctx, q.key("dead"), data)
+		pipe.LTrim(ctx, q.key("dead"), 0, int64(q.opts.deadLimit-1))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return q.releaseUnique(ctx, job)
+}
+
+func (q *Queue) releaseUnique(ctx context.Context, job *Job) error {
+	if job.UniqueKey == "" {
+		return nil
+	}
+	return releaseUniqueScript.Run(ctx, q.rdb, []string{
+		q.key("unique:" + job.UniqueKey),
+	}, job.ID).Err()
+}
+
+// promote 将到期的延迟任务与处理超时的任务放回就绪列表
+func (q *Queue) promote(ctx context.Context) error {
+	now := time.Now().UnixMilli()
+	for _, name := range []string{"delayed", "inflight"} {
+		n, err := promoteScript.Run(ctx, q.rdb, []string{
+			q.key(name),
+			q.key("ready"),
+		}, now, 1000).Int()
+		if err != nil {
+			return err
+		}
+		if name == "inflight" && n > 0 {
+			q.log.Warnf("Requeued %d jobs exceeding visibility timeout", n)
+		}
+	}
+	return nil
+}
+
+// Stats 各状态的任务数量
+func (q *Queue) Stats(ctx context.Context) (map[string]int64, error) {
+	pipe := q.rdb.Pipeline()
+	ready := pipe.LLen(ctx, q.key("ready"))
+	delayed := pipe.ZCard(ctx, q.key("delayed"))
+	inflight := pipe.ZCard(ctx, q.key("inflight"))
+	dead := pipe.LLen(ctx, q.key("dead"))
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+	return map[string]int64{
+		"ready":    ready.Val(),
+		"delayed":  delayed.Val(),
+		"inflight": inflight.Val(),
+		"dead":     dead.Val(),
+	}, nil
+}
@@ -0,0 +1,130 @@
+package jobqueue
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/go-redsync/redsync/v4"
+	"github.com/robfig/cron/v3"
+	"go.uber.org/zap"
+)
+
+const scheduleLockTTL = time.Minute
+
+type schedule struct {
+	name    string
+	spec    cron.Schedule
+	jobType string
+	payload interface{}
+	next    time.Time
+}
+
+// Scheduler 按cron表达式定时添加任务，多个实例同时运行时每次触发只有获得redsync锁的实例添加
+type Scheduler struct {
+	log           *zap.SugaredLogger
+	queue         *Queue
+	redSyncClient *redsync.Redsync
+	parser        cron.Parser
+	schedules     []*schedule
+}
+
+func NewScheduler(queue *Queue, redSyncClient *redsync.Redsync) *Scheduler {
+	return &Scheduler{
+		log:           zap.S().With("module", "pkg.jobqueue.scheduler"),
+		queue:         queue,
+		redSyncClient: redSyncClient,
+		parser: cron.NewParser(
+			cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor,
+		),
+	}
+}
+
+// Add 添加定时任务，spec为标准5位cron表达式或@every 1h等描述符，需在Run之前调用
+func (s *Scheduler) Add(name, spec, jobType string, payload interface{}) error {
+	sched, err := s.parser.Parse(spec)
+	if err != nil {
+		return err
+	}
+	s.schedules = append(s.schedules, &schedule{
+		name:    name,
+		spec:    sched,
+		jobType: jobType,
+		payload: payload,
+	})
+	return nil
+}
+
+// Run 阻塞直到ctx结束
+func (s *Scheduler) Run(ctx context.Context) {
+	if len(s.schedules) == 0 {
+		return
+	}
+
+	now := time.Now()
+	for _, sched := range s.schedules {
+		sched.next = sched.spec.Next(now)
+	}
+
+	for {
+		earliest := s.schedules[0].next
+		for _, sched := range s.schedules[1:] {
+			if sched.next.Before(earliest) {
+				earliest = sched.next
+			}
+		}
+
+		timer := time.NewTimer(time.Until(earliest))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		now := time.Now()
+		for _, sched := range s.schedules {
+			if sched.next.After(now) {
+				continue
+			}
+			s.fire(ctx, sched)
+			sched.next = sched.spec.Next(now)
+		}
+	}
+}
+
+func (s *Scheduler) fire(ctx context.Context, sched *schedule) {
+	// 锁名包含触发时间，不主动释放，其他实例在过期前都无法获得
+	mutex := s.redSyncClient.NewMutex(
+		s.queue.key("schedule:"+sched.name+":"+strconv.FormatInt(sched.next.Unix(), 10)),
+		redsync.WithExpiry(scheduleLockTTL),
+		redsync.WithTries(1),
+	)
+	if err := mutex.LockContext(ctx); err != nil {
+		return
+	}
+
+	// 上一次的任务未完成时跳过
+	uniqueTTL := sched.spec.Next(sched.next).Sub(sched.next)
+	if uniqueTTL < scheduleLockTTL {
+		uniqueTTL = scheduleLockTTL
+	}
+	_, err := s.queue.Enqueue(
+		ctx,
+		sched.jobType,
+		sched.payload,
+		WithUnique("schedule:"+sched.name, uniqueTTL),
+	)
+	switch {
+	case err == nil:
+		scheduledRuns.WithLabelValues(sched.name, "enqueued").Inc()
+		s.log.Infof("Schedule %s enqueued job type=%s", sched.name, sched.jobType)
+	case errors.Is(err, ErrDuplicate):
+		scheduledRuns.WithLabelValues(sched.name, "skipped").Inc()
+		s.log.Warnf("Schedule %s skipped, previous job still pending", sched.name)
+	default:
+		scheduledRuns.WithLabelValues(sched.name, "error").Inc()
+		s.log.Errorf("Schedule %s enqueue err=%v", sched.name, err)
+	}
+}
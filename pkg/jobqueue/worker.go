@@ -0,0 +1,137 @@
+package jobqueue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// Register 注册任务处理函数，需在Run之前调用
+func (q *Queue) Register(jobType string, handler Handler) {
+	q.handlers[jobType] = handler
+}
+
+// Run 启动concurrency个worker处理任务，阻塞直到ctx结束且处理中的任务完成
+func (q *Queue) Run(ctx context.Context, concurrency int) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		q.promoteLoop(ctx)
+	}()
+
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q.work(ctx)
+		}()
+	}
+
+	wg.Wait()
+}
+
+func (q *Queue) promoteLoop(ctx context.Context) {
+	ticker := time.NewTicker(q.opts.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := q.promote(ctx); err != nil && ctx.Err() == nil {
+			q.log.Errorf("Promote jobs err=%v", err)
+		}
+		stats, err := q.Stats(ctx)
+		if err != nil {
+			continue
+		}
+		for state, n := range stats {
+			queueLength.WithLabelValues(state).Set(float64(n))
+		}
+	}
+}
+
+func (q *Queue) work(ctx context.Context) {
+	for ctx.Err() == nil {
+		job, err := q.dequeue(ctx)
+		if err != nil && ctx.Err() == nil {
+			q.log.Errorf("Dequeue job err=%v", err)
+		}
+		if job == nil {
+			select {
+			case <-ctx.Done():
+			case <-time.After(q.opts.pollInterval):
+			}
+			continue
+		}
+		q.process(job)
+	}
+}
+
+// process 使用独立的ctx，退出时处理中的任务可以完成
+func (q *Queue) process(job *Job) {
+	ctx, cancel := context.WithTimeout(context.Background(), q.opts.visibilityTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := q.call(ctx, job)
+	duration.WithLabelValues(job.Type).Observe(time.Since(start).Seconds())
+
+	job.Attempts++
+	switch {
+	case err == nil:
+		processed.WithLabelValues(job.Type, "ok").Inc()
+		err = q.complete(ctx, job)
+	case job.Attempts > job.MaxRetries:
+		processed.WithLabelValues(job.Type, "dead").Inc()
+		q.log.Errorf("Job dead id=%s type=%s attempts=%d err=%v", job.ID, job.Type, job.Attempts, err)
+		job.LastError = err.Error()
+		err = q.kill(ctx, job)
+	default:
+		processed.WithLabelValues(job.Type, "retry").Inc()
+		delay := q.backoff(job.Attempts)
+		q.log.Warnf("Job retry id=%s type=%s attempts=%d delay=%s err=%v", job.ID, job.Type, job.Attempts, delay, err)
+		job.LastError = err.Error()
+		err = q.retry(ctx, job, delay)
+	}
+	if err != nil {
+		// 状态未更新的任务在处理超时后重新执行
+		q.log.Errorf("Update job id=%s err=%v", job.ID, err)
+	}
+}
+
+func (q *Queue) call(ctx context.Context, job *Job) (err error) {
+	handler, ok := q.handlers[job.Type]
+	if !ok {
+		return errors.New("no handler registered for job type " + job.Type)
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panic: %v", r)
+			q.log.Errorf("Job panic id=%s type=%s err=%v\n%s", job.ID, job.Type, r, debug.Stack())
+		}
+	}()
+	return handler(ctx, job)
+}
+
+func (q *Queue) backoff(attempts int) time.Duration {
+	d := q.opts.retryBackoff
+	for i := 1; i < attempts; i++ {
+		d *= 2
+		if d >= q.opts.maxRetryBackoff {
+			return q.opts.maxRetryBackoff
+		}
+	}
+	return d
+}
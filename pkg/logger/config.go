@@ -0,0 +1,57 @@
+package logger
+
+import "time"
+
+// Config 日志配置
+type Config struct {
+	Format   string         `mapstructure:"format"` // json | console
+	Outputs  []OutputConfig `mapstructure:"outputs"`
+	Modules  []ModuleConfig `mapstructure:"modules"`
+	Sampling SamplingConfig `mapstructure:"sampling"`
+	Sink     SinkConfig     `mapstructure:"sink"`
+}
+
+// OutputConfig 日志输出，Format、Level为空时使用全局配置
+type OutputConfig struct {
+	Type   string `mapstructure:"type"` // stdout | stderr | file
+	Format string `mapstructure:"format"`
+	Level  string `mapstructure:"level"`
+
+	// 以下仅对file生效
+	Filename   string `mapstructure:"filename"`
+	MaxSize    int    `mapstructure:"maxSize"`    // 单个文件最大MB，超出后切割
+	MaxAge     int    `mapstructure:"maxAge"`     // 保留天数，0为不限制
+	MaxBackups int    `mapstructure:"maxBackups"` // 保留文件数，0为不限制
+	Compress   bool   `mapstructure:"compress"`
+	Rotate     string `mapstructure:"rotate"` // 按时间切割：hourly | daily，为空时只按大小切割
+}
+
+// ModuleConfig 按module设置日志级别，如pkg.database、services.account
+type ModuleConfig struct {
+	Name  string `mapstructure:"name"`
+	Level string `mapstructure:"level"`
+}
+
+// SamplingConfig 每个tick内相同级别与内容的日志，输出前Initial条，之后每Thereafter条输出1条
+type SamplingConfig struct {
+	Enable     bool          `mapstructure:"enable"`
+	Tick       time.Duration `mapstructure:"tick"`
+	Initial    int           `mapstructure:"initial"`
+	Thereafter int           `mapstructure:"thereafter"`
+}
+
+// SinkConfig 异步批量发送日志到外部存储
+type SinkConfig struct {
+	Enable        bool          `mapstructure:"enable"`
+	Type          string        `mapstructure:"type"` // rabbitmq | clickhouse
+	Level         string        `mapstructure:"level"`
+	BufferSize    int           `mapstructure:"bufferSize"`
+	BatchSize     int           `mapstructure:"batchSize"`
+	FlushInterval time.Duration `mapstructure:"flushInterval"`
+	// 不发送的module，避免发送失败产生的日志再次进入sink
+	ExcludeModules []string `mapstructure:"excludeModules"`
+
+	// rabbitmq
+	Exchange   string `mapstructure:"exchange"`
+	RoutingKey string `mapstructure:"routingKey"`
+}
@@ -0,0 +1,28 @@
+package logger
+
+import (
+	"context"
+
+	"lovebox/pkg/requestid"
+	"lovebox/pkg/tracing"
+
+	"go.uber.org/zap"
+)
+
+// WithContext 为日志添加context中的requestId、traceId与spanId
+func WithContext(ctx context.Context, log *zap.SugaredLogger) *zap.SugaredLogger {
+	var fields []interface{}
+	if id := requestid.FromContext(ctx); id != "" {
+		fields = append(fields, "requestId", id)
+	}
+	if traceID := tracing.TraceIDFromContext(ctx); traceID != "" {
+		fields = append(fields,
+			"traceId", traceID,
+			"spanId", tracing.SpanIDFromContext(ctx),
+		)
+	}
+	if len(fields) == 0 {
+		return log
+	}
+	return log.With(fields...)
+}
@@ -1,33 +1,224 @@
 package logger
 
 import (
+	"context"
+	"fmt"
+	"io"
 	"os"
+	"sync"
+	"time"
 
+	"github.com/spf13/viper"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
 
+var (
+	mu      sync.Mutex
+	closers []io.Closer
+	sink    *asyncSink
+)
+
 // LogCallback ...
 type LogCallback func(entry zapcore.Entry) error
 
-// InitLogger ...
+// InitLogger 读取配置前使用的默认日志，console格式输出到stdout
 func InitLogger(atom zap.AtomicLevel) {
-	encoderCfg := zap.NewProductionEncoderConfig()
-	encoderCfg.EncodeTime = zapcore.TimeEncoderOfLayout("2006-01-02T15:04:05.999Z07:00")
-
 	logger := zap.New(zapcore.NewCore(
-		// zapcore.NewJSONEncoder(encoderCfg),
-		zapcore.NewConsoleEncoder(encoderCfg),
+		zapcore.NewConsoleEncoder(newEncoderConfig()),
 		zapcore.Lock(os.Stdout),
 		atom,
 	))
 	_ = zap.ReplaceGlobals(logger)
 }
 
-// RegisterCallback ...
+// Configure 按配置重建全局日志，cfg为nil时使用默认配置；
+// atom为全局级别，未单独配置级别的module都使用它
+func Configure(atom zap.AtomicLevel, cfg *viper.Viper) error {
+	if cfg == nil {
+		cfg = viper.New()
+	}
+
+	cfg.SetDefault("format", "console")
+	cfg.SetDefault("outputs", []map[string]interface{}{{"type": "stdout"}})
+	cfg.SetDefault("sampling.tick", "1s")
+	cfg.SetDefault("sampling.initial", 100)
+	cfg.SetDefault("sampling.thereafter", 100)
+	cfg.SetDefault("sink.level", "info")
+	cfg.SetDefault("sink.bufferSize", 10000)
+	cfg.SetDefault("sink.batchSize", 500)
+	cfg.SetDefault("sink.flushInterval", "5s")
+	c := &Config{}
+	if err := cfg.Unmarshal(c); err != nil {
+		return err
+	}
+
+	var cores []zapcore.Core
+	var newClosers []io.Closer
+	for i := range c.Outputs {
+		core, closer, err := newOutputCore(c.Format, &c.Outputs[i])
+		if err != nil {
+			closeAll(newClosers)
+			return err
+		}
+		cores = append(cores, core)
+		if closer != nil {
+			newClosers = append(newClosers, closer)
+		}
+	}
+
+	var newSink *asyncSink
+	if c.Sink.Enable {
+		level, err := parseLevel(c.Sink.Level)
+		if err != nil {
+			closeAll(newClosers)
+			return err
+		}
+		excludes := map[string]bool{}
+		for _, module := range defaultExcludeModules(c.Sink.Type) {
+			excludes[module] = true
+		}
+		for _, module := range c.Sink.ExcludeModules {
+			excludes[module] = true
+		}
+		fallback := zap.New(zapcore.NewTee(cores...)).Sugar().With("module", "pkg.logger")
+		newSink = newAsyncSink(fallback, c.Sink.BufferSize, c.Sink.BatchSize, c.Sink.FlushInterval)
+		cores = append(cores, &sinkCore{
+			LevelEnabler: level,
+			sink:         newSink,
+			excludes:     excludes,
+		})
+	}
+
+	modules := make(map[string]zapcore.Level, len(c.Modules))
+	for _, m := range c.Modules {
+		level, err := parseLevel(m.Level)
+		if err != nil {
+			closeAll(newClosers)
+			return fmt.Errorf("log module %s: %w", m.Name, err)
+		}
+		modules[m.Name] = level
+	}
+
+	core := newModuleCore(zapcore.NewTee(cores...), atom, modules)
+	if c.Sampling.Enable {
+		core = zapcore.NewSamplerWithOptions(core, c.Sampling.Tick, c.Sampling.Initial, c.Sampling.Thereafter)
+	}
+
+	mu.Lock()
+	oldClosers, oldSink := closers, sink
+	closers, sink = newClosers, newSink
+	mu.Unlock()
+
+	_ = zap.ReplaceGlobals(zap.New(core))
+
+	if oldSink != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = oldSink.shutdown(ctx)
+	}
+	closeAll(oldClosers)
+	return nil
+}
+
+// SetSink 设置异步发送的目标，未启用sink时忽略
+func SetSink(s Sink) {
+	mu.Lock()
+	defer mu.Unlock()
+	if sink != nil {
+		sink.start(s)
+	}
+}
+
+// Shutdown 发送sink中剩余的日志并刷新输出
+func Shutdown(ctx context.Context) error {
+	mu.Lock()
+	s := sink
+	mu.Unlock()
+
+	if s != nil {
+		if err := s.shutdown(ctx); err != nil {
+			return err
+		}
+	}
+	_ = zap.L().Sync()
+	return nil
+}
+
+// RegisterCallback 为全局日志添加回调，保留原有的级别与选项
 func RegisterCallback(cb LogCallback) {
-	core := zap.L().Core()
-	core = zapcore.RegisterHooks(core, cb)
-	logger := zap.New(core)
-	_ = zap.ReplaceGlobals(logger)
+	_ = zap.ReplaceGlobals(zap.L().WithOptions(zap.Hooks(cb)))
+}
+
+func newEncoderConfig() zapcore.EncoderConfig {
+	encoderCfg := zap.NewProductionEncoderConfig()
+	encoderCfg.EncodeTime = zapcore.TimeEncoderOfLayout("2006-01-02T15:04:05.999Z07:00")
+	return encoderCfg
+}
+
+func newEncoder(format string) (zapcore.Encoder, error) {
+	switch format {
+	case "json":
+		return zapcore.NewJSONEncoder(newEncoderConfig()), nil
+	case "console":
+		return zapcore.NewConsoleEncoder(newEncoderConfig()), nil
+	default:
+		return nil, fmt.Errorf("unknown log format %q", format)
+	}
+}
+
+func newOutputCore(format string, c *OutputConfig) (zapcore.Core, io.Closer, error) {
+	if c.Format != "" {
+		format = c.Format
+	}
+	encoder, err := newEncoder(format)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// 级别由moduleCore控制，这里只做输出自身的过滤
+	level := zapcore.DebugLevel
+	if c.Level != "" {
+		if level, err = parseLevel(c.Level); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	switch c.Type {
+	case "stdout":
+		return zapcore.NewCore(encoder, zapcore.Lock(os.Stdout), level), nil, nil
+	case "stderr":
+		return zapcore.NewCore(encoder, zapcore.Lock(os.Stderr), level), nil, nil
+	case "file":
+		w, err := newRotateWriter(c)
+		if err != nil {
+			return nil, nil, err
+		}
+		return zapcore.NewCore(encoder, zapcore.AddSync(w), level), w, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown log output %q", c.Type)
+	}
+}
+
+// defaultExcludeModules sink自身依赖的模块
+func defaultExcludeModules(sinkType string) []string {
+	switch sinkType {
+	case "rabbitmq":
+		return []string{"pkg.rabbitmq"}
+	case "clickhouse":
+		return []string{"pkg.analytics"}
+	}
+	return nil
+}
+
+func parseLevel(text string) (zapcore.Level, error) {
+	var level zapcore.Level
+	err := level.UnmarshalText([]byte(text))
+	return level, err
+}
+
+func closeAll(cs []io.Closer) {
+	for _, c := range cs {
+		_ = c.Close()
+	}
 }
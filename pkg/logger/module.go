@@ -0,0 +1,75 @@
+package logger
+
+import (
+	"sort"
+	"strings"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// moduleCore 按日志的module字段（zap.S().With("module", ...)）使用不同级别，
+// 未配置的module使用全局级别；module按"."分段前缀匹配，最长匹配优先
+type moduleCore struct {
+	zapcore.Core
+
+	global  zapcore.LevelEnabler
+	modules []moduleLevel
+	level   zapcore.LevelEnabler // 当前logger匹配到的级别，nil时使用global
+}
+
+type moduleLevel struct {
+	prefix string
+	level  zapcore.Level
+}
+
+func newModuleCore(core zapcore.Core, global zapcore.LevelEnabler, levels map[string]zapcore.Level) zapcore.Core {
+	modules := make([]moduleLevel, 0, len(levels))
+	for prefix, level := range levels {
+		modules = append(modules, moduleLevel{prefix: prefix, level: level})
+	}
+	// 前缀长的优先
+	sort.Slice(modules, func(i, j int) bool {
+		return len(modules[i].prefix) > len(modules[j].prefix)
+	})
+	return &moduleCore{
+		Core:    core,
+		global:  global,
+		modules: modules,
+	}
+}
+
+func (c *moduleCore) Enabled(level zapcore.Level) bool {
+	if c.level != nil {
+		return c.level.Enabled(level)
+	}
+	return c.global.Enabled(level)
+}
+
+func (c *moduleCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := *c
+	clone.Core = c.Core.With(fields)
+	for _, f := range fields {
+		if f.Key == "module" && f.Type == zapcore.StringType {
+			if level, ok := c.match(f.String); ok {
+				clone.level = level
+			}
+		}
+	}
+	return &clone
+}
+
+func (c *moduleCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.Enabled(ent.Level) {
+		return ce
+	}
+	return c.Core.Check(ent, ce)
+}
+
+func (c *moduleCore) match(module string) (zapcore.Level, bool) {
+	for _, m := range c.modules {
+		if module == m.prefix || strings.HasPrefix(module, m.prefix+".") {
+			return m.level, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,35 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+
+	"lovebox/pkg/rabbitmq"
+)
+
+// NewRabbitMQSink 每批日志发布为一条NDJSON消息
+func NewRabbitMQSink(publisher *rabbitmq.Client, exchange, routingKey string) Sink {
+	return SinkFunc(func(ctx context.Context, entries []*Entry) error {
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		for _, entry := range entries {
+			if err := enc.Encode(entry); err != nil {
+				return err
+			}
+		}
+
+		err := publisher.ProduceWithParam(ctx, &rabbitmq.ProduceParam{
+			Exchange:    exchange,
+			RoutingKey:  routingKey,
+			Body:        buf.Bytes(),
+			ContentType: "application/x-ndjson",
+		})
+		// 没有队列消费日志
+		if errors.Is(err, rabbitmq.ErrUnroutable) {
+			return nil
+		}
+		return err
+	})
+}
@@ -0,0 +1,74 @@
+package logger
+
+import (
+	"fmt"
+	"time"
+
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// rotateWriter 按大小切割的文件输出，interval不为0时同时按时间切割
+type rotateWriter struct {
+	*lumberjack.Logger
+
+	stop chan struct{}
+}
+
+func newRotateWriter(c *OutputConfig) (*rotateWriter, error) {
+	if c.Filename == "" {
+		return nil, fmt.Errorf("log output file requires filename")
+	}
+
+	var interval time.Duration
+	switch c.Rotate {
+	case "":
+	case "hourly":
+		interval = time.Hour
+	case "daily":
+		interval = 24 * time.Hour
+	default:
+		return nil, fmt.Errorf("unknown log rotate %q", c.Rotate)
+	}
+
+	w := &rotateWriter{
+		Logger: &lumberjack.Logger{
+			Filename:   c.Filename,
+			MaxSize:    c.MaxSize,
+			MaxAge:     c.MaxAge,
+			MaxBackups: c.MaxBackups,
+			LocalTime:  true,
+			Compress:   c.Compress,
+		},
+		stop: make(chan struct{}),
+	}
+	if interval > 0 {
+		go w.loop(interval)
+	}
+	return w, nil
+}
+
+// loop 在整点（或零点）切割
+func (w *rotateWriter) loop(interval time.Duration) {
+	for {
+		now := time.Now()
+		next := now.Truncate(time.Hour).Add(time.Hour)
+		if interval == 24*time.Hour {
+			y, m, d := now.Date()
+			next = time.Date(y, m, d+1, 0, 0, 0, 0, now.Location())
+		}
+
+		timer := time.NewTimer(next.Sub(now))
+		select {
+		case <-timer.C:
+			_ = w.Rotate()
+		case <-w.stop:
+			timer.Stop()
+			return
+		}
+	}
+}
+
+func (w *rotateWriter) Close() error {
+	close(w.stop)
+	return w.Logger.Close()
+}
@@ -0,0 +1,242 @@
+package logger
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+var (
+	metricsOnce sync.Once
+
+	sinkEntries *prometheus.CounterVec
+)
+
+func registerMetrics() {
+	sinkEntries = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "logger_sink_entries_total",
+			Help: "How many log entries shipped to the async sink, partitioned by result.",
+		},
+		[]string{"result"},
+	)
+	prometheus.MustRegister(sinkEntries)
+}
+
+// Entry 发送到sink的日志
+type Entry struct {
+	Time      time.Time              `json:"time"`
+	Level     string                 `json:"level"`
+	Module    string                 `json:"module,omitempty"`
+	Message   string                 `json:"message"`
+	RequestID string                 `json:"requestId,omitempty"`
+	TraceID   string                 `json:"traceId,omitempty"`
+	SpanID    string                 `json:"spanId,omitempty"`
+	Fields    map[string]interface{} `json:"fields,omitempty"`
+}
+
+// Sink 日志批量写入的目标
+type Sink interface {
+	Write(ctx context.Context, entries []*Entry) error
+}
+
+// SinkFunc ...
+type SinkFunc func(ctx context.Context, entries []*Entry) error
+
+func (f SinkFunc) Write(ctx context.Context, entries []*Entry) error {
+	return f(ctx, entries)
+}
+
+// asyncSink 缓存日志并批量写入sink，缓存满时丢弃；
+// 在SetSink之前产生的日志留在缓存中，启动后再发送
+type asyncSink struct {
+	log *zap.SugaredLogger // 不经过sink的logger，用于输出sink自身的错误
+
+	entries       chan *Entry
+	batchSize     int
+	flushInterval time.Duration
+
+	sink      Sink
+	startOnce sync.Once
+	closeOnce sync.Once
+	closed    chan struct{}
+	done      chan struct{}
+}
+
+func newAsyncSink(log *zap.SugaredLogger, bufferSize, batchSize int, flushInterval time.Duration) *asyncSink {
+	metricsOnce.Do(registerMetrics)
+
+	return &asyncSink{
+		log:           log,
+		entries:       make(chan *Entry, bufferSize),
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+		closed:        make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+}
+
+func (s *asyncSink) start(sink Sink) {
+	s.startOnce.Do(func() {
+		s.sink = sink
+		go s.loop()
+	})
+}
+
+func (s *asyncSink) enqueue(entry *Entry) {
+	select {
+	case <-s.closed:
+		sinkEntries.WithLabelValues("dropped").Inc()
+		return
+	default:
+	}
+
+	select {
+	case s.entries <- entry:
+	default:
+		sinkEntries.WithLabelValues("dropped").Inc()
+	}
+}
+
+// shutdown 停止接收日志并发送缓存中剩余的日志，未启动时直接返回
+func (s *asyncSink) shutdown(ctx context.Context) error {
+	s.closeOnce.Do(func() {
+		close(s.closed)
+	})
+	if s.sink == nil {
+		return nil
+	}
+
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *asyncSink) loop() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*Entry, 0, s.batchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		s.flush(batch)
+		batch = make([]*Entry, 0, s.batchSize)
+	}
+
+	for {
+		select {
+		case entry := <-s.entries:
+			batch = append(batch, entry)
+			if len(batch) >= s.batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-s.closed:
+			for {
+				select {
+				case entry := <-s.entries:
+					batch = append(batch, entry)
+					if len(batch) >= s.batchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+func (s *asyncSink) flush(batch []*Entry) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := s.sink.Write(ctx, batch); err != nil {
+		s.log.Warnf("Log sink write entries=%d err=%v", len(batch), err)
+		sinkEntries.WithLabelValues("error").Add(float64(len(batch)))
+		return
+	}
+	sinkEntries.WithLabelValues("sent").Add(float64(len(batch)))
+}
+
+// sinkCore 将日志写入asyncSink的zapcore.Core
+type sinkCore struct {
+	zapcore.LevelEnabler
+
+	sink     *asyncSink
+	fields   []zapcore.Field
+	module   string
+	excludes map[string]bool
+}
+
+func (c *sinkCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := *c
+	clone.fields = make([]zapcore.Field, 0, len(c.fields)+len(fields))
+	clone.fields = append(clone.fields, c.fields...)
+	clone.fields = append(clone.fields, fields...)
+	for _, f := range fields {
+		if f.Key == "module" && f.Type == zapcore.StringType {
+			clone.module = f.String
+		}
+	}
+	return &clone
+}
+
+func (c *sinkCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) && !c.excludes[c.module] {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *sinkCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	enc := zapcore.NewMapObjectEncoder()
+	for _, f := range c.fields {
+		f.AddTo(enc)
+	}
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+
+	entry := &Entry{
+		Time:    ent.Time,
+		Level:   ent.Level.String(),
+		Message: ent.Message,
+		Fields:  enc.Fields,
+	}
+	entry.Module = popString(enc.Fields, "module")
+	entry.RequestID = popString(enc.Fields, "requestId")
+	entry.TraceID = popString(enc.Fields, "traceId")
+	entry.SpanID = popString(enc.Fields, "spanId")
+	if len(entry.Fields) == 0 {
+		entry.Fields = nil
+	}
+
+	c.sink.enqueue(entry)
+	return nil
+}
+
+func (c *sinkCore) Sync() error {
+	return nil
+}
+
+func popString(fields map[string]interface{}, key string) string {
+	v, ok := fields[key].(string)
+	if ok {
+		delete(fields, key)
+	}
+	return v
+}
@@ -2,15 +2,27 @@ package mongodb
 
 import (
 	"context"
+	"sync"
 	"time"
 
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.uber.org/zap"
 )
 
+// indexEnsurer 启动时需要创建索引的集合
+type indexEnsurer interface {
+	EnsureIndexes(ctx context.Context) error
+}
+
 type Client struct {
-	// log *zap.SugaredLogger
-	db *mongo.Database
+	log    *zap.SugaredLogger
+	client *mongo.Client
+	db     *mongo.Database
+
+	mu    sync.Mutex
+	repos []indexEnsurer
 }
 
 func NewMongo(uri, name string, timeout time.Duration, num uint64) (*Client, error) {
@@ -19,10 +31,44 @@ func NewMongo(uri, name string, timeout time.Duration, num uint64) (*Client, err
 	if err != nil {
 		return nil, err
 	}
-	client := Client{db: cli.Database(name)}
+	client := Client{
+		log:    zap.S().With("module", "pkg.mongodb"),
+		client: cli,
+		db:     cli.Database(name),
+	}
 	return &client, nil
 }
 
 func (client *Client) Db() *mongo.Database {
 	return client.db
 }
+
+// Ping 检查mongodb是否可用
+func (client *Client) Ping(ctx context.Context) error {
+	return client.client.Ping(ctx, readpref.Primary())
+}
+
+// Disconnect 关闭连接
+func (client *Client) Disconnect(ctx context.Context) error {
+	return client.client.Disconnect(ctx)
+}
+
+// EnsureIndexes 创建所有已注册仓库声明的索引，在创建仓库之后、提供服务之前调用
+func (client *Client) EnsureIndexes(ctx context.Context) error {
+	client.mu.Lock()
+	repos := append([]indexEnsurer{}, client.repos...)
+	client.mu.Unlock()
+
+	for _, repo := range repos {
+		if err := repo.EnsureIndexes(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (client *Client) register(repo indexEnsurer) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	client.repos = append(client.repos, repo)
+}
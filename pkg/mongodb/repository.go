@@ -0,0 +1,294 @@
+package mongodb
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"lovebox/pkg/errors"
+	"lovebox/pkg/query"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	fieldID        = "_id"
+	fieldUpdatedAt = "updated_at"
+	fieldDeletedAt = "deleted_at"
+
+	// queryTag 与mysql分页相同的查询白名单标签，包含sort时字段可排序
+	queryTag = "query"
+)
+
+var (
+	// ErrInvalidID ...
+	ErrInvalidID = errors.New("invalid object id")
+)
+
+// ErrInvalidSortFields 不在白名单内的排序字段
+func ErrInvalidSortFields(fields []string) error {
+	return errors.NewWithStatusDetail(
+		"查询参数不合法: "+strings.Join(fields, ", "),
+		http.StatusBadRequest,
+		fields,
+	)
+}
+
+// Model 文档基础字段，DeletedAt不为空表示已软删除
+type Model struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id" query:"sort"`
+	CreatedAt time.Time          `bson:"created_at" json:"createdAt" query:"sort"`
+	UpdatedAt time.Time          `bson:"updated_at" json:"updatedAt" query:"sort"`
+	DeletedAt *time.Time         `bson:"deleted_at,omitempty" json:"deletedAt,omitempty"`
+}
+
+// BeforeInsert 插入前设置id与时间
+func (m *Model) BeforeInsert(now time.Time) {
+	if m.ID.IsZero() {
+		m.ID = primitive.NewObjectID()
+	}
+	m.CreatedAt = now
+	m.UpdatedAt = now
+}
+
+// Indexer 文档声明的索引，仓库创建时注册，由Client.EnsureIndexes统一创建
+type Indexer interface {
+	Indexes() []mongo.IndexModel
+}
+
+type beforeInserter interface {
+	BeforeInsert(now time.Time)
+}
+
+// Repository 类型化的集合操作，查询默认排除软删除的文档
+type Repository[T any] struct {
+	client     *Client
+	collection *mongo.Collection
+	sortFields map[string]string
+}
+
+// NewRepository 创建仓库，T实现Indexer时由Client.EnsureIndexes创建索引
+func NewRepository[T any](client *Client, collection string) *Repository[T] {
+	repo := &Repository[T]{
+		client:     client,
+		collection: client.Db().Collection(collection),
+		sortFields: map[string]string{},
+	}
+	parseSortFields(reflect.TypeOf((*T)(nil)).Elem(), repo.sortFields)
+	client.register(repo)
+	return repo
+}
+
+// Collection ...
+func (r *Repository[T]) Collection() *mongo.Collection {
+	return r.collection
+}
+
+// EnsureIndexes ...
+func (r *Repository[T]) EnsureIndexes(ctx context.Context) error {
+	var doc T
+	indexer, ok := any(&doc).(Indexer)
+	if !ok {
+		return nil
+	}
+	indexes := indexer.Indexes()
+	if len(indexes) == 0 {
+		return nil
+	}
+	names, err := r.collection.Indexes().CreateMany(ctx, indexes)
+	if err != nil {
+		return err
+	}
+	r.client.log.Infof("EnsureIndexes collection=%s indexes=%v", r.collection.Name(), names)
+	return nil
+}
+
+// FindByID ...
+func (r *Repository[T]) FindByID(ctx context.Context, id string) (*T, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrInvalidID
+	}
+	return r.FindOne(ctx, bson.M{fieldID: oid})
+}
+
+// FindOne 查询单个文档，不存在返回nil
+func (r *Repository[T]) FindOne(ctx context.Context, filter bson.M) (*T, error) {
+	doc := new(T)
+	err := r.collection.FindOne(ctx, notDeleted(filter)).Decode(doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// Find ...
+func (r *Repository[T]) Find(ctx context.Context, filter bson.M, opts ...*options.FindOptions) ([]T, error) {
+	cursor, err := r.collection.Find(ctx, notDeleted(filter), opts...)
+	if err != nil {
+		return nil, err
+	}
+	docs := []T{}
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+	return docs, nil
+}
+
+// Paginate 按页码、数量与排序查询，与mysql分页语义一致，
+// 排序字段（json名或bson名）需在文档的`query:"sort"`白名单内
+func (r *Repository[T]) Paginate(
+	ctx context.Context,
+	filter bson.M,
+	p query.Page,
+	total *int64,
+) ([]T, error) {
+	sort := bson.D{}
+	var invalid []string
+	for _, s := range p.Sorts {
+		key, ok := r.sortFields[s.Field]
+		if !ok {
+			invalid = append(invalid, "sort["+s.Field+"]")
+			continue
+		}
+		order := 1
+		if s.Desc {
+			order = -1
+		}
+		sort = append(sort, bson.E{Key: key, Value: order})
+	}
+	if len(invalid) > 0 {
+		return nil, ErrInvalidSortFields(invalid)
+	}
+
+	filter = notDeleted(filter)
+	count, err := r.collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	*total = count
+
+	opts := options.Find()
+	if p.Page > 0 && p.Limit > 0 {
+		opts.SetSkip(int64(p.Offset())).
+			SetLimit(int64(p.Limit))
+	}
+	if len(sort) > 0 {
+		opts.SetSort(sort)
+	}
+
+	return r.Find(ctx, filter, opts)
+}
+
+// Insert 插入文档，实现BeforeInsert时自动设置id与时间
+func (r *Repository[T]) Insert(ctx context.Context, doc *T) error {
+	if bi, ok := any(doc).(beforeInserter); ok {
+		bi.BeforeInsert(time.Now())
+	}
+	_, err := r.collection.InsertOne(ctx, doc)
+	return err
+}
+
+// UpdateByID 更新字段并刷新updated_at，返回是否匹配到文档
+func (r *Repository[T]) UpdateByID(ctx context.Context, id string, set bson.M) (bool, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return false, ErrInvalidID
+	}
+
+	update := bson.M{}
+	for k, v := range set {
+		update[k] = v
+	}
+	update[fieldUpdatedAt] = time.Now()
+
+	result, err := r.collection.UpdateOne(
+		ctx,
+		notDeleted(bson.M{fieldID: oid}),
+		bson.M{"$set": update},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+// SoftDelete 软删除，返回是否匹配到文档
+func (r *Repository[T]) SoftDelete(ctx context.Context, id string) (bool, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return false, ErrInvalidID
+	}
+
+	now := time.Now()
+	result, err := r.collection.UpdateOne(
+		ctx,
+		notDeleted(bson.M{fieldID: oid}),
+		bson.M{"$set": bson.M{
+			fieldDeletedAt: now,
+			fieldUpdatedAt: now,
+		}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+// parseSortFields 收集带`query:"sort"`标签的字段，json名与bson名都映射到bson键，
+// 展开bson inline的嵌入结构体
+func parseSortFields(t reflect.Type, fields map[string]string) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		key, opts, _ := strings.Cut(f.Tag.Get("bson"), ",")
+		if key == "-" {
+			continue
+		}
+		if strings.Contains(opts, "inline") {
+			parseSortFields(f.Type, fields)
+			continue
+		}
+		if !f.IsExported() || !hasSortTag(f.Tag.Get(queryTag)) {
+			continue
+		}
+		if key == "" {
+			key = strings.ToLower(f.Name)
+		}
+		fields[key] = key
+		if name, _, _ := strings.Cut(f.Tag.Get("json"), ","); name != "" && name != "-" {
+			fields[name] = key
+		}
+	}
+}
+
+func hasSortTag(tag string) bool {
+	for _, item := range strings.Split(tag, ",") {
+		if name, _, _ := strings.Cut(strings.TrimSpace(item), "="); name == "sort" {
+			return true
+		}
+	}
+	return false
+}
+
+// notDeleted 排除软删除的文档，null与字段不存在都会匹配
+func notDeleted(filter bson.M) bson.M {
+	f := bson.M{fieldDeletedAt: nil}
+	for k, v := range filter {
+		f[k] = v
+	}
+	return f
+}
@@ -0,0 +1,22 @@
+package query
+
+// SortField 排序字段
+type SortField struct {
+	Field string // 字段名（snake_case，不含反引号）
+	Desc  bool   // 是否倒序
+}
+
+// Page 分页与排序参数，Page与Limit为0时不分页
+type Page struct {
+	Page  int
+	Limit int
+	Sorts []SortField
+}
+
+// Offset 跳过的数量
+func (p Page) Offset() int {
+	if p.Page <= 0 || p.Limit <= 0 {
+		return 0
+	}
+	return (p.Page - 1) * p.Limit
+}
@@ -2,28 +2,100 @@ package rabbitmq
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
 	"time"
 
+	"lovebox/pkg/requestid"
+
+	"github.com/google/uuid"
 	"github.com/streadway/amqp"
 	"go.uber.org/atomic"
 	"go.uber.org/zap"
 )
 
+var (
+	// ErrNotConnected ...
+	ErrNotConnected = errors.New("rabbitmq not connected")
+	// ErrClosed ...
+	ErrClosed = errors.New("rabbitmq client closed")
+	// ErrNacked ...
+	ErrNacked = errors.New("rabbitmq publish nacked")
+	// ErrUnroutable ...
+	ErrUnroutable = errors.New("rabbitmq message unroutable")
+	// ErrConfirmTimeout ...
+	ErrConfirmTimeout = errors.New("rabbitmq publish confirm timeout")
+)
+
+// Handler 消费消息，返回nil时ack，返回Requeue包装的错误时重新入队，
+// 其他错误按重试策略重试或进入死信队列，未设置重试策略时nack
+type Handler func(ctx context.Context, msg amqp.Delivery) error
+
+type requeueError struct {
+	err error
+}
+
+func (e *requeueError) Error() string {
+	return e.err.Error()
+}
+
+func (e *requeueError) Unwrap() error {
+	return e.err
+}
+
+// Requeue 包装错误，消息将重新入队
+func Requeue(err error) error {
+	return &requeueError{err: err}
+}
+
+// IsRequeue ...
+func IsRequeue(err error) bool {
+	var re *requeueError
+	return errors.As(err, &re)
+}
+
+type confirmation struct {
+	messageID string
+	returned  bool
+	done      chan error
+}
+
 type Client struct {
-	log             *zap.SugaredLogger
-	rabbitmqClient  *amqp.Connection
-	rabbitmqChannel *amqp.Channel
-	rabbitmqIsConn  atomic.Bool
+	log  *zap.SugaredLogger
+	opts options
+
+	address     string
+	queue       string
+	kind        string
+	exchange    string
+	routingKey  string
+	contentType string
+	handler     Handler
 
-	address      string
-	queue        string
-	kind         string
-	exchange     string
-	routingKey   string
-	contentType  string
-	consumerFunc func(msg amqp.Delivery)
+	mu          sync.RWMutex
+	conn        *amqp.Connection
+	pubChannel  *amqp.Channel
+	consChannel *amqp.Channel
+	isConn      atomic.Bool
+
+	// 发布确认，按deliveryTag与messageId关联
+	pubMu     sync.Mutex
+	pendMu    sync.Mutex
+	nextTag   uint64
+	pending   map[uint64]*confirmation
+	pendingID map[string]*confirmation
+
+	ctx       context.Context
+	cancel    context.CancelFunc
+	workers   sync.WaitGroup
+	closeOnce sync.Once
+	closing   chan struct{}
+	done      chan struct{}
 }
 
+// New 创建客户端并在后台维持连接，handler为nil时只发布不消费
 func New(
 	address string,
 	kind string,
@@ -31,115 +103,424 @@ func New(
 	exchange string,
 	routingKey string,
 	contentType string,
-	consumerFunc func(msg amqp.Delivery),
+	handler Handler,
+	opts ...Option,
 ) *Client {
-	client := Client{
-		log:          zap.S().With("module", "pkg.rabbitmq"),
-		address:      address,
-		kind:         kind,
-		queue:        queue,
-		exchange:     exchange,
-		routingKey:   routingKey,
-		contentType:  contentType,
-		consumerFunc: consumerFunc,
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.name == "" {
+		o.name = queue
+		if o.name == "" {
+			o.name = exchange
+		}
+	}
+	if o.consumerTag == "" {
+		o.consumerTag = o.name + "-" + uuid.New().String()
+	}
+	metricsOnce.Do(registerMetrics)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	client := &Client{
+		log:         zap.S().With("module", "pkg.rabbitmq", "name", o.name),
+		opts:        o,
+		address:     address,
+		kind:        kind,
+		queue:       queue,
+		exchange:    exchange,
+		routingKey:  routingKey,
+		contentType: contentType,
+		handler:     handler,
+		pending:     map[uint64]*confirmation{},
+		pendingID:   map[string]*confirmation{},
+		ctx:         ctx,
+		cancel:      cancel,
+		closing:     make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	connectionState.WithLabelValues(o.name).Set(0)
+	go client.run()
+
+	return client
+}
+
+// IsConnected ...
+func (c *Client) IsConnected() bool {
+	return c.isConn.Load()
+}
+
+// Ping 未连接时返回ErrNotConnected
+func (c *Client) Ping(ctx context.Context) error {
+	if !c.IsConnected() {
+		return ErrNotConnected
+	}
+	return nil
+}
+
+// run 维持连接，断开后按指数退避重连
+func (c *Client) run() {
+	defer close(c.done)
+
+	backoff := c.opts.reconnectMin
+	for {
+		select {
+		case <-c.closing:
+			return
+		default:
+		}
+
+		closed, err := c.connect()
+		if err != nil {
+			c.log.Errorf("rabbitmq connect error: %+v, retry in %s", err, backoff)
+			reconnects.WithLabelValues(c.opts.name).Inc()
+
+			select {
+			case <-c.closing:
+				return
+			case <-time.After(jitter(backoff)):
+			}
+			backoff *= 2
+			if backoff > c.opts.reconnectMax {
+				backoff = c.opts.reconnectMax
+			}
+			continue
+		}
+
+		backoff = c.opts.reconnectMin
+		c.setConnected(true)
+		c.log.Infof("rabbitmq connected")
+
+		// 任一channel被broker关闭时也关闭连接重连，否则消费者会永久停止
+		var reason string
+		var closeErr *amqp.Error
+		select {
+		case closeErr = <-closed.conn:
+			reason = "connection"
+		case closeErr = <-closed.pub:
+			reason = "publish channel"
+		case closeErr = <-closed.cons:
+			reason = "consume channel"
+		case <-c.closing:
+			return
+		}
+		c.setConnected(false)
+		c.failPending(ErrNotConnected)
+		c.log.Errorf("rabbitmq %s closed: %v", reason, closeErr)
+		reconnects.WithLabelValues(c.opts.name).Inc()
+		c.closeConn()
 	}
-	client.rabbitmqIsConn.Store(false)
-	go client.Connect()
+}
 
-	return &client
+// closeNotify 连接与channel的关闭通知，未消费时cons为nil
+type closeNotify struct {
+	conn chan *amqp.Error
+	pub  chan *amqp.Error
+	cons chan *amqp.Error
+}
+
+func (c *Client) closeConn() {
+	c.mu.RLock()
+	conn := c.conn
+	c.mu.RUnlock()
+	if conn != nil && !conn.IsClosed() {
+		_ = conn.Close()
+	}
 }
 
-func (c *Client) Connect() {
+func (c *Client) connect() (*closeNotify, error) {
+	conn, err := amqp.Dial(c.address)
+	if err != nil {
+		return nil, err
+	}
+
+	ok := false
 	defer func() {
-		if err := recover(); err != nil {
-			c.log.Errorf("consume err: %+v", err)
-			time.Sleep(3 * time.Second)
-			go c.Connect()
-			// for !c.rabbitmqIsConn.Load() {
-			// 	c.log.Infof("rabbitmq reconnect")
-			// 	time.Sleep(3 * time.Second)
-			// 	c.Connect()
-			// }
+		if !ok {
+			conn.Close()
 		}
 	}()
 
-	var err error
-	c.rabbitmqClient, err = amqp.Dial(c.address)
-	if err != nil {
-		c.log.Errorf("rabbitmq connect error: %+v", err)
-		return
+	closed := &closeNotify{
+		conn: conn.NotifyClose(make(chan *amqp.Error, 1)),
 	}
 
-	c.rabbitmqChannel, err = c.rabbitmqClient.Channel()
+	pubChannel, err := conn.Channel()
 	if err != nil {
-		c.log.Errorf("rabbitmq open channel error: %+v", err)
-		return
+		return nil, err
+	}
+	closed.pub = pubChannel.NotifyClose(make(chan *amqp.Error, 1))
+	if err := pubChannel.Confirm(false); err != nil {
+		return nil, err
 	}
+	confirms := pubChannel.NotifyPublish(make(chan amqp.Confirmation, c.opts.prefetch))
+	returns := pubChannel.NotifyReturn(make(chan amqp.Return, c.opts.prefetch))
 
-	err = c.rabbitmqChannel.Qos(200, 0, false)
-	if err != nil {
-		c.log.Errorf("rabbitmq Qos error: %+v", err)
-		return
+	if err := c.declare(pubChannel); err != nil {
+		return nil, err
 	}
 
-	c.log.Infof("rabbitmq ExchangeDeclare exchange=%s kind=%s", c.exchange, c.kind)
-	err = c.rabbitmqChannel.ExchangeDeclare(c.exchange, c.kind, true, false, false, false, nil)
-	if err != nil {
-		c.log.Errorf("rabbitmq ExchangeDeclare error: %+v", err)
-		return
+	c.pubMu.Lock()
+	c.mu.Lock()
+	c.conn = conn
+	c.pubChannel = pubChannel
+	c.consChannel = nil
+	c.nextTag = 0
+	c.mu.Unlock()
+	c.pubMu.Unlock()
+
+	go c.handleConfirms(confirms, returns)
+
+	if c.handler != nil && c.queue != "" {
+		closed.cons, err = c.consume(conn)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ok = true
+	return closed, nil
+}
+
+func (c *Client) declare(ch *amqp.Channel) error {
+	if c.exchange != "" {
+		c.log.Infof("rabbitmq ExchangeDeclare exchange=%s kind=%s", c.exchange, c.kind)
+		err := ch.ExchangeDeclare(c.exchange, c.kind, true, false, false, false, nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	if c.queue == "" {
+		return nil
+	}
+
+	if c.opts.retry != nil {
+		if err := c.declareRetry(ch); err != nil {
+			return err
+		}
 	}
 
 	c.log.Infof("rabbitmq QueueDeclare queue=%s", c.queue)
-	q, err := c.rabbitmqChannel.QueueDeclare(c.queue, true, false, false, false, nil)
+	q, err := ch.QueueDeclare(c.queue, true, false, false, false, c.opts.queueArgs)
 	if err != nil {
-		c.log.Errorf("rabbitmq QueueDeclare error: %+v", err)
-		return
+		return err
+	}
+
+	if c.exchange != "" {
+		c.log.Infof("rabbitmq QueueBind name=%s routingKey=%s exchange=%s", q.Name, c.routingKey, c.exchange)
+		err = ch.QueueBind(q.Name, c.routingKey, c.exchange, false, nil)
+		if err != nil {
+			return err
+		}
 	}
 
-	c.log.Infof("rabbitmq QueueBind name=%s routingKey=%s exchange=%s", q.Name, c.routingKey, c.exchange)
-	err = c.rabbitmqChannel.QueueBind(q.Name, c.routingKey, c.exchange, false, nil)
+	return nil
+}
+
+func (c *Client) consume(conn *amqp.Connection) (chan *amqp.Error, error) {
+	select {
+	case <-c.closing:
+		return nil, ErrClosed
+	default:
+	}
+
+	ch, err := conn.Channel()
 	if err != nil {
-		c.log.Errorf("rabbitmq QueueBind error: %+v", err)
-		return
+		return nil, err
 	}
+	closed := ch.NotifyClose(make(chan *amqp.Error, 1))
+	if err := ch.Qos(c.opts.prefetch, 0, false); err != nil {
+		return nil, err
+	}
+	deliveries, err := ch.Consume(c.queue, c.opts.consumerTag, false, false, false, false, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.consChannel = ch
+	c.mu.Unlock()
+
+	c.workers.Add(c.opts.workers)
+	for i := 0; i < c.opts.workers; i++ {
+		go c.worker(deliveries)
+	}
+	return closed, nil
+}
+
+func (c *Client) worker(deliveries <-chan amqp.Delivery) {
+	defer c.workers.Done()
+	for msg := range deliveries {
+		c.handle(msg)
+	}
+}
+
+func (c *Client) handle(msg amqp.Delivery) {
+	err := c.process(msg)
 
-	c.rabbitmqIsConn.Store(true)
+	result := "ack"
+	switch {
+	case err == nil:
+		if err := msg.Ack(false); err != nil {
+			c.log.Errorf("rabbitmq ack error: %v", err)
+		}
+	case isAcquireError(err):
+		result = c.retryLater(msg, err)
+	case IsRequeue(err):
+		c.log.Warnf("rabbitmq consume requeue messageId=%s err=%v", msg.MessageId, err)
+		if err := msg.Nack(false, true); err != nil {
+			c.log.Errorf("rabbitmq nack error: %v", err)
+		}
+		result = "requeue"
+	case c.opts.retry != nil:
+		result = c.retryOrDeadLetter(msg, err)
+	default:
+		c.log.Errorf("rabbitmq consume messageId=%s err=%v", msg.MessageId, err)
+		if err := msg.Nack(false, false); err != nil {
+			c.log.Errorf("rabbitmq nack error: %v", err)
+		}
+		result = "nack"
+	}
+	consumed.WithLabelValues(c.opts.name, result).Inc()
+}
 
-	var msgs <-chan amqp.Delivery
-	if c.consumerFunc != nil {
-		msgs, err = c.rabbitmqChannel.Consume(c.queue, "", false, false, false, false, nil)
+// process 执行handler，panic视为不可重试的错误
+func (c *Client) process(msg amqp.Delivery) (err error) {
+	key := ""
+	if c.opts.idempotency != nil {
+		key = idempotencyKey(&msg)
+	}
+	if key != "" {
+		acquired, err := c.opts.idempotency.Acquire(c.ctx, key)
 		if err != nil {
-			c.log.Errorf("rabbitmq Consume error: %s", err)
+			return &acquireError{err: err}
 		}
-	} else {
-		msgs = make(<-chan amqp.Delivery)
+		if !acquired {
+			c.log.Infof("rabbitmq skip duplicate messageId=%s key=%s", msg.MessageId, key)
+			return nil
+		}
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			c.log.Errorf("rabbitmq consume panic: %+v", r)
+			err = Permanent(fmt.Errorf("panic: %v", r))
+		}
+
+		if key == "" {
+			return
+		}
+		if err == nil {
+			if e := c.opts.idempotency.Done(c.ctx, key); e != nil {
+				c.log.Errorf("rabbitmq idempotency done key=%s err=%v", key, e)
+			}
+		} else {
+			if e := c.opts.idempotency.Release(c.ctx, key); e != nil {
+				c.log.Errorf("rabbitmq idempotency release key=%s err=%v", key, e)
+			}
+		}
+	}()
+
+	ctx := c.ctx
+	if id, ok := msg.Headers[requestid.AMQPHeader].(string); ok {
+		ctx = requestid.NewContext(ctx, id)
+	}
+	return c.handler(ctx, msg)
+}
+
+func (c *Client) handleConfirms(confirms <-chan amqp.Confirmation, returns <-chan amqp.Return) {
+	markReturned := func(ret amqp.Return) {
+		c.log.Warnf("rabbitmq message returned messageId=%s exchange=%s routingKey=%s reply=%s",
+			ret.MessageId, ret.Exchange, ret.RoutingKey, ret.ReplyText)
+		returned.WithLabelValues(c.opts.name).Inc()
+
+		c.pendMu.Lock()
+		if conf, ok := c.pendingID[ret.MessageId]; ok {
+			conf.returned = true
+		}
+		c.pendMu.Unlock()
 	}
 
-	closeChan := make(chan *amqp.Error, 1)
-	notifyClose := c.rabbitmqChannel.NotifyClose(closeChan)
-	closeFlag := false
 	for {
 		select {
-		case <-notifyClose:
-			c.rabbitmqIsConn.Store(false)
-			c.log.Errorf("rabbitmq notifyClose")
-			close(closeChan)
-			closeFlag = true
-			c.Connect()
-		case msg := <-msgs:
-			c.consumerFunc(msg)
-		}
-		if closeFlag {
-			break
+		case ret, ok := <-returns:
+			if !ok {
+				returns = nil
+				continue
+			}
+			markReturned(ret)
+		case confirm, ok := <-confirms:
+			if !ok {
+				return
+			}
+			// basic.return先于basic.ack发送，先处理已到达的return
+		drain:
+			for {
+				select {
+				case ret, ok := <-returns:
+					if !ok {
+						returns = nil
+						break drain
+					}
+					markReturned(ret)
+				default:
+					break drain
+				}
+			}
+			c.resolve(confirm)
 		}
 	}
 }
 
-func (c *Client) Produce(body []byte) error {
-	return c.rabbitmqChannel.Publish(c.exchange, c.routingKey, false, false, amqp.Publishing{
-		DeliveryMode: amqp.Persistent,
-		ContentType:  c.contentType,
-		Body:         body,
+func (c *Client) resolve(confirm amqp.Confirmation) {
+	c.pendMu.Lock()
+	conf, ok := c.pending[confirm.DeliveryTag]
+	if ok {
+		delete(c.pending, confirm.DeliveryTag)
+		delete(c.pendingID, conf.messageID)
+	}
+	c.pendMu.Unlock()
+	if !ok {
+		return
+	}
+
+	switch {
+	case !confirm.Ack:
+		conf.done <- ErrNacked
+	case conf.returned:
+		conf.done <- ErrUnroutable
+	default:
+		conf.done <- nil
+	}
+}
+
+func (c *Client) failPending(err error) {
+	c.pendMu.Lock()
+	defer c.pendMu.Unlock()
+	for tag, conf := range c.pending {
+		conf.done <- err
+		delete(c.pending, tag)
+	}
+	c.pendingID = map[string]*confirmation{}
+}
+
+func (c *Client) setConnected(connected bool) {
+	c.isConn.Store(connected)
+	if connected {
+		connectionState.WithLabelValues(c.opts.name).Set(1)
+	} else {
+		connectionState.WithLabelValues(c.opts.name).Set(0)
+	}
+}
+
+// Produce 发布到默认exchange与routingKey，等待broker确认
+func (c *Client) Produce(ctx context.Context, body []byte) error {
+	return c.ProduceWithParam(ctx, &ProduceParam{
+		Exchange:    c.exchange,
+		RoutingKey:  c.routingKey,
+		Body:        body,
+		ContentType: c.contentType,
 	})
 }
 
@@ -148,24 +529,150 @@ type ProduceParam struct {
 	RoutingKey  string
 	Body        []byte
 	ContentType string
+	MessageID   string     // 为空时自动生成
+	Headers     amqp.Table // 可选
 }
 
-func (c *Client) ProduceWithParam(param *ProduceParam) error {
-	return c.rabbitmqChannel.Publish(param.Exchange, param.RoutingKey, false, false, amqp.Publishing{
+// ProduceWithParam 发布消息并等待broker确认，无法路由的消息返回ErrUnroutable
+func (c *Client) ProduceWithParam(ctx context.Context, param *ProduceParam) error {
+	err := c.publish(ctx, param)
+	result := "ack"
+	switch {
+	case err == nil:
+	case errors.Is(err, ErrNacked):
+		result = "nack"
+	case errors.Is(err, ErrUnroutable):
+		result = "returned"
+	default:
+		result = "error"
+	}
+	published.WithLabelValues(c.opts.name, param.Exchange, result).Inc()
+	return err
+}
+
+func (c *Client) publish(ctx context.Context, param *ProduceParam) error {
+	select {
+	case <-c.closing:
+		return ErrClosed
+	default:
+	}
+
+	messageID := param.MessageID
+	if messageID == "" {
+		messageID = uuid.New().String()
+	}
+	headers := param.Headers
+	if id := requestid.FromContext(ctx); id != "" {
+		if _, ok := headers[requestid.AMQPHeader]; !ok {
+			headers = make(amqp.Table, len(param.Headers)+1)
+			for k, v := range param.Headers {
+				headers[k] = v
+			}
+			headers[requestid.AMQPHeader] = id
+		}
+	}
+	conf := &confirmation{
+		messageID: messageID,
+		done:      make(chan error, 1),
+	}
+
+	c.pubMu.Lock()
+	c.mu.RLock()
+	ch := c.pubChannel
+	c.mu.RUnlock()
+	if ch == nil || !c.IsConnected() {
+		c.pubMu.Unlock()
+		return ErrNotConnected
+	}
+
+	c.nextTag++
+	tag := c.nextTag
+	c.pendMu.Lock()
+	c.pending[tag] = conf
+	c.pendingID[messageID] = conf
+	c.pendMu.Unlock()
+
+	err := ch.Publish(param.Exchange, param.RoutingKey, true, false, amqp.Publishing{
 		DeliveryMode: amqp.Persistent,
 		ContentType:  param.ContentType,
+		MessageId:    messageID,
+		Timestamp:    time.Now(),
+		Headers:      headers,
 		Body:         param.Body,
 	})
-}
+	if err != nil {
+		c.pendMu.Lock()
+		delete(c.pending, tag)
+		delete(c.pendingID, messageID)
+		c.pendMu.Unlock()
+		c.pubMu.Unlock()
+		return err
+	}
+	c.pubMu.Unlock()
 
-type Params struct {
-	Kind        string
-	Queue       string
-	Exchange    string
-	RoutingKey  string
-	ContentType string
+	timer := time.NewTimer(c.opts.confirmTimeout)
+	defer timer.Stop()
+
+	select {
+	case err := <-conf.done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return ErrConfirmTimeout
+	}
 }
 
+// Shutdown 停止消费，等待处理中的消息完成后关闭连接
 func (c *Client) Shutdown(ctx context.Context) error {
-	return nil
+	c.closeOnce.Do(func() {
+		close(c.closing)
+	})
+
+	select {
+	case <-c.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	c.mu.RLock()
+	consChannel := c.consChannel
+	c.mu.RUnlock()
+	if consChannel != nil {
+		if err := consChannel.Cancel(c.opts.consumerTag, false); err != nil {
+			c.log.Warnf("rabbitmq cancel consumer error: %v", err)
+		}
+	}
+
+	drained := make(chan struct{})
+	go func() {
+		c.workers.Wait()
+		close(drained)
+	}()
+
+	var err error
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	c.cancel()
+
+	c.mu.Lock()
+	if c.conn != nil {
+		_ = c.conn.Close()
+		c.conn = nil
+	}
+	c.mu.Unlock()
+	c.setConnected(false)
+	c.failPending(ErrClosed)
+
+	return err
+}
+
+func jitter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return d
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
 }
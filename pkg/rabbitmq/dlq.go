@@ -0,0 +1,159 @@
+package rabbitmq
+
+import (
+	"context"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+// DeadLetter 死信消息
+type DeadLetter struct {
+	MessageID          string     `json:"messageId"`
+	Attempts           int        `json:"attempts"`
+	FailureReason      string     `json:"failureReason"`
+	FailedAt           string     `json:"failedAt"`
+	OriginalExchange   string     `json:"originalExchange"`
+	OriginalRoutingKey string     `json:"originalRoutingKey"`
+	ContentType        string     `json:"contentType"`
+	Timestamp          time.Time  `json:"timestamp"`
+	Headers            amqp.Table `json:"headers"`
+	Body               []byte     `json:"body"`
+}
+
+func newDeadLetter(msg *amqp.Delivery) DeadLetter {
+	dl := DeadLetter{
+		MessageID:   msg.MessageId,
+		Attempts:    headerInt(msg.Headers, HeaderAttempts),
+		ContentType: msg.ContentType,
+		Timestamp:   msg.Timestamp,
+		Headers:     msg.Headers,
+		Body:        msg.Body,
+	}
+	dl.FailureReason, _ = msg.Headers[HeaderFailureReason].(string)
+	dl.FailedAt, _ = msg.Headers[HeaderFailedAt].(string)
+	dl.OriginalExchange, _ = msg.Headers[HeaderOriginalExchange].(string)
+	dl.OriginalRoutingKey, _ = msg.Headers[HeaderOriginalRoutingKey].(string)
+	return dl
+}
+
+// InspectDeadLetters 查看死信队列中的前limit条消息，消息保留在队列中
+func InspectDeadLetters(ctx context.Context, address, queue string, limit int) ([]DeadLetter, error) {
+	conn, err := amqp.Dial(address)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	ch, err := conn.Channel()
+	if err != nil {
+		return nil, err
+	}
+	defer ch.Close()
+
+	letters := []DeadLetter{}
+	var lastTag uint64
+	for len(letters) < limit {
+		if ctx.Err() != nil {
+			break
+		}
+		msg, ok, err := ch.Get(DeadLetterQueue(queue), false)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+		lastTag = msg.DeliveryTag
+		letters = append(letters, newDeadLetter(&msg))
+	}
+
+	// 全部放回队列
+	if lastTag > 0 {
+		if err := ch.Nack(lastTag, true, true); err != nil {
+			return nil, err
+		}
+	}
+	return letters, nil
+}
+
+// ReplayDeadLetters 将死信队列中的前limit条消息经默认exchange直接发布回queue，
+// 避免原exchange上绑定的其他队列重复收到，重置处理次数，返回重放数量
+func ReplayDeadLetters(ctx context.Context, address, queue string, limit int) (int, error) {
+	conn, err := amqp.Dial(address)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	ch, err := conn.Channel()
+	if err != nil {
+		return 0, err
+	}
+	defer ch.Close()
+
+	if err := ch.Confirm(false); err != nil {
+		return 0, err
+	}
+	confirms := ch.NotifyPublish(make(chan amqp.Confirmation, 1))
+	returns := ch.NotifyReturn(make(chan amqp.Return, 1))
+
+	replayed := 0
+	for replayed < limit {
+		if ctx.Err() != nil {
+			return replayed, ctx.Err()
+		}
+		msg, ok, err := ch.Get(DeadLetterQueue(queue), false)
+		if err != nil {
+			return replayed, err
+		}
+		if !ok {
+			break
+		}
+
+		headers := amqp.Table{}
+		for k, v := range msg.Headers {
+			headers[k] = v
+		}
+		delete(headers, HeaderAttempts)
+		delete(headers, HeaderFailureReason)
+		delete(headers, HeaderFailedAt)
+
+		err = ch.Publish("", queue, true, false, amqp.Publishing{
+			DeliveryMode: amqp.Persistent,
+			ContentType:  msg.ContentType,
+			MessageId:    msg.MessageId,
+			Timestamp:    msg.Timestamp,
+			Headers:      headers,
+			Body:         msg.Body,
+		})
+		if err != nil {
+			_ = msg.Nack(false, true)
+			return replayed, err
+		}
+
+		select {
+		case confirm := <-confirms:
+			if !confirm.Ack {
+				_ = msg.Nack(false, true)
+				return replayed, ErrNacked
+			}
+			// basic.return先于basic.ack到达
+			select {
+			case <-returns:
+				_ = msg.Nack(false, true)
+				return replayed, ErrUnroutable
+			default:
+			}
+		case <-ctx.Done():
+			_ = msg.Nack(false, true)
+			return replayed, ctx.Err()
+		}
+
+		if err := msg.Ack(false); err != nil {
+			return replayed, err
+		}
+		replayed++
+	}
+	return replayed, nil
+}
@@ -0,0 +1,159 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/streadway/amqp"
+)
+
+const (
+	// HeaderIdempotencyKey 幂等键，为空时使用MessageId
+	HeaderIdempotencyKey = "x-idempotency-key"
+
+	idempotencyProcessing = "processing"
+	idempotencyDone       = "done"
+)
+
+var (
+	// ErrInProgress 相同幂等键的消息正在被处理
+	ErrInProgress = errors.New("message with same idempotency key in progress")
+)
+
+// IdempotencyStore 记录已处理的消息，防止重复消费
+type IdempotencyStore interface {
+	// Acquire 占用幂等键，返回false表示已处理完成
+	Acquire(ctx context.Context, key string) (bool, error)
+	// Done 标记处理完成
+	Done(ctx context.Context, key string) error
+	// Release 处理失败时释放，允许重试
+	Release(ctx context.Context, key string) error
+}
+
+type redisIdempotencyStore struct {
+	rdb       *redis.Client
+	prefix    string
+	lockTTL   time.Duration
+	resultTTL time.Duration
+}
+
+// NewRedisIdempotencyStore lockTTL为处理中状态的过期时间，resultTTL为已完成状态的保留时间
+func NewRedisIdempotencyStore(
+	rdb *redis.Client,
+	prefix string,
+	lockTTL time.Duration,
+	resultTTL time.Duration,
+) IdempotencyStore {
+	return &redisIdempotencyStore{
+		rdb:       rdb,
+		prefix:    prefix,
+		lockTTL:   lockTTL,
+		resultTTL: resultTTL,
+	}
+}
+
+func (s *redisIdempotencyStore) Acquire(ctx context.Context, key string) (bool, error) {
+	ok, err := s.rdb.SetNX(ctx, s.prefix+key, idempotencyProcessing, s.lockTTL).Result()
+	if err != nil {
+		return false, err
+	}
+	if ok {
+		return true, nil
+	}
+
+	state, err := s.rdb.Get(ctx, s.prefix+key).Result()
+	if errors.Is(err, redis.Nil) {
+		// 刚好过期，重新占用
+		return s.Acquire(ctx, key)
+	}
+	if err != nil {
+		return false, err
+	}
+	if state == idempotencyDone {
+		return false, nil
+	}
+	return false, ErrInProgress
+}
+
+func (s *redisIdempotencyStore) Done(ctx context.Context, key string) error {
+	return s.rdb.Set(ctx, s.prefix+key, idempotencyDone, s.resultTTL).Err()
+}
+
+func (s *redisIdempotencyStore) Release(ctx context.Context, key string) error {
+	return s.rdb.Del(ctx, s.prefix+key).Err()
+}
+
+// acquireError 占用幂等键失败（相同消息处理中或redis错误），
+// 立即重新入队会被马上再次投递，需要延迟后重试
+type acquireError struct {
+	err error
+}
+
+func (e *acquireError) Error() string {
+	return e.err.Error()
+}
+
+func (e *acquireError) Unwrap() error {
+	return e.err
+}
+
+func isAcquireError(err error) bool {
+	var ae *acquireError
+	return errors.As(err, &ae)
+}
+
+// retryLater 不计入处理次数：设置了重试策略时发布到第一级延迟队列，
+// 否则（或发布失败时）等待reconnectMin后重新入队
+func (c *Client) retryLater(msg amqp.Delivery, cause error) string {
+	if c.opts.retry != nil && len(c.opts.retry.delays()) > 0 {
+		headers := amqp.Table{}
+		for k, v := range msg.Headers {
+			headers[k] = v
+		}
+		if _, ok := headers[HeaderOriginalExchange]; !ok {
+			headers[HeaderOriginalExchange] = msg.Exchange
+			headers[HeaderOriginalRoutingKey] = msg.RoutingKey
+		}
+
+		routingKey := retryQueue(c.queue, 0)
+		ctx, cancel := context.WithTimeout(context.Background(), c.opts.confirmTimeout)
+		defer cancel()
+		err := c.ProduceWithParam(ctx, &ProduceParam{
+			Exchange:    "",
+			RoutingKey:  routingKey,
+			Body:        msg.Body,
+			ContentType: msg.ContentType,
+			MessageID:   msg.MessageId,
+			Headers:     headers,
+		})
+		if err == nil {
+			c.log.Warnf("rabbitmq consume retry later messageId=%s err=%v", msg.MessageId, cause)
+			if err := msg.Ack(false); err != nil {
+				c.log.Errorf("rabbitmq ack error: %v", err)
+			}
+			return "retry"
+		}
+		c.log.Errorf("rabbitmq republish to %s messageId=%s err=%v", routingKey, msg.MessageId, err)
+	}
+
+	c.log.Warnf("rabbitmq consume requeue later messageId=%s err=%v", msg.MessageId, cause)
+	timer := time.NewTimer(c.opts.reconnectMin)
+	select {
+	case <-timer.C:
+	case <-c.ctx.Done():
+		timer.Stop()
+	}
+	if err := msg.Nack(false, true); err != nil {
+		c.log.Errorf("rabbitmq nack error: %v", err)
+	}
+	return "requeue"
+}
+
+func idempotencyKey(msg *amqp.Delivery) string {
+	if key, ok := msg.Headers[HeaderIdempotencyKey].(string); ok && key != "" {
+		return key
+	}
+	return msg.MessageId
+}
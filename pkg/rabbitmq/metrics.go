@@ -0,0 +1,64 @@
+package rabbitmq
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	metricsOnce sync.Once
+
+	connectionState *prometheus.GaugeVec
+	reconnects      *prometheus.CounterVec
+	published       *prometheus.CounterVec
+	returned        *prometheus.CounterVec
+	consumed        *prometheus.CounterVec
+)
+
+func registerMetrics() {
+	connectionState = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "rabbitmq_connection_state",
+			Help: "Whether the rabbitmq client is connected (1) or not (0).",
+		},
+		[]string{"name"},
+	)
+	prometheus.MustRegister(connectionState)
+
+	reconnects = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rabbitmq_reconnects_total",
+			Help: "How many times the rabbitmq client lost or failed to open its connection.",
+		},
+		[]string{"name"},
+	)
+	prometheus.MustRegister(reconnects)
+
+	published = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rabbitmq_published_total",
+			Help: "How many messages published, partitioned by exchange and confirm result.",
+		},
+		[]string{"name", "exchange", "result"},
+	)
+	prometheus.MustRegister(published)
+
+	returned = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rabbitmq_returned_total",
+			Help: "How many published messages were returned as unroutable.",
+		},
+		[]string{"name"},
+	)
+	prometheus.MustRegister(returned)
+
+	consumed = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rabbitmq_consumed_total",
+			Help: "How many messages consumed, partitioned by result (ack, nack, requeue, panic).",
+		},
+		[]string{"name", "result"},
+	)
+	prometheus.MustRegister(consumed)
+}
@@ -0,0 +1,112 @@
+package rabbitmq
+
+import (
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+type options struct {
+	name           string
+	workers        int
+	prefetch       int
+	consumerTag    string
+	reconnectMin   time.Duration
+	reconnectMax   time.Duration
+	confirmTimeout time.Duration
+	queueArgs      amqp.Table
+	retry          *RetryPolicy
+	idempotency    IdempotencyStore
+}
+
+func defaultOptions() options {
+	return options{
+		workers:        1,
+		prefetch:       200,
+		reconnectMin:   time.Second,
+		reconnectMax:   time.Minute,
+		confirmTimeout: 10 * time.Second,
+	}
+}
+
+// Option controls the behavior of the Client.
+type Option func(*options)
+
+// WithName 设置日志与指标中的名称，默认为队列名
+func WithName(name string) Option {
+	return func(o *options) {
+		o.name = name
+	}
+}
+
+// WithWorkers 设置并发消费的worker数量
+func WithWorkers(workers int) Option {
+	return func(o *options) {
+		if workers > 0 {
+			o.workers = workers
+		}
+	}
+}
+
+// WithPrefetch 设置消费者预取数量
+func WithPrefetch(prefetch int) Option {
+	return func(o *options) {
+		if prefetch > 0 {
+			o.prefetch = prefetch
+		}
+	}
+}
+
+// WithConsumerTag 设置消费者标识，默认为名称加随机后缀
+func WithConsumerTag(tag string) Option {
+	return func(o *options) {
+		o.consumerTag = tag
+	}
+}
+
+// WithReconnectBackoff 设置重连退避的最小与最大间隔
+func WithReconnectBackoff(min, max time.Duration) Option {
+	return func(o *options) {
+		if min > 0 {
+			o.reconnectMin = min
+		}
+		if max >= o.reconnectMin {
+			o.reconnectMax = max
+		}
+	}
+}
+
+// WithConfirmTimeout 设置等待发布确认的超时时间
+func WithConfirmTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		if timeout > 0 {
+			o.confirmTimeout = timeout
+		}
+	}
+}
+
+// WithQueueArgs 设置声明队列时的参数
+func WithQueueArgs(args amqp.Table) Option {
+	return func(o *options) {
+		o.queueArgs = args
+	}
+}
+
+// WithRetryPolicy 设置消费失败的重试策略并声明延迟队列与死信队列。
+// 不修改主队列的参数，已有队列可直接开启；如需broker拒绝的消息也进入死信队列，
+// 通过policy为主队列配置dead-letter-exchange=""与dead-letter-routing-key=<queue>.dlq，
+// 之前以x-dead-letter-*参数声明过主队列的环境需通过WithQueueArgs保留这两个参数或删除队列后重建
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(o *options) {
+		if policy.MaxAttempts > 0 {
+			o.retry = &policy
+		}
+	}
+}
+
+// WithIdempotency 使用幂等键去重，已处理完成的消息直接ack
+func WithIdempotency(store IdempotencyStore) Option {
+	return func(o *options) {
+		o.idempotency = store
+	}
+}
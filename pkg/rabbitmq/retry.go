@@ -0,0 +1,177 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+const (
+	HeaderAttempts           = "x-attempts"
+	HeaderFailureReason      = "x-failure-reason"
+	HeaderFailedAt           = "x-failed-at"
+	HeaderOriginalExchange   = "x-original-exchange"
+	HeaderOriginalRoutingKey = "x-original-routing-key"
+)
+
+// RetryPolicy 消费失败的重试策略，第n次重试延迟 InitialDelay*Multiplier^(n-1)，
+// 通过带TTL的延迟队列实现，过期后由DLX投递回原队列，超过MaxAttempts进入死信队列
+type RetryPolicy struct {
+	MaxAttempts  int           // 最多处理次数（含首次）
+	InitialDelay time.Duration // 首次重试延迟
+	Multiplier   float64       // 延迟倍数，默认2
+	MaxDelay     time.Duration // 最大延迟，0表示不限制
+}
+
+func (p *RetryPolicy) delays() []time.Duration {
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 2
+	}
+	delays := []time.Duration{}
+	for i := 0; i < p.MaxAttempts-1; i++ {
+		d := time.Duration(float64(p.InitialDelay) * math.Pow(multiplier, float64(i)))
+		if p.MaxDelay > 0 && d > p.MaxDelay {
+			d = p.MaxDelay
+		}
+		delays = append(delays, d)
+	}
+	return delays
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent 包装错误，消息不再重试直接进入死信队列
+func Permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+// IsPermanent ...
+func IsPermanent(err error) bool {
+	var pe *permanentError
+	return errors.As(err, &pe)
+}
+
+// DeadLetterQueue 死信队列名
+func DeadLetterQueue(queue string) string {
+	return queue + ".dlq"
+}
+
+func retryQueue(queue string, level int) string {
+	return fmt.Sprintf("%s.retry.%d", queue, level+1)
+}
+
+// declareRetry 声明死信队列与各级延迟队列。
+// 主队列的参数保持不变（重新声明参数不同的已有队列会返回PRECONDITION_FAILED），
+// 消息由retryOrDeadLetter显式发布到延迟队列或死信队列
+func (c *Client) declareRetry(ch *amqp.Channel) error {
+	dlq := DeadLetterQueue(c.queue)
+	c.log.Infof("rabbitmq QueueDeclare queue=%s", dlq)
+	if _, err := ch.QueueDeclare(dlq, true, false, false, false, nil); err != nil {
+		return err
+	}
+
+	for level, delay := range c.opts.retry.delays() {
+		name := retryQueue(c.queue, level)
+		c.log.Infof("rabbitmq QueueDeclare queue=%s delay=%s", name, delay)
+		_, err := ch.QueueDeclare(name, true, false, false, false, amqp.Table{
+			"x-message-ttl":             int64(delay / time.Millisecond),
+			"x-dead-letter-exchange":    "",
+			"x-dead-letter-routing-key": c.queue,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// retryOrDeadLetter 按策略重新发布到延迟队列或死信队列，成功后ack原消息
+func (c *Client) retryOrDeadLetter(msg amqp.Delivery, cause error) string {
+	attempts := headerInt(msg.Headers, HeaderAttempts) + 1
+
+	headers := amqp.Table{}
+	for k, v := range msg.Headers {
+		headers[k] = v
+	}
+	headers[HeaderAttempts] = int32(attempts)
+	headers[HeaderFailureReason] = cause.Error()
+	if _, ok := headers[HeaderOriginalExchange]; !ok {
+		headers[HeaderOriginalExchange] = msg.Exchange
+		headers[HeaderOriginalRoutingKey] = msg.RoutingKey
+	}
+
+	result := "retry"
+	routingKey := ""
+	delays := c.opts.retry.delays()
+	if IsPermanent(cause) || attempts >= c.opts.retry.MaxAttempts || len(delays) == 0 {
+		result = "dead_letter"
+		routingKey = DeadLetterQueue(c.queue)
+		headers[HeaderFailedAt] = time.Now().Format(time.RFC3339)
+	} else {
+		level := attempts - 1
+		if level >= len(delays) {
+			level = len(delays) - 1
+		}
+		routingKey = retryQueue(c.queue, level)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.opts.confirmTimeout)
+	defer cancel()
+	err := c.ProduceWithParam(ctx, &ProduceParam{
+		Exchange:    "",
+		RoutingKey:  routingKey,
+		Body:        msg.Body,
+		ContentType: msg.ContentType,
+		MessageID:   msg.MessageId,
+		Headers:     headers,
+	})
+	if err != nil {
+		// 无法重新发布时重新入队，避免丢失
+		c.log.Errorf("rabbitmq republish to %s messageId=%s err=%v", routingKey, msg.MessageId, err)
+		_ = msg.Nack(false, true)
+		return "requeue"
+	}
+
+	c.log.Warnf("rabbitmq consume %s messageId=%s attempts=%d err=%v", result, msg.MessageId, attempts, cause)
+	if err := msg.Ack(false); err != nil {
+		c.log.Errorf("rabbitmq ack error: %v", err)
+	}
+	return result
+}
+
+func headerInt(headers amqp.Table, key string) int {
+	switch v := headers[key].(type) {
+	case int:
+		return v
+	case int8:
+		return int(v)
+	case int16:
+		return int(v)
+	case int32:
+		return int(v)
+	case int64:
+		return int(v)
+	case uint8:
+		return int(v)
+	case uint16:
+		return int(v)
+	case uint32:
+		return int(v)
+	}
+	return 0
+}
@@ -0,0 +1,57 @@
+package requestid
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// Header http请求与响应头
+	Header = "X-Request-ID"
+	// AMQPHeader rabbitmq消息头
+	AMQPHeader = "x-request-id"
+
+	maxLength = 128
+)
+
+type contextKey struct{}
+
+// New 生成新的request id
+func New() string {
+	return uuid.New().String()
+}
+
+// NewContext 将request id放入context，id为空时原样返回
+func NewContext(ctx context.Context, id string) context.Context {
+	if id == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext 读取context中的request id，不存在时返回空字符串
+func FromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
+
+// Valid 校验客户端传入的request id，只允许有限长度的可打印字符，防止日志注入
+func Valid(id string) bool {
+	if id == "" || len(id) > maxLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		ch := id[i]
+		switch {
+		case ch >= 'a' && ch <= 'z', ch >= 'A' && ch <= 'Z', ch >= '0' && ch <= '9':
+		case ch == '-', ch == '_', ch == '.', ch == ':', ch == '/', ch == '+', ch == '=':
+		default:
+			return false
+		}
+	}
+	return true
+}
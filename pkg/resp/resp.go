@@ -16,15 +16,30 @@ const (
 	ACCOUNT_EXISTS      = "账号已存在"
 	ACCOUNT_NOT_EXISTS  = "账号不存在"
 	ACCOUNT_HAS_CHINESE = "用户名不能包含中文"
+	STATS_DISABLED      = "统计服务未启用"
+	WEBHOOK_NOT_FOUND   = "webhook不存在"
+
+	IDEMPOTENCY_KEY_INVALID = "Idempotency-Key不合法"
+	IDEMPOTENCY_KEY_REUSED  = "Idempotency-Key已用于其他请求"
+	IDEMPOTENCY_IN_PROGRESS = "相同Idempotency-Key的请求正在处理中"
 )
 
 type Response struct {
 	Code    int         `json:"code"`
 	Result  interface{} `json:"result"`
 	Message string      `json:"message"`
+	// RequestID 仅在错误响应中返回，便于客户端反馈问题时定位日志
+	RequestID string `json:"requestId,omitempty"`
 }
 
 type PageResult struct {
 	Items interface{} `json:"items"`
 	Total int64       `json:"total"`
 }
+
+// CursorPageResult 分页结果，游标分页时返回前后页的cursor，没有时为空；未查询总数时Total为-1
+type CursorPageResult struct {
+	PageResult
+	NextCursor string `json:"nextCursor,omitempty"`
+	PrevCursor string `json:"prevCursor,omitempty"`
+}
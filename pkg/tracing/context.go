@@ -0,0 +1,31 @@
+package tracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TraceIDFromContext 获取context中span的trace id，不存在返回空字符串
+func TraceIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.HasTraceID() {
+		return ""
+	}
+	return sc.TraceID().String()
+}
+
+// SpanIDFromContext 获取context中span的span id，不存在返回空字符串
+func SpanIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.HasSpanID() {
+		return ""
+	}
+	return sc.SpanID().String()
+}
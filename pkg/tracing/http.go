@@ -5,12 +5,12 @@ import (
 	"net/http/httptrace"
 	"net/textproto"
 
-	"github.com/opentracing/opentracing-go"
-	"github.com/opentracing/opentracing-go/ext"
-	"github.com/opentracing/opentracing-go/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
-func NewClientTrace(span opentracing.Span) *httptrace.ClientTrace {
+func NewClientTrace(span trace.Span) *httptrace.ClientTrace {
 	trace := &clientTrace{span: span}
 	return &httptrace.ClientTrace{
 		GetConn:              trace.GetConn,
@@ -34,112 +34,100 @@ func NewClientTrace(span opentracing.Span) *httptrace.ClientTrace {
 // clientTrace holds a reference to the Span and
 // provides methods used as ClientTrace callbacks
 type clientTrace struct {
-	span opentracing.Span
+	span trace.Span
+}
+
+func (h *clientTrace) event(name string, attrs ...attribute.KeyValue) {
+	h.span.AddEvent(name, trace.WithAttributes(attrs...))
 }
 
 func (h *clientTrace) GetConn(hostPort string) {
-	h.span.LogFields(
-		log.String("event", "GetConn"),
-		log.String("hostPort", hostPort),
-	)
+	h.event("GetConn", attribute.String("hostPort", hostPort))
 }
 
 func (h *clientTrace) GotConn(info httptrace.GotConnInfo) {
-	h.span.SetTag("net/http.reused", info.Reused)
-	h.span.SetTag("net/http.was_idle", info.WasIdle)
-	h.span.LogFields(log.String("event", "GotConn"))
+	h.span.SetAttributes(
+		attribute.Bool("net/http.reused", info.Reused),
+		attribute.Bool("net/http.was_idle", info.WasIdle),
+	)
+	h.event("GotConn")
 }
 
 func (h *clientTrace) PutIdleConn(err error) {
-	h.span.LogFields(log.String("event", "PutIdleConn"))
+	h.event("PutIdleConn")
 }
 
 func (h *clientTrace) GotFirstResponseByte() {
-	h.span.LogFields(log.String("event", "GotFirstResponseByte"))
+	h.event("GotFirstResponseByte")
 }
 
 func (h *clientTrace) Got100Continue() {
-	h.span.LogFields(log.String("event", "Got100Continue"))
+	h.event("Got100Continue")
 }
 
 func (h *clientTrace) Got1xxResponse(code int, header textproto.MIMEHeader) error {
-	h.span.LogFields(log.String("event", "Got1xxResponse"))
+	h.event("Got1xxResponse", attribute.Int("code", code))
 	return nil
 }
 
 func (h *clientTrace) DNSStart(info httptrace.DNSStartInfo) {
-	h.span.LogFields(
-		log.String("event", "DNSStart"),
-		log.Object("host", info.Host),
-	)
+	h.event("DNSStart", attribute.String("host", info.Host))
 }
 
 func (h *clientTrace) DNSDone(info httptrace.DNSDoneInfo) {
-	fields := []log.Field{log.String("event", "DNSDone")}
+	addrs := make([]string, 0, len(info.Addrs))
 	for _, addr := range info.Addrs {
-		fields = append(fields, log.String("addr", addr.String()))
+		addrs = append(addrs, addr.String())
 	}
+	attrs := []attribute.KeyValue{attribute.StringSlice("addrs", addrs)}
 	if info.Err != nil {
-		fields = append(fields, log.Error(info.Err))
+		attrs = append(attrs, attribute.String("error", info.Err.Error()))
 	}
-	h.span.LogFields(fields...)
+	h.event("DNSDone", attrs...)
 }
 
 func (h *clientTrace) ConnectStart(network, addr string) {
-	h.span.LogFields(
-		log.String("event", "ConnectStart"),
-		log.String("network", network),
-		log.String("addr", addr),
+	h.event("ConnectStart",
+		attribute.String("network", network),
+		attribute.String("addr", addr),
 	)
 }
 
 func (h *clientTrace) ConnectDone(network, addr string, err error) {
+	attrs := []attribute.KeyValue{
+		attribute.String("network", network),
+		attribute.String("addr", addr),
+	}
 	if err != nil {
-		h.span.LogFields(
-			log.String("message", "ConnectDone"),
-			log.String("network", network),
-			log.String("addr", addr),
-			log.String("event", "error"),
-			log.Error(err),
-		)
-	} else {
-		h.span.LogFields(
-			log.String("event", "ConnectDone"),
-			log.String("network", network),
-			log.String("addr", addr),
-		)
+		attrs = append(attrs, attribute.String("error", err.Error()))
 	}
+	h.event("ConnectDone", attrs...)
 }
 
 func (h *clientTrace) TLSHandshakeStart() {
-	h.span.LogFields(log.String("event", "TLSHandshakeStart"))
+	h.event("TLSHandshakeStart")
 }
 
 func (h *clientTrace) TLSHandshakeDone(tls.ConnectionState, error) {
-	h.span.LogFields(log.String("event", "TLSHandshakeDone"))
+	h.event("TLSHandshakeDone")
 }
 
 func (h *clientTrace) WroteHeaderField(key string, value []string) {
-	h.span.LogFields(log.String("event", "WroteHeaderField"))
+	h.event("WroteHeaderField")
 }
 
 func (h *clientTrace) WroteHeaders() {
-	h.span.LogFields(log.String("event", "WroteHeaders"))
+	h.event("WroteHeaders")
 }
 
 func (h *clientTrace) Wait100Continue() {
-	h.span.LogFields(log.String("event", "Wait100Continue"))
+	h.event("Wait100Continue")
 }
 
 func (h *clientTrace) WroteRequest(info httptrace.WroteRequestInfo) {
 	if info.Err != nil {
-		h.span.LogFields(
-			log.String("message", "WroteRequest"),
-			log.String("event", "error"),
-			log.Error(info.Err),
-		)
-		ext.Error.Set(h.span, true)
-	} else {
-		h.span.LogFields(log.String("event", "WroteRequest"))
+		h.span.RecordError(info.Err)
+		h.span.SetStatus(codes.Error, info.Err.Error())
 	}
+	h.event("WroteRequest")
 }
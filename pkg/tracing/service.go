@@ -1,36 +1,69 @@
 package tracing
 
 import (
-	"io"
+	"context"
+	"fmt"
+	"os"
 	"strings"
 
-	"github.com/mitchellh/mapstructure"
-	"github.com/opentracing/opentracing-go"
 	"github.com/spf13/viper"
-	"github.com/uber/jaeger-client-go"
-	jaegercfg "github.com/uber/jaeger-client-go/config"
+	"go.opentelemetry.io/contrib/propagators/b3"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
-type jaegerLoggerAdapter struct {
-	logger *zap.SugaredLogger
+const instrumentationName = "lovebox"
+
+// Tracer 全局tracer，未初始化时为noop
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
 }
 
-func (l jaegerLoggerAdapter) Error(msg string) {
-	l.logger.Error(msg)
+// Config tracing配置
+type Config struct {
+	ServiceName string            `mapstructure:"serviceName"`
+	Exporter    string            `mapstructure:"exporter"` // otlp-grpc | otlp-http | stdout | none
+	Endpoint    string            `mapstructure:"endpoint"`
+	Insecure    bool              `mapstructure:"insecure"`
+	Headers     map[string]string `mapstructure:"headers"`
+	Sampler     struct {
+		Type  string  `mapstructure:"type"` // always | never | ratio | parentbased_ratio
+		Ratio float64 `mapstructure:"ratio"`
+	} `mapstructure:"sampler"`
+	Propagators []string `mapstructure:"propagators"` // tracecontext | baggage | b3 | b3multi
 }
 
-func (l jaegerLoggerAdapter) Infof(msg string, args ...interface{}) {
-	l.logger.Infof(strings.Trim(msg, "\n\r "), args...)
+// loggingProcessor span结束时输出日志，用于调试
+type loggingProcessor struct {
+	log *zap.SugaredLogger
 }
 
-func NewLogger(log *zap.SugaredLogger) jaeger.Logger {
-	return &jaegerLoggerAdapter{log}
+func (p *loggingProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {}
+
+func (p *loggingProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	p.log.Infof("Reporting span %s:%s %s", s.SpanContext().TraceID(), s.SpanContext().SpanID(), s.Name())
+}
+
+func (p *loggingProcessor) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+func (p *loggingProcessor) ForceFlush(ctx context.Context) error {
+	return nil
 }
 
 type TracingService struct {
-	log    *zap.SugaredLogger
-	closer io.Closer
+	log      *zap.SugaredLogger
+	provider *sdktrace.TracerProvider
 
 	enableLogger bool
 }
@@ -42,48 +75,144 @@ func NewTracingService(enableLogger bool) *TracingService {
 	}
 }
 
+// InitGlobal 设置全局TracerProvider与propagator，cfg为nil时使用默认配置（不导出span）
 func (s *TracingService) InitGlobal(cfg *viper.Viper) error {
 	if cfg == nil {
-		return nil
+		cfg = viper.New()
 	}
 
-	if s.closer != nil {
-		s.closer.Close()
-		s.closer = nil
+	cfg.SetDefault("serviceName", "lovebox")
+	cfg.SetDefault("exporter", "none")
+	cfg.SetDefault("sampler.type", "parentbased_ratio")
+	cfg.SetDefault("sampler.ratio", 1)
+	cfg.SetDefault("propagators", []string{"tracecontext", "baggage", "b3"})
+	c := &Config{}
+	if err := cfg.Unmarshal(c); err != nil {
+		return err
 	}
 
-	jcfg := jaegercfg.Configuration{}
-
-	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
-		TagName:          "yaml",
-		Squash:           true,
-		Metadata:         nil,
-		Result:           &jcfg,
-		WeaklyTypedInput: true,
-		DecodeHook: mapstructure.ComposeDecodeHookFunc(
-			mapstructure.StringToTimeDurationHookFunc(),
-			mapstructure.StringToSliceHookFunc(","),
-		),
-	})
+	propagator, err := newPropagator(c.Propagators)
 	if err != nil {
 		return err
 	}
-	err = decoder.Decode(cfg.AllSettings())
+	otel.SetTextMapPropagator(propagator)
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		s.log.Warnf("Otel error %v", err)
+	}))
+
+	sampler, err := newSampler(c.Sampler.Type, c.Sampler.Ratio)
 	if err != nil {
 		return err
 	}
-
-	options := []jaegercfg.Option{}
-	if s.enableLogger {
-		options = append(options, jaegercfg.Logger(NewLogger(zap.S().With("module", "tracing.jaeger"))))
+	res, err := resource.Merge(
+		resource.Default(),
+		resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceName(c.ServiceName),
+		),
+	)
+	if err != nil {
+		return err
+	}
+	opts := []sdktrace.TracerProviderOption{
+		sdktrace.WithSampler(sampler),
+		sdktrace.WithResource(res),
 	}
-	tracer, closer, err := jcfg.NewTracer(options...)
+
+	exporter, err := newExporter(c)
 	if err != nil {
 		return err
 	}
-	s.closer = closer
+	if exporter != nil {
+		opts = append(opts, sdktrace.WithBatcher(exporter))
+	}
+	if s.enableLogger {
+		opts = append(opts, sdktrace.WithSpanProcessor(&loggingProcessor{
+			log: zap.S().With("module", "tracing.otel"),
+		}))
+	}
 
-	opentracing.SetGlobalTracer(tracer)
+	if s.provider != nil {
+		_ = s.provider.Shutdown(context.Background())
+	}
+	s.provider = sdktrace.NewTracerProvider(opts...)
+	otel.SetTracerProvider(s.provider)
 
 	return nil
 }
+
+// Shutdown 上报剩余的span
+func (s *TracingService) Shutdown(ctx context.Context) error {
+	if s.provider == nil {
+		return nil
+	}
+	return s.provider.Shutdown(ctx)
+}
+
+func newExporter(c *Config) (sdktrace.SpanExporter, error) {
+	ctx := context.Background()
+	switch c.Exporter {
+	case "otlp-grpc":
+		opts := []otlptracegrpc.Option{}
+		if c.Endpoint != "" {
+			opts = append(opts, otlptracegrpc.WithEndpoint(c.Endpoint))
+		}
+		if c.Insecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		if len(c.Headers) > 0 {
+			opts = append(opts, otlptracegrpc.WithHeaders(c.Headers))
+		}
+		return otlptrace.New(ctx, otlptracegrpc.NewClient(opts...))
+	case "otlp-http":
+		opts := []otlptracehttp.Option{}
+		if c.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpoint(c.Endpoint))
+		}
+		if c.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		if len(c.Headers) > 0 {
+			opts = append(opts, otlptracehttp.WithHeaders(c.Headers))
+		}
+		return otlptrace.New(ctx, otlptracehttp.NewClient(opts...))
+	case "stdout":
+		return stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case "none", "":
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unknown tracing exporter %q", c.Exporter)
+}
+
+func newSampler(typ string, ratio float64) (sdktrace.Sampler, error) {
+	switch typ {
+	case "always":
+		return sdktrace.AlwaysSample(), nil
+	case "never":
+		return sdktrace.NeverSample(), nil
+	case "ratio":
+		return sdktrace.TraceIDRatioBased(ratio), nil
+	case "parentbased_ratio", "":
+		return sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio)), nil
+	}
+	return nil, fmt.Errorf("unknown tracing sampler %q", typ)
+}
+
+func newPropagator(names []string) (propagation.TextMapPropagator, error) {
+	propagators := []propagation.TextMapPropagator{}
+	for _, name := range names {
+		switch strings.TrimSpace(name) {
+		case "tracecontext":
+			propagators = append(propagators, propagation.TraceContext{})
+		case "baggage":
+			propagators = append(propagators, propagation.Baggage{})
+		case "b3":
+			propagators = append(propagators, b3.New())
+		case "b3multi":
+			propagators = append(propagators, b3.New(b3.WithInjectEncoding(b3.B3MultipleHeader)))
+		default:
+			return nil, fmt.Errorf("unknown tracing propagator %q", name)
+		}
+	}
+	return propagation.NewCompositeTextMapPropagator(propagators...), nil
+}
@@ -1,10 +1,11 @@
 package validator
 
 import (
+	"reflect"
 	"regexp"
 
-	"github.com/globalsign/mgo/bson"
 	validator "github.com/go-playground/validator/v10"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 var (
@@ -24,7 +25,18 @@ func ValidateObjectID(fl validator.FieldLevel) bool {
 		return true
 	}
 
-	return bson.IsObjectIdHex(value)
+	return primitive.IsValidObjectID(value)
+}
+
+// ObjectIDTypeFunc 将primitive.ObjectID转为hex字符串参与校验，零值视为空
+func ObjectIDTypeFunc(field reflect.Value) interface{} {
+	if id, ok := field.Interface().(primitive.ObjectID); ok {
+		if id.IsZero() {
+			return ""
+		}
+		return id.Hex()
+	}
+	return nil
 }
 
 // ValidateDate ...
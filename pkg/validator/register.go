@@ -2,8 +2,11 @@ package validator
 
 import (
 	"errors"
+	"strings"
 	"sync"
 
+	"lovebox/models"
+
 	"github.com/gin-gonic/gin/binding"
 	en_locales "github.com/go-playground/locales/en"
 	zh_locales "github.com/go-playground/locales/zh"
@@ -11,6 +14,7 @@ import (
 	validator "github.com/go-playground/validator/v10"
 	en_translations "github.com/go-playground/validator/v10/translations/en"
 	zh_translations "github.com/go-playground/validator/v10/translations/zh"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 var (
@@ -53,6 +57,8 @@ func Register(v *validator.Validate) (*ut.UniversalTranslator, error) {
 	registerMu.Lock()
 	defer registerMu.Unlock()
 
+	v.RegisterCustomTypeFunc(ObjectIDTypeFunc, primitive.ObjectID{})
+
 	err := v.RegisterValidation("objectid", ValidateObjectID)
 	if err != nil {
 		return nil, err
@@ -84,6 +90,8 @@ func Register(v *validator.Validate) (*ut.UniversalTranslator, error) {
 	}
 
 	v.RegisterAlias("weekday", "oneof=Sunday Monday Tuesday Wednesday Thursday Friday Saturday")
+	// 可订阅的webhook事件，与models.WebhookEvents保持一致
+	v.RegisterAlias("webhook_event", "oneof="+strings.Join(models.WebhookEvents, " "))
 
 	en := en_locales.New()
 	zh := zh_locales.New()
@@ -180,5 +188,25 @@ func Register(v *validator.Validate) (*ut.UniversalTranslator, error) {
 		return nil, err
 	}
 
+	err = v.RegisterTranslation("webhook_event", enTrans, func(ut ut.Translator) error {
+		return ut.Add("webhook_event", "{0} must be one of ["+strings.Join(models.WebhookEvents, " ")+"]!", true)
+	}, func(ut ut.Translator, fe validator.FieldError) string {
+		t, _ := ut.T("webhook_event", fe.Field())
+		return t
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = v.RegisterTranslation("webhook_event", zhTrans, func(ut ut.Translator) error {
+		return ut.Add("webhook_event", "{0} 必须是 ["+strings.Join(models.WebhookEvents, " ")+"] 中的一个!", true)
+	}, func(ut ut.Translator, fe validator.FieldError) string {
+		t, _ := ut.T("webhook_event", fe.Field())
+		return t
+	})
+	if err != nil {
+		return nil, err
+	}
+
 	return uni, nil
 }
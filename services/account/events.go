@@ -0,0 +1,90 @@
+package account
+
+import (
+	"context"
+	"fmt"
+
+	"lovebox/models"
+	"lovebox/pkg/eventbus"
+	"lovebox/pkg/httpcache"
+)
+
+const auditModule = "account"
+
+// Subscribe 注册账号相关的事件订阅者
+func (s *Service) Subscribe(bus *eventbus.Bus) {
+	eventbus.Subscribe(bus, "account.updateLoginInfo", s.updateLoginInfo)
+	eventbus.Subscribe(bus, "account.invalidateCache", s.invalidateCache)
+	eventbus.Subscribe(bus, "account.recordLogin", s.recordLogin, eventbus.Async())
+	eventbus.Subscribe(bus, "account.recordLoginFailed", s.recordLoginFailed, eventbus.Async())
+	eventbus.Subscribe(bus, "account.auditRegistered", s.auditRegistered, eventbus.Async())
+}
+
+// updateLoginInfo 更新最后登录时间、ip与登录次数
+func (s *Service) updateLoginInfo(ctx context.Context, event models.AccountLoggedInEvent) error {
+	return s.mysqlClient.Db().WithContext(ctx).
+		Model(&models.Account{}).
+		Where("id = ?", event.AccountID).
+		Updates(models.Account{
+			LastLoginTime: &event.LoginAt,
+			LastLoginIp:   event.IP,
+			LoginTimes:    event.LoginTimes,
+		}).
+		Error
+}
+
+// invalidateCache 删除jwt中间件缓存的账号与账号相关接口的响应缓存
+func (s *Service) invalidateCache(ctx context.Context, event models.AccountLoggedInEvent) error {
+	if err := s.cacheClient.Delete(ctx, fmt.Sprintf("account:%d", event.AccountID)); err != nil {
+		return err
+	}
+	return s.httpCache.Invalidate(ctx, httpcache.AccountTag(event.AccountID))
+}
+
+func (s *Service) recordLogin(ctx context.Context, event models.AccountLoggedInEvent) error {
+	s.analyticsClient.Record(&models.LoginEvent{
+		Time:      event.LoginAt,
+		AccountID: uint64(event.AccountID),
+		Username:  event.Username,
+		IP:        event.IP,
+		Success:   1,
+	})
+	return nil
+}
+
+func (s *Service) recordLoginFailed(ctx context.Context, event models.AccountLoginFailedEvent) error {
+	s.analyticsClient.Record(&models.LoginEvent{
+		Time:      event.FailedAt,
+		AccountID: uint64(event.AccountID),
+		Username:  event.Username,
+		IP:        event.IP,
+		Reason:    event.Reason,
+	})
+	return nil
+}
+
+// auditRegistered 注册账号写入操作日志，对外通知由webhook订阅account.registered
+func (s *Service) auditRegistered(ctx context.Context, event models.AccountRegisteredEvent) error {
+	operateLog := &models.OperateLogs{
+		AccountID:   event.AccountID,
+		AccountName: event.Username,
+		Module:      auditModule,
+		IP:          event.IP,
+		Content:     "注册账号",
+	}
+	err := s.mysqlClient.Db().WithContext(ctx).
+		Create(operateLog).
+		Error
+	if err != nil {
+		return err
+	}
+
+	s.analyticsClient.Record(&models.OperateLogEvent{
+		Time:      event.RegisteredAt,
+		AccountID: uint64(event.AccountID),
+		Module:    operateLog.Module,
+		IP:        operateLog.IP,
+		Content:   operateLog.Content,
+	})
+	return nil
+}
@@ -0,0 +1,27 @@
+package account
+
+import (
+	"context"
+
+	"lovebox/models"
+)
+
+// RecomputeStats 重新统计账号总数并写入统计
+func (s *Service) RecomputeStats(ctx context.Context) error {
+	db := s.mysqlClient.Db().WithContext(ctx)
+
+	var total, locked int64
+	if err := db.Model(&models.Account{}).Count(&total).Error; err != nil {
+		return err
+	}
+	err := db.Model(&models.Account{}).
+		Where("status = ?", models.AccountStatusLock).
+		Count(&locked).
+		Error
+	if err != nil {
+		return err
+	}
+
+	s.statsSvc.RecordAccountTotals(total, locked)
+	return nil
+}
@@ -5,13 +5,19 @@ import (
 	"context"
 	"errors"
 	"image/png"
+	"strconv"
 	"time"
 
 	"lovebox/models"
+	"lovebox/pkg/analytics"
 	"lovebox/pkg/database"
+	"lovebox/pkg/eventbus"
+	"lovebox/pkg/httpcache"
 	"lovebox/pkg/jwt"
 	"lovebox/pkg/resp"
 	"lovebox/pkg/utils"
+	"lovebox/services/outbox"
+	"lovebox/services/stats"
 
 	"github.com/afocus/captcha"
 	redisCache "github.com/go-redis/cache/v8"
@@ -21,25 +27,40 @@ import (
 )
 
 type Service struct {
-	log         *zap.SugaredLogger
-	mysqlClient *database.Client
-	redisClient *redis.Client
-	cacheClient *redisCache.Cache
-	jwt         *jwt.Jwt
+	log             *zap.SugaredLogger
+	mysqlClient     *database.Client
+	redisClient     *redis.Client
+	cacheClient     *redisCache.Cache
+	httpCache       *httpcache.Cache
+	analyticsClient *analytics.Client
+	statsSvc        *stats.Service
+	outboxSvc       *outbox.Service
+	bus             *eventbus.Bus
+	jwt             *jwt.Jwt
 }
 
 func NewService(
 	mysqlClient *database.Client,
 	redisClient *redis.Client,
 	cacheClient *redisCache.Cache,
+	httpCache *httpcache.Cache,
+	analyticsClient *analytics.Client,
+	statsSvc *stats.Service,
+	outboxSvc *outbox.Service,
+	bus *eventbus.Bus,
 	jwt *jwt.Jwt,
 ) *Service {
 	return &Service{
-		log:         zap.S().With("module", "services.account.service"),
-		mysqlClient: mysqlClient,
-		redisClient: redisClient,
-		cacheClient: cacheClient,
-		jwt:         jwt,
+		log:             zap.S().With("module", "services.account.service"),
+		mysqlClient:     mysqlClient,
+		redisClient:     redisClient,
+		cacheClient:     cacheClient,
+		httpCache:       httpCache,
+		analyticsClient: analyticsClient,
+		statsSvc:        statsSvc,
+		outboxSvc:       outboxSvc,
+		bus:             bus,
+		jwt:             jwt,
 	}
 }
 
@@ -94,12 +115,16 @@ func (s *Service) Login(
 		return "", nil, err
 	}
 	if account.ID == 0 {
+		s.loginFailed(ctx, account, req.Username, ip, resp.ACCOUNT_NOT_FOUND)
 		return "", nil, errors.New(resp.ACCOUNT_NOT_FOUND)
 	}
 	if account.Status == models.AccountStatusLock {
+		s.loginFailed(ctx, account, req.Username, ip, resp.ACCOUNT_LOCKED)
+		s.locked(ctx, account, ip)
 		return "", nil, errors.New(resp.ACCOUNT_LOCKED)
 	}
 	if utils.Md5(utils.Md5(req.Password)+account.PasswordSalt) != account.Password {
+		s.loginFailed(ctx, account, req.Username, ip, resp.ACCOUNT_PWD_ERROR)
 		return "", nil, errors.New(resp.ACCOUNT_PWD_ERROR)
 	}
 
@@ -112,22 +137,64 @@ func (s *Service) Login(
 		return "", nil, errors.New(resp.SERVER_ERROR)
 	}
 
-	now := time.Now()
-	err = s.mysqlClient.Db().
-		Model(&account).
-		Updates(models.Account{
-			LastLoginTime: &now,
-			LastLoginIp:   ip,
-			LoginTimes:    account.LoginTimes + 1,
-		}).
-		Error
+	event := models.AccountLoggedInEvent{
+		AccountID:  account.ID,
+		Username:   account.Username,
+		IP:         ip,
+		LoginTimes: account.LoginTimes + 1,
+		LoginAt:    time.Now(),
+	}
+	// webhook通过outbox投递
+	err = s.outboxSvc.Add(
+		ctx,
+		models.AggregateAccount,
+		strconv.FormatUint(uint64(account.ID), 10),
+		event.EventName(),
+		&event,
+		nil,
+	)
 	if err != nil {
-		s.log.Errorf("Login update account %v", err)
+		s.log.Errorf("Login outboxSvc.Add %v", err)
+		return "", nil, errors.New(resp.SERVER_ERROR)
 	}
+	// 登录信息更新、统计等由订阅者处理
+	_ = s.bus.Publish(ctx, event)
 
 	return token, account, nil
 }
 
+func (s *Service) loginFailed(ctx context.Context, account *models.Account, username, ip, reason string) {
+	_ = s.bus.Publish(ctx, models.AccountLoginFailedEvent{
+		AccountID: account.ID,
+		Username:  username,
+		IP:        ip,
+		Reason:    reason,
+		FailedAt:  time.Now(),
+	})
+}
+
+// locked 账号已锁定，写入outbox供webhook投递
+func (s *Service) locked(ctx context.Context, account *models.Account, ip string) {
+	event := models.AccountLockedEvent{
+		AccountID:  account.ID,
+		Username:   account.Username,
+		IP:         ip,
+		DetectedAt: time.Now(),
+	}
+	err := s.outboxSvc.Add(
+		ctx,
+		models.AggregateAccount,
+		strconv.FormatUint(uint64(account.ID), 10),
+		event.EventName(),
+		&event,
+		nil,
+	)
+	if err != nil {
+		s.log.Errorf("Login outboxSvc.Add %v", err)
+	}
+	_ = s.bus.Publish(ctx, event)
+}
+
 // Register 账号注册
 func (s *Service) Register(
 	ctx context.Context,
@@ -155,10 +222,46 @@ func (s *Service) Register(
 	account.Password = utils.Md5(utils.Md5(req.Password) + account.PasswordSalt)
 	account.LastLoginIp = ip
 
-	err = s.mysqlClient.Db().
-		Model(&models.Account{}).
-		Create(account).Error
+	// 账号、扩展信息与注册事件在同一事务中创建
+	var event models.AccountRegisteredEvent
+	err = s.mysqlClient.WithTransaction(ctx, func(ctx context.Context) error {
+		// 死锁重试时重新生成id
+		account.ID = 0
+		err := s.mysqlClient.Db().WithContext(ctx).
+			Model(&models.Account{}).
+			Create(account).
+			Error
+		if err != nil {
+			return err
+		}
+
+		err = s.mysqlClient.Db().WithContext(ctx).
+			Model(&models.AccountExtraInfo{}).
+			Create(&models.AccountExtraInfo{
+				AccoutnId: account.ID,
+			}).
+			Error
+		if err != nil {
+			return err
+		}
+
+		event = models.AccountRegisteredEvent{
+			AccountID:    account.ID,
+			Username:     account.Username,
+			IP:           ip,
+			RegisteredAt: now,
+		}
+		return s.outboxSvc.Add(
+			ctx,
+			models.AggregateAccount,
+			strconv.FormatUint(uint64(account.ID), 10),
+			event.EventName(),
+			&event,
+			nil,
+		)
+	})
 	if err != nil {
+		s.log.Errorf("Register create account %v", err)
 		return "", nil, err
 	}
 
@@ -170,17 +273,7 @@ func (s *Service) Register(
 		s.log.Errorf("Register jwt.BuildToken %v", err)
 		return "", nil, errors.New(resp.SERVER_ERROR)
 	}
-
-	go func() {
-		err := s.mysqlClient.Db().
-			Model(&models.AccountExtraInfo{}).
-			Create(&models.AccountExtraInfo{
-				AccoutnId: account.ID,
-			}).Error
-		if err != nil {
-			s.log.Errorf("Register create AccountExtraInfo %v", err)
-		}
-	}()
+	_ = s.bus.Publish(ctx, event)
 
 	return token, account, nil
 }
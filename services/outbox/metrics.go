@@ -0,0 +1,42 @@
+package outbox
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	metricsOnce sync.Once
+
+	published *prometheus.CounterVec
+	pending   prometheus.Gauge
+	cleaned   prometheus.Counter
+)
+
+func registerMetrics() {
+	published = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "outbox_published_total",
+			Help: "How many outbox messages relayed, partitioned by event type and result.",
+		},
+		[]string{"event", "result"},
+	)
+	prometheus.MustRegister(published)
+
+	pending = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "outbox_pending_messages",
+			Help: "How many outbox messages are waiting to be relayed.",
+		},
+	)
+	prometheus.MustRegister(pending)
+
+	cleaned = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "outbox_cleaned_total",
+			Help: "How many delivered outbox messages were deleted.",
+		},
+	)
+	prometheus.MustRegister(cleaned)
+}
@@ -0,0 +1,286 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"lovebox/models"
+	"lovebox/pkg/database"
+	"lovebox/pkg/rabbitmq"
+
+	"github.com/go-redsync/redsync/v4"
+	"github.com/streadway/amqp"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	HeaderAggregateType = "x-aggregate-type"
+	HeaderAggregateID   = "x-aggregate-id"
+	HeaderEventType     = "x-event-type"
+
+	relayLockName = "outbox:relay"
+	relayLockTTL  = 30 * time.Second
+	// relayExtendInterval 发布过程中续期锁的间隔，加上一次发布确认的超时仍需小于relayLockTTL
+	relayExtendInterval = relayLockTTL / 3
+
+	cleanupChunk    = 1000
+	maxLastErrorLen = 500
+)
+
+// Relay 将outbox中待发布的消息按id顺序发布到rabbitmq，
+// 同一时刻只有持有redsync锁的实例在发布，同一聚合的消息发布失败时跳过其后续消息以保证顺序
+type Relay struct {
+	log             *zap.SugaredLogger
+	mysqlClient     *database.Client
+	redSyncClient   *redsync.Redsync
+	publisher       *rabbitmq.Client
+	exchange        string
+	batchSize       int
+	pollInterval    time.Duration
+	retention       time.Duration
+	cleanupInterval time.Duration
+}
+
+func NewRelay(
+	mysqlClient *database.Client,
+	redSyncClient *redsync.Redsync,
+	publisher *rabbitmq.Client,
+	exchange string,
+	batchSize int,
+	pollInterval time.Duration,
+	retention time.Duration,
+	cleanupInterval time.Duration,
+) *Relay {
+	metricsOnce.Do(registerMetrics)
+
+	return &Relay{
+		log:             zap.S().With("module", "services.outbox.relay"),
+		mysqlClient:     mysqlClient,
+		redSyncClient:   redSyncClient,
+		publisher:       publisher,
+		exchange:        exchange,
+		batchSize:       batchSize,
+		pollInterval:    pollInterval,
+		retention:       retention,
+		cleanupInterval: cleanupInterval,
+	}
+}
+
+// Run 轮询发布直到ctx结束
+func (r *Relay) Run(ctx context.Context) {
+	mutex := r.redSyncClient.NewMutex(
+		relayLockName,
+		redsync.WithExpiry(relayLockTTL),
+		redsync.WithTries(1),
+	)
+	ticker := time.NewTicker(r.pollInterval)
+	defer ticker.Stop()
+
+	leader := false
+	var lastCleanup time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			if leader {
+				unlockCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				_, _ = mutex.UnlockContext(unlockCtx)
+				cancel()
+			}
+			return
+		case <-ticker.C:
+		}
+
+		if leader {
+			if ok, err := mutex.ExtendContext(ctx); !ok || err != nil {
+				r.log.Warnf("Relay lost lock err=%v", err)
+				leader = false
+			}
+		}
+		if !leader {
+			if err := mutex.LockContext(ctx); err != nil {
+				continue
+			}
+			r.log.Infof("Relay acquired lock")
+			leader = true
+		}
+
+		// 一批全部发布成功时继续发布，直到积压处理完；有发布失败时等待下次轮询
+		for ctx.Err() == nil {
+			n, err := r.relayBatch(ctx, mutex)
+			if errors.Is(err, errLostLock) {
+				r.log.Warnf("Relay lost lock during batch")
+				leader = false
+				break
+			}
+			if err != nil {
+				r.log.Errorf("Relay batch err=%v", err)
+				break
+			}
+			if n < r.batchSize {
+				break
+			}
+			if ok, err := mutex.ExtendContext(ctx); !ok || err != nil {
+				r.log.Warnf("Relay lost lock err=%v", err)
+				leader = false
+				break
+			}
+		}
+		if !leader {
+			continue
+		}
+
+		if time.Since(lastCleanup) >= r.cleanupInterval {
+			r.cleanup(ctx)
+			lastCleanup = time.Now()
+		}
+	}
+}
+
+// errLostLock 发布过程中续期锁失败，其他实例可能已开始发布
+var errLostLock = errors.New("outbox relay lost lock")
+
+// relayBatch 发布一批待发布消息，返回发布成功的消息数量；
+// 发布过程中定期续期锁，续期失败时立即停止以免与其他实例同时发布同一聚合的消息
+func (r *Relay) relayBatch(ctx context.Context, mutex *redsync.Mutex) (int, error) {
+	db := r.mysqlClient.Db().WithContext(ctx)
+
+	var count int64
+	err := db.Model(&models.OutboxMessage{}).
+		Where("status = ?", models.OutboxStatusPending).
+		Count(&count).
+		Error
+	if err != nil {
+		return 0, err
+	}
+	pending.Set(float64(count))
+	if count == 0 {
+		return 0, nil
+	}
+
+	messages := []*models.OutboxMessage{}
+	err = db.Model(&models.OutboxMessage{}).
+		Where("status = ?", models.OutboxStatusPending).
+		Order("id asc").
+		Limit(r.batchSize).
+		Find(&messages).
+		Error
+	if err != nil {
+		return 0, err
+	}
+
+	blocked := map[string]bool{}
+	delivered := 0
+	extendedAt := time.Now()
+	for _, msg := range messages {
+		if ctx.Err() != nil {
+			break
+		}
+		if time.Since(extendedAt) >= relayExtendInterval {
+			if ok, err := mutex.ExtendContext(ctx); !ok || err != nil {
+				return delivered, errLostLock
+			}
+			extendedAt = time.Now()
+		}
+		aggregate := msg.AggregateType + ":" + msg.AggregateID
+		if blocked[aggregate] {
+			continue
+		}
+
+		err := r.publish(ctx, msg)
+		result := "ok"
+		if errors.Is(err, rabbitmq.ErrUnroutable) {
+			// 没有队列订阅该事件，视为已发布，避免阻塞同一聚合的后续消息
+			r.log.Warnf("Relay publish id=%d event=%s unroutable", msg.ID, msg.EventType)
+			result = "unroutable"
+			err = nil
+		}
+		if err != nil {
+			blocked[aggregate] = true
+			published.WithLabelValues(msg.EventType, "error").Inc()
+			r.log.Warnf("Relay publish id=%d event=%s attempts=%d err=%v", msg.ID, msg.EventType, msg.Attempts+1, err)
+
+			lastError := err.Error()
+			if len(lastError) > maxLastErrorLen {
+				lastError = lastError[:maxLastErrorLen]
+			}
+			err = db.Model(&models.OutboxMessage{}).
+				Where("id = ?", msg.ID).
+				Updates(map[string]interface{}{
+					"attempts":   gorm.Expr("attempts + 1"),
+					"last_error": lastError,
+				}).
+				Error
+			if err != nil {
+				return delivered, err
+			}
+			continue
+		}
+
+		published.WithLabelValues(msg.EventType, result).Inc()
+		now := time.Now()
+		err = db.Model(&models.OutboxMessage{}).
+			Where("id = ?", msg.ID).
+			Updates(map[string]interface{}{
+				"status":       models.OutboxStatusDelivered,
+				"attempts":     gorm.Expr("attempts + 1"),
+				"delivered_at": &now,
+			}).
+			Error
+		if err != nil {
+			// 状态未更新时会被重复发布，消费方通过MessageId去重
+			return delivered, err
+		}
+		delivered++
+	}
+
+	return delivered, nil
+}
+
+func (r *Relay) publish(ctx context.Context, msg *models.OutboxMessage) error {
+	headers := amqp.Table{}
+	if msg.Headers != "" {
+		if err := json.Unmarshal([]byte(msg.Headers), &headers); err != nil {
+			return err
+		}
+	}
+	headers[HeaderAggregateType] = msg.AggregateType
+	headers[HeaderAggregateID] = msg.AggregateID
+	headers[HeaderEventType] = msg.EventType
+
+	return r.publisher.ProduceWithParam(ctx, &rabbitmq.ProduceParam{
+		Exchange:    r.exchange,
+		RoutingKey:  msg.EventType,
+		Body:        []byte(msg.Payload),
+		ContentType: "application/json",
+		MessageID:   fmt.Sprintf("outbox-%d", msg.ID),
+		Headers:     headers,
+	})
+}
+
+// cleanup 分批删除超过保留时间的已发布消息
+func (r *Relay) cleanup(ctx context.Context) {
+	before := time.Now().Add(-r.retention)
+	var total int64
+	for ctx.Err() == nil {
+		result := r.mysqlClient.Db().WithContext(ctx).
+			Where("status = ? AND delivered_at < ?", models.OutboxStatusDelivered, before).
+			Limit(cleanupChunk).
+			Delete(&models.OutboxMessage{})
+		if result.Error != nil {
+			r.log.Errorf("Relay cleanup err=%v", result.Error)
+			break
+		}
+		total += result.RowsAffected
+		if result.RowsAffected < cleanupChunk {
+			break
+		}
+	}
+	if total > 0 {
+		cleaned.Add(float64(total))
+		r.log.Infof("Relay cleanup deleted=%d", total)
+	}
+}
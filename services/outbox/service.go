@@ -0,0 +1,76 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+
+	"lovebox/models"
+	"lovebox/pkg/database"
+	"lovebox/pkg/requestid"
+
+	"go.uber.org/zap"
+)
+
+type Service struct {
+	log         *zap.SugaredLogger
+	mysqlClient *database.Client
+}
+
+func NewService(
+	mysqlClient *database.Client,
+) *Service {
+	return &Service{
+		log:         zap.S().With("module", "services.outbox.service"),
+		mysqlClient: mysqlClient,
+	}
+}
+
+// Add 写入待发布事件，在WithTransaction的ctx中调用时与业务数据一同提交
+func (s *Service) Add(
+	ctx context.Context,
+	aggregateType string,
+	aggregateID string,
+	eventType string,
+	payload interface{},
+	headers map[string]interface{},
+) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	msg := &models.OutboxMessage{
+		AggregateType: aggregateType,
+		AggregateID:   aggregateID,
+		EventType:     eventType,
+		Payload:       string(body),
+		Status:        models.OutboxStatusPending,
+	}
+	// relay在后台发布，request id需随消息一同保存
+	if id := requestid.FromContext(ctx); id != "" {
+		if _, ok := headers[requestid.AMQPHeader]; !ok {
+			h := make(map[string]interface{}, len(headers)+1)
+			for k, v := range headers {
+				h[k] = v
+			}
+			h[requestid.AMQPHeader] = id
+			headers = h
+		}
+	}
+	if len(headers) > 0 {
+		buf, err := json.Marshal(headers)
+		if err != nil {
+			return err
+		}
+		msg.Headers = string(buf)
+	}
+
+	err = s.mysqlClient.Db().WithContext(ctx).
+		Model(&models.OutboxMessage{}).
+		Create(msg).
+		Error
+	if err != nil {
+		s.log.Errorf("Add outbox message event=%s err=%v", eventType, err)
+		return err
+	}
+	return nil
+}
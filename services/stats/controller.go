@@ -0,0 +1,40 @@
+package stats
+
+import (
+	"net/http"
+
+	"lovebox/models"
+	"lovebox/pkg/resp"
+
+	"github.com/gin-gonic/gin"
+)
+
+type GinController struct {
+	StatsSvc *Service
+}
+
+// NewGinController ...
+func NewGinController(svc *Service) *GinController {
+	return &GinController{
+		StatsSvc: svc,
+	}
+}
+
+// Series 查询业务事件统计
+func (ctrl *GinController) Series(c *gin.Context) {
+	req := &models.StatsSeriesReq{}
+	if err := c.ShouldBind(req); err != nil {
+		_ = c.Error(err).
+			SetType(gin.ErrorTypePublic)
+		return
+	}
+
+	result, err := ctrl.StatsSvc.Series(c.Request.Context(), req)
+	if err != nil {
+		_ = c.Error(err).
+			SetType(gin.ErrorTypePublic)
+		return
+	}
+
+	c.JSON(http.StatusOK, &resp.Response{Result: result})
+}
@@ -0,0 +1,24 @@
+package stats
+
+import (
+	"context"
+
+	"lovebox/models"
+	"lovebox/pkg/eventbus"
+)
+
+// Subscribe 统计账号事件
+func (s *Service) Subscribe(bus *eventbus.Bus) {
+	eventbus.Subscribe(bus, "stats.registration", func(ctx context.Context, event models.AccountRegisteredEvent) error {
+		s.RecordRegistration()
+		return nil
+	}, eventbus.Async())
+	eventbus.Subscribe(bus, "stats.login", func(ctx context.Context, event models.AccountLoggedInEvent) error {
+		s.RecordLogin()
+		return nil
+	}, eventbus.Async())
+	eventbus.Subscribe(bus, "stats.loginFailed", func(ctx context.Context, event models.AccountLoginFailedEvent) error {
+		s.RecordLoginFailed(event.IP, event.Reason)
+		return nil
+	}, eventbus.Async())
+}
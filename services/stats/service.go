@@ -0,0 +1,148 @@
+package stats
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"lovebox/models"
+	"lovebox/pkg/errors"
+	"lovebox/pkg/influxdb"
+	"lovebox/pkg/resp"
+
+	"go.uber.org/zap"
+)
+
+const (
+	MeasurementRegistrations = "account_registrations"
+	MeasurementLogins        = "account_logins"
+	MeasurementLoginFailures = "account_login_failures"
+	MeasurementAccountTotals = "account_totals"
+
+	fieldCount = "count"
+
+	defaultRange = 24 * time.Hour
+	defaultEvery = time.Hour
+	maxPoints    = 1000
+)
+
+var (
+	// ErrStatsDisabled ...
+	ErrStatsDisabled = errors.NewWithStatus(resp.STATS_DISABLED, http.StatusServiceUnavailable)
+	// ErrInvalidRange ...
+	ErrInvalidRange = errors.NewWithStatus(resp.PARAM_INVALID, http.StatusBadRequest)
+
+	measurements = map[string]string{
+		"registrations": MeasurementRegistrations,
+		"logins":        MeasurementLogins,
+		"loginFailures": MeasurementLoginFailures,
+	}
+)
+
+type Service struct {
+	log          *zap.SugaredLogger
+	influxClient *influxdb.Client
+	writer       *influxdb.BatchWriter
+}
+
+// NewService influxClient为nil（未启用influxdb）时只忽略写入
+func NewService(
+	influxClient *influxdb.Client,
+	writer *influxdb.BatchWriter,
+) *Service {
+	return &Service{
+		log:          zap.S().With("module", "services.stats.service"),
+		influxClient: influxClient,
+		writer:       writer,
+	}
+}
+
+// RecordRegistration 记录注册
+func (s *Service) RecordRegistration() {
+	s.write(MeasurementRegistrations, nil)
+}
+
+// RecordLogin 记录登录成功
+func (s *Service) RecordLogin() {
+	s.write(MeasurementLogins, nil)
+}
+
+// RecordLoginFailed 记录登录失败，按ip与原因统计
+func (s *Service) RecordLoginFailed(ip, reason string) {
+	s.write(MeasurementLoginFailures, map[string]string{
+		"ip":     ip,
+		"reason": reason,
+	})
+}
+
+// RecordAccountTotals 记录账号总数快照
+func (s *Service) RecordAccountTotals(total, locked int64) {
+	if s.writer == nil {
+		return
+	}
+	s.writer.WritePoint(influxdb.NewPoint(
+		MeasurementAccountTotals,
+		nil,
+		map[string]interface{}{
+			"total":  total,
+			"locked": locked,
+		},
+		time.Now(),
+	))
+}
+
+func (s *Service) write(measurement string, tags map[string]string) {
+	if s.writer == nil {
+		return
+	}
+	s.writer.WritePoint(influxdb.NewPoint(
+		measurement,
+		tags,
+		map[string]interface{}{fieldCount: 1},
+		time.Now(),
+	))
+}
+
+// Series 查询事件的时间分桶统计
+func (s *Service) Series(
+	ctx context.Context,
+	req *models.StatsSeriesReq,
+) ([]influxdb.SeriesPoint, error) {
+	if s.influxClient == nil {
+		return nil, ErrStatsDisabled
+	}
+
+	rng, err := parseDuration(req.Range, defaultRange)
+	if err != nil {
+		return nil, err
+	}
+	every, err := parseDuration(req.Every, defaultEvery)
+	if err != nil {
+		return nil, err
+	}
+	if rng/every > maxPoints {
+		return nil, ErrInvalidRange
+	}
+
+	stop := time.Now()
+	return s.influxClient.QuerySeries(ctx, &influxdb.SeriesQuery{
+		Measurement: measurements[req.Event],
+		Field:       fieldCount,
+		Start:       stop.Add(-rng),
+		Stop:        stop,
+		Every:       every,
+		Fn:          "sum",
+		GroupBy:     req.GroupBy,
+	})
+}
+
+func parseDuration(val string, def time.Duration) (time.Duration, error) {
+	if val == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(val)
+	if err != nil || d < time.Second {
+		return 0, ErrInvalidRange
+	}
+	return d, nil
+}
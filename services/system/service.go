@@ -2,6 +2,7 @@ package system
 
 import (
 	"context"
+	"time"
 
 	"lovebox/models"
 	"lovebox/pkg/database"
@@ -9,6 +10,8 @@ import (
 	"go.uber.org/zap"
 )
 
+const pruneChunk = 1000
+
 type Service struct {
 	log         *zap.SugaredLogger
 	mysqlClient *database.Client
@@ -37,3 +40,29 @@ func (s *Service) CreateOperateLog(
 	}
 	return nil
 }
+
+// PruneOperateLogs 分批删除before之前的操作日志，返回删除数量
+func (s *Service) PruneOperateLogs(
+	ctx context.Context,
+	before time.Time,
+) (int64, error) {
+	var total int64
+	for ctx.Err() == nil {
+		result := s.mysqlClient.Db().WithContext(ctx).
+			Unscoped().
+			Where("created_at < ?", before).
+			Limit(pruneChunk).
+			Delete(&models.OperateLogs{})
+		if result.Error != nil {
+			return total, result.Error
+		}
+		total += result.RowsAffected
+		if result.RowsAffected < pruneChunk {
+			break
+		}
+	}
+	if total > 0 {
+		s.log.Infof("PruneOperateLogs deleted=%d", total)
+	}
+	return total, ctx.Err()
+}
@@ -0,0 +1,136 @@
+package webhook
+
+import (
+	"net/http"
+
+	"lovebox/models"
+	"lovebox/pkg/gin/middlewares"
+	"lovebox/pkg/resp"
+
+	"github.com/gin-gonic/gin"
+)
+
+type GinController struct {
+	WebhookSvc *Service
+}
+
+// NewGinController ...
+func NewGinController(svc *Service) *GinController {
+	return &GinController{
+		WebhookSvc: svc,
+	}
+}
+
+// Create 创建订阅
+func (ctrl *GinController) Create(c *gin.Context) {
+	req := &models.CreateWebhookReq{}
+	if err := c.ShouldBind(req); err != nil {
+		_ = c.Error(err).
+			SetType(gin.ErrorTypePublic)
+		return
+	}
+
+	result, err := ctrl.WebhookSvc.Create(c.Request.Context(), req)
+	if err != nil {
+		_ = c.Error(err).
+			SetType(gin.ErrorTypePublic)
+		return
+	}
+
+	c.JSON(http.StatusOK, &resp.Response{Result: result})
+}
+
+// List 订阅列表
+func (ctrl *GinController) List(c *gin.Context) {
+	p := c.MustGet("pagination").(*middlewares.Pagination)
+	result, err := ctrl.WebhookSvc.List(c.Request.Context(), p)
+	if err != nil {
+		_ = c.Error(err).
+			SetType(gin.ErrorTypePublic)
+		return
+	}
+
+	c.JSON(http.StatusOK, &resp.Response{Result: result})
+}
+
+// Get 订阅详情
+func (ctrl *GinController) Get(c *gin.Context) {
+	req := &models.ID{}
+	if err := c.ShouldBindUri(req); err != nil {
+		_ = c.Error(err).
+			SetType(gin.ErrorTypePublic)
+		return
+	}
+
+	result, err := ctrl.WebhookSvc.Get(c.Request.Context(), req.ID)
+	if err != nil {
+		_ = c.Error(err).
+			SetType(gin.ErrorTypePublic)
+		return
+	}
+
+	c.JSON(http.StatusOK, &resp.Response{Result: result})
+}
+
+// Update 修改订阅
+func (ctrl *GinController) Update(c *gin.Context) {
+	uri := &models.ID{}
+	if err := c.ShouldBindUri(uri); err != nil {
+		_ = c.Error(err).
+			SetType(gin.ErrorTypePublic)
+		return
+	}
+	req := &models.UpdateWebhookReq{}
+	if err := c.ShouldBind(req); err != nil {
+		_ = c.Error(err).
+			SetType(gin.ErrorTypePublic)
+		return
+	}
+
+	result, err := ctrl.WebhookSvc.Update(c.Request.Context(), uri.ID, req)
+	if err != nil {
+		_ = c.Error(err).
+			SetType(gin.ErrorTypePublic)
+		return
+	}
+
+	c.JSON(http.StatusOK, &resp.Response{Result: result})
+}
+
+// Delete 删除订阅
+func (ctrl *GinController) Delete(c *gin.Context) {
+	req := &models.ID{}
+	if err := c.ShouldBindUri(req); err != nil {
+		_ = c.Error(err).
+			SetType(gin.ErrorTypePublic)
+		return
+	}
+
+	if err := ctrl.WebhookSvc.Delete(c.Request.Context(), req.ID); err != nil {
+		_ = c.Error(err).
+			SetType(gin.ErrorTypePublic)
+		return
+	}
+
+	c.JSON(http.StatusOK, &resp.Response{})
+}
+
+// Deliveries 投递记录
+func (ctrl *GinController) Deliveries(c *gin.Context) {
+	req := &models.ID{}
+	if err := c.ShouldBindUri(req); err != nil {
+		_ = c.Error(err).
+			SetType(gin.ErrorTypePublic)
+		return
+	}
+
+	p := c.MustGet("pagination").(*middlewares.Pagination)
+	result, err := ctrl.WebhookSvc.Deliveries(c.Request.Context(), req.ID, p)
+	if err != nil {
+		_ = c.Error(err).
+			SetType(gin.ErrorTypePublic)
+		return
+	}
+
+	c.JSON(http.StatusOK, &resp.Response{Result: result})
+}
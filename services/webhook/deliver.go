@@ -0,0 +1,208 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"lovebox/models"
+	"lovebox/pkg/errors"
+	"lovebox/pkg/httpclient"
+	"lovebox/pkg/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"gorm.io/gorm"
+)
+
+const (
+	HeaderEventID   = "X-Webhook-Id"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+
+	maxResponseBody = 1024
+	maxErrorLen     = 500
+)
+
+// Sign 签名内容为 timestamp + "." + body，使用HMAC-SHA256，
+// 接收方应校验时间戳防止重放
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Deliver 投递一次，last表示没有后续重试，返回错误时由任务队列退避重试
+func (s *Service) Deliver(ctx context.Context, deliveryID uint, last bool) error {
+	db := s.mysqlClient.Db().WithContext(ctx)
+
+	delivery := &models.WebhookDelivery{}
+	err := db.Where("id = ?", deliveryID).First(delivery).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if delivery.Status != models.WebhookDeliveryPending {
+		return nil
+	}
+
+	endpoint := &models.WebhookEndpoint{}
+	err = db.Where("id = ?", delivery.EndpointID).First(endpoint).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	if err != nil || endpoint.Status != models.WebhookStatusActive {
+		return db.Model(delivery).
+			Update("status", models.WebhookDeliverySkipped).
+			Error
+	}
+
+	statusCode, body, duration, sendErr := s.send(ctx, endpoint, delivery)
+
+	updates := map[string]interface{}{
+		"attempts":      gorm.Expr("attempts + 1"),
+		"status_code":   statusCode,
+		"response_body": body,
+		"duration_ms":   duration.Milliseconds(),
+		"error":         "",
+	}
+	if sendErr == nil {
+		now := time.Now()
+		updates["status"] = models.WebhookDeliverySucceeded
+		updates["delivered_at"] = &now
+		if err := db.Model(delivery).Updates(updates).Error; err != nil {
+			return err
+		}
+		return db.Model(endpoint).
+			Update("failure_count", 0).
+			Error
+	}
+
+	// 熔断中未实际发送，不计入连续失败；没有后续重试时标记为失败
+	if errors.Is(sendErr, httpclient.ErrCircuitOpen) {
+		if last {
+			err := db.Model(delivery).
+				Updates(map[string]interface{}{
+					"status": models.WebhookDeliveryFailed,
+					"error":  sendErr.Error(),
+				}).
+				Error
+			if err != nil {
+				return err
+			}
+		}
+		return sendErr
+	}
+
+	errMsg := sendErr.Error()
+	if len(errMsg) > maxErrorLen {
+		errMsg = errMsg[:maxErrorLen]
+	}
+	updates["error"] = errMsg
+
+	disabled, err := s.recordFailure(ctx, endpoint, errMsg)
+	if err != nil {
+		return err
+	}
+	if last || disabled {
+		updates["status"] = models.WebhookDeliveryFailed
+	}
+	if err := db.Model(delivery).Updates(updates).Error; err != nil {
+		return err
+	}
+
+	s.log.Warnf("Deliver webhook delivery=%d endpoint=%d err=%v", delivery.ID, endpoint.ID, sendErr)
+	if disabled {
+		return nil
+	}
+	return sendErr
+}
+
+// recordFailure 累加连续失败次数，达到阈值时禁用订阅
+func (s *Service) recordFailure(ctx context.Context, endpoint *models.WebhookEndpoint, reason string) (bool, error) {
+	db := s.mysqlClient.Db().WithContext(ctx)
+	err := db.Model(endpoint).
+		Update("failure_count", gorm.Expr("failure_count + 1")).
+		Error
+	if err != nil {
+		return false, err
+	}
+	if s.disableThreshold == 0 || endpoint.FailureCount+1 < s.disableThreshold {
+		return false, nil
+	}
+
+	now := time.Now()
+	err = db.Model(endpoint).
+		Updates(map[string]interface{}{
+			"status":         models.WebhookStatusDisabled,
+			"disabled_at":    &now,
+			"disable_reason": reason,
+		}).
+		Error
+	if err != nil {
+		return false, err
+	}
+	s.log.Warnf("Webhook endpoint=%d disabled after %d consecutive failures", endpoint.ID, endpoint.FailureCount+1)
+	return true, nil
+}
+
+func (s *Service) send(
+	ctx context.Context,
+	endpoint *models.WebhookEndpoint,
+	delivery *models.WebhookDelivery,
+) (int, string, time.Duration, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "webhook.deliver")
+	defer span.End()
+	span.SetAttributes(
+		attribute.Int64("webhook.endpoint", int64(endpoint.ID)),
+		attribute.String("webhook.event", delivery.EventType),
+	)
+
+	body := []byte(delivery.Payload)
+	timestamp := time.Now().Unix()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return 0, "", 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEventID, delivery.EventID)
+	req.Header.Set(HeaderDelivery, strconv.FormatUint(uint64(delivery.ID), 10))
+	req.Header.Set(HeaderEvent, delivery.EventType)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(endpoint.Secret, timestamp, body))
+
+	start := time.Now()
+	res, err := s.httpClient.Do(req)
+	duration := time.Since(start)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return 0, "", duration, err
+	}
+	defer res.Body.Close()
+
+	buf, _ := io.ReadAll(io.LimitReader(res.Body, maxResponseBody))
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		err = fmt.Errorf("unexpected status %d", res.StatusCode)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return res.StatusCode, string(buf), duration, err
+	}
+	return res.StatusCode, string(buf), duration, nil
+}
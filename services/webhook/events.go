@@ -0,0 +1,112 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"lovebox/models"
+	"lovebox/pkg/errors"
+	"lovebox/pkg/jobqueue"
+	"lovebox/services/outbox"
+
+	"github.com/streadway/amqp"
+	"gorm.io/gorm"
+)
+
+// Consume 消费outbox发布到rabbitmq的事件，为订阅了该事件的每个订阅创建投递记录并添加投递任务，
+// 返回错误时由rabbitmq重试，重复投递的消息复用已创建的投递记录
+func (s *Service) Consume(ctx context.Context, msg amqp.Delivery) error {
+	// 只处理outbox发布的事件，eventbus转发到同一exchange的事件不保证送达
+	eventType, _ := msg.Headers[outbox.HeaderEventType].(string)
+	if eventType == "" || !isWebhookEvent(eventType) {
+		return nil
+	}
+
+	endpoints := []*models.WebhookEndpoint{}
+	err := s.mysqlClient.Db().WithContext(ctx).
+		Where("status = ? AND JSON_CONTAINS(events, JSON_QUOTE(?))", models.WebhookStatusActive, eventType).
+		Find(&endpoints).
+		Error
+	if err != nil {
+		return err
+	}
+	if len(endpoints) == 0 {
+		return nil
+	}
+
+	// outbox消息id作为事件id，接收方可据此去重
+	eventID := msg.MessageId
+	createdAt := msg.Timestamp
+	if createdAt.IsZero() {
+		createdAt = time.Now()
+	}
+	payload, err := json.Marshal(&models.WebhookPayload{
+		ID:        eventID,
+		Type:      eventType,
+		CreatedAt: createdAt,
+		Data:      json.RawMessage(msg.Body),
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, endpoint := range endpoints {
+		if err := s.dispatch(ctx, endpoint, eventID, eventType, string(payload)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dispatch 创建投递记录并添加投递任务，记录已存在且不再待投递时跳过
+func (s *Service) dispatch(
+	ctx context.Context,
+	endpoint *models.WebhookEndpoint,
+	eventID string,
+	eventType string,
+	payload string,
+) error {
+	db := s.mysqlClient.Db().WithContext(ctx)
+
+	delivery := &models.WebhookDelivery{}
+	err := db.Where("endpoint_id = ? AND event_id = ?", endpoint.ID, eventID).First(delivery).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		delivery = &models.WebhookDelivery{
+			EndpointID: endpoint.ID,
+			EventID:    eventID,
+			EventType:  eventType,
+			Payload:    payload,
+			Status:     models.WebhookDeliveryPending,
+		}
+		err = db.Model(&models.WebhookDelivery{}).Create(delivery).Error
+	}
+	if err != nil {
+		s.log.Errorf("Create webhook delivery endpoint=%d event=%s err=%v", endpoint.ID, eventID, err)
+		return err
+	}
+	if delivery.Status != models.WebhookDeliveryPending {
+		return nil
+	}
+
+	_, err = s.jobQueue.Enqueue(
+		ctx,
+		models.JobWebhookDeliver,
+		&models.WebhookDeliverJob{DeliveryID: delivery.ID},
+		jobqueue.WithJobMaxRetries(s.maxRetries),
+	)
+	if err != nil {
+		s.log.Errorf("Enqueue webhook delivery=%d err=%v", delivery.ID, err)
+		return err
+	}
+	return nil
+}
+
+func isWebhookEvent(eventType string) bool {
+	for _, e := range models.WebhookEvents {
+		if e == eventType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,209 @@
+package webhook
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"lovebox/models"
+	"lovebox/pkg/database"
+	"lovebox/pkg/errors"
+	"lovebox/pkg/gin/middlewares"
+	"lovebox/pkg/httpclient"
+	"lovebox/pkg/jobqueue"
+	"lovebox/pkg/resp"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrWebhookNotFound ...
+	ErrWebhookNotFound = errors.NewWithStatus(resp.WEBHOOK_NOT_FOUND, http.StatusNotFound)
+)
+
+type Service struct {
+	log              *zap.SugaredLogger
+	mysqlClient      *database.Client
+	jobQueue         *jobqueue.Queue
+	httpClient       *httpclient.Client
+	maxRetries       int
+	disableThreshold uint
+}
+
+// NewService maxRetries为投递失败的重试次数，连续失败disableThreshold次后自动禁用订阅
+func NewService(
+	mysqlClient *database.Client,
+	jobQueue *jobqueue.Queue,
+	timeout time.Duration,
+	maxRetries int,
+	disableThreshold uint,
+) *Service {
+	return &Service{
+		log:         zap.S().With("module", "services.webhook.service"),
+		mysqlClient: mysqlClient,
+		jobQueue:    jobQueue,
+		httpClient: httpclient.New(
+			httpclient.WithName("webhook"),
+			httpclient.WithTimeout(timeout),
+			// 由任务队列退避重试
+			httpclient.WithRetry(0, 0, 0),
+			// 不跟随重定向，避免签名请求被转发到其他地址
+			httpclient.WithCheckRedirect(func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			}),
+		),
+		maxRetries:       maxRetries,
+		disableThreshold: disableThreshold,
+	}
+}
+
+// Create 创建订阅，返回的密钥只在创建时可见
+func (s *Service) Create(
+	ctx context.Context,
+	req *models.CreateWebhookReq,
+) (*models.CreateWebhookRes, error) {
+	secret, err := newSecret()
+	if err != nil {
+		return nil, err
+	}
+	endpoint := &models.WebhookEndpoint{
+		URL:         req.URL,
+		Secret:      secret,
+		Events:      req.Events,
+		Description: req.Description,
+		Status:      models.WebhookStatusActive,
+	}
+	err = s.mysqlClient.Db().WithContext(ctx).
+		Model(&models.WebhookEndpoint{}).
+		Create(endpoint).
+		Error
+	if err != nil {
+		s.log.Errorf("Create webhook err=%v", err)
+		return nil, err
+	}
+	return &models.CreateWebhookRes{
+		WebhookEndpoint: endpoint,
+		Secret:          secret,
+	}, nil
+}
+
+// List 订阅列表
+func (s *Service) List(
+	ctx context.Context,
+	p *middlewares.Pagination,
+) (*resp.CursorPageResult, error) {
+	endpoints := []*models.WebhookEndpoint{}
+	query := s.mysqlClient.Db().WithContext(ctx).
+		Model(&models.WebhookEndpoint{})
+	return p.GetPage(query, &endpoints)
+}
+
+// Get 查询订阅
+func (s *Service) Get(
+	ctx context.Context,
+	id uint,
+) (*models.WebhookEndpoint, error) {
+	endpoint := &models.WebhookEndpoint{}
+	err := s.mysqlClient.Db().WithContext(ctx).
+		Where("id = ?", id).
+		First(endpoint).
+		Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrWebhookNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return endpoint, nil
+}
+
+// Update 修改订阅，重新启用时清空失败次数
+func (s *Service) Update(
+	ctx context.Context,
+	id uint,
+	req *models.UpdateWebhookReq,
+) (*models.WebhookEndpoint, error) {
+	endpoint, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := map[string]interface{}{}
+	if req.URL != "" {
+		updates["url"] = req.URL
+	}
+	if len(req.Events) > 0 {
+		updates["events"] = models.ArrayFieldString(req.Events)
+	}
+	if req.Description != nil {
+		updates["description"] = *req.Description
+	}
+	if req.Enabled != nil {
+		if *req.Enabled {
+			updates["status"] = models.WebhookStatusActive
+			updates["failure_count"] = 0
+			updates["disabled_at"] = nil
+			updates["disable_reason"] = ""
+		} else {
+			updates["status"] = models.WebhookStatusDisabled
+		}
+	}
+	if len(updates) == 0 {
+		return endpoint, nil
+	}
+
+	err = s.mysqlClient.Db().WithContext(ctx).
+		Model(endpoint).
+		Updates(updates).
+		Error
+	if err != nil {
+		s.log.Errorf("Update webhook id=%d err=%v", id, err)
+		return nil, err
+	}
+	return s.Get(ctx, id)
+}
+
+// Delete 删除订阅
+func (s *Service) Delete(
+	ctx context.Context,
+	id uint,
+) error {
+	result := s.mysqlClient.Db().WithContext(ctx).
+		Where("id = ?", id).
+		Delete(&models.WebhookEndpoint{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrWebhookNotFound
+	}
+	return nil
+}
+
+// Deliveries 订阅的投递记录
+func (s *Service) Deliveries(
+	ctx context.Context,
+	id uint,
+	p *middlewares.Pagination,
+) (*resp.CursorPageResult, error) {
+	if _, err := s.Get(ctx, id); err != nil {
+		return nil, err
+	}
+
+	deliveries := []*models.WebhookDelivery{}
+	query := s.mysqlClient.Db().WithContext(ctx).
+		Model(&models.WebhookDelivery{}).
+		Where("endpoint_id = ?", id)
+	return p.GetPage(query, &deliveries)
+}
+
+func newSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(buf), nil
+}